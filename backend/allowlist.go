@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// filterReasonNoMatchingTypes is the filter_reason of an analysis whose
+// allowed_resource_types matched none of the code's resources.
+const filterReasonNoMatchingTypes = "no_matching_resource_types"
+
+// filterResourceTypes returns tf's source with the resource blocks whose
+// type is not allowed blanked out, and how many it removed. Lines keep their
+// numbers, so findings still point at the submitted code.
+func filterResourceTypes(tf *TerraformFile, allowed []string) (string, int) {
+	lines := strings.SplitAfter(tf.Source, "\n")
+	removed := 0
+	for _, b := range tf.Resources {
+		if slices.Contains(allowed, b.Type) {
+			continue
+		}
+		removed++
+		for i := b.Line - 1; i < b.EndLine && i < len(lines); i++ {
+			if strings.HasSuffix(lines[i], "\n") {
+				lines[i] = "\n"
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+	return strings.Join(lines, ""), removed
+}
+
+// applyResourceAllowlist drops the resources whose type is not in allowed
+// from source, reparsing it as tf. An empty allowlist keeps every resource.
+// It reports false if the filtered code could not be parsed, having written
+// the error response.
+func applyResourceAllowlist(ctx context.Context, w http.ResponseWriter, logger *slog.Logger, source string, tf *TerraformFile, allowed []string) (string, *TerraformFile, bool) {
+	if len(allowed) == 0 {
+		return source, tf, true
+	}
+	filtered, removed := filterResourceTypes(tf, allowed)
+	if removed == 0 {
+		return source, tf, true
+	}
+	logger.Info("Filtered out resources not in allowed_resource_types", "count", removed, "remaining", len(tf.Resources)-removed)
+	tf, ok := parseSource(ctx, w, tf.Filename, filtered)
+	return filtered, tf, ok
+}
+
+// noMatchingResourcesResponse is the response to an analysis whose
+// allowlist filtered out every resource, returned without invoking the agent.
+func noMatchingResourcesResponse(tf *TerraformFile) AnalyzeResponse {
+	return AnalyzeResponse{
+		Findings:     []Finding{},
+		Filtered:     true,
+		FilterReason: filterReasonNoMatchingTypes,
+		Metrics:      tf.analysisMetrics(),
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// bedrockAnalyzerName is the name the Bedrock agent analyzer is registered under.
+const bedrockAnalyzerName = "bedrock"
+
+// Analyzer produces compliance findings for a parsed Terraform file.
+// Implementations must be safe for concurrent use.
+type Analyzer interface {
+	Analyze(ctx context.Context, tf TerraformFile) ([]Finding, error)
+}
+
+var (
+	analyzersMu sync.RWMutex
+	analyzers   = make(map[string]Analyzer)
+)
+
+// RegisterAnalyzer makes an analyzer available to /analyze under name. It
+// panics if a is nil or name is already registered.
+func RegisterAnalyzer(name string, a Analyzer) {
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+	if a == nil {
+		panic("analyzer: RegisterAnalyzer analyzer is nil")
+	}
+	if _, dup := analyzers[name]; dup {
+		panic("analyzer: RegisterAnalyzer called twice for analyzer " + name)
+	}
+	analyzers[name] = a
+}
+
+// analyzerNames returns the names of the registered analyzers, sorted.
+func analyzerNames() []string {
+	analyzersMu.RLock()
+	defer analyzersMu.RUnlock()
+	names := make([]string, 0, len(analyzers))
+	for name := range analyzers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// analysisRequest carries the per-request parameters that analyzers need
+// beyond the file itself. The Bedrock analyzer records the raw agent
+// response, retry count and region on it.
+type analysisRequest struct {
+	Framework Framework
+	SessionID string
+
+	Suggestion string
+	Retries    int
+	Region     string
+	// Truncated reports that the agent found more issues than it could report.
+	Truncated bool
+	// Chunks is the number of agent invocations the file was split across.
+	Chunks int
+	// DeadlineExceeded reports that the analysis deadline cut the agent off
+	// before it reviewed Remaining.
+	DeadlineExceeded bool
+	Remaining        []string
+	// PromptTokens and Latency are the estimated size of the prompts sent
+	// to the agent and how long it took to answer them.
+	PromptTokens int
+	Latency      time.Duration
+	// DuplicatesRemoved is the number of agent findings dropped as repeats
+	// of another, or of a local analyzer's finding for the same rule.
+	DuplicatesRemoved int
+}
+
+// analysisRequestKey is the context key under which the analysis request is stored.
+type analysisRequestKey struct{}
+
+// withAnalysisRequest returns a copy of ctx carrying req.
+func withAnalysisRequest(ctx context.Context, req *analysisRequest) context.Context {
+	return context.WithValue(ctx, analysisRequestKey{}, req)
+}
+
+// analysisRequestFromContext returns the analysis request stored in ctx.
+func analysisRequestFromContext(ctx context.Context) (*analysisRequest, bool) {
+	req, ok := ctx.Value(analysisRequestKey{}).(*analysisRequest)
+	return req, ok
+}
+
+// runAnalyzers runs every registered analyzer except those named in skip
+// concurrently and merges their findings in analyzer name order, with the
+// agent's findings for rules a local analyzer reported dropped and each
+// finding's blast radius listed. An error
+// from any analyzer fails the whole analysis.
+func runAnalyzers(ctx context.Context, tf TerraformFile, skip ...string) ([]Finding, error) {
+	names := slices.DeleteFunc(analyzerNames(), func(name string) bool {
+		return slices.Contains(skip, name)
+	})
+
+	results := make([][]Finding, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		analyzersMu.RLock()
+		a := analyzers[name]
+		analyzersMu.RUnlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if results[i], errs[i] = a.Analyze(ctx, tf); errs[i] != nil {
+				errs[i] = fmt.Errorf("%s analyzer: %w", name, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	var agent, local []Finding
+	for i, r := range results {
+		if names[i] == bedrockAnalyzerName {
+			agent = r
+		} else {
+			local = append(local, r...)
+		}
+	}
+	merged, dropped := tf.mergeFindings(agent, local)
+	if req, ok := analysisRequestFromContext(ctx); ok {
+		req.DuplicatesRemoved += dropped
+	}
+	return tf.withBlastRadius(tf.WorkspaceRules.apply(merged)), nil
+}
+
+// bedrockAnalyzer asks the Bedrock agent to review the file against the
+// requested framework.
+type bedrockAnalyzer struct {
+	api *BedrockConverseAPI
+}
+
+// Analyze implements Analyzer.
+func (a *bedrockAnalyzer) Analyze(ctx context.Context, tf TerraformFile) ([]Finding, error) {
+	req, ok := analysisRequestFromContext(ctx)
+	if !ok {
+		return nil, errors.New("missing analysis request")
+	}
+	if a.api.Config().OfflineMode {
+		return nil, nil
+	}
+	logger := loggerFromContext(ctx)
+
+	analysis, err := a.api.invokeAnalysis(ctx, logger, &tf, req.Framework, req.SessionID, nil)
+	req.Retries, req.Region, req.Chunks = analysis.Retries, analysis.Region, analysis.Chunks
+	if err != nil {
+		return nil, err
+	}
+	req.Suggestion, req.Truncated = analysis.Suggestion, analysis.Truncated
+	req.DeadlineExceeded, req.Remaining = analysis.DeadlineExceeded, analysis.Remaining
+	req.PromptTokens, req.Latency = analysis.PromptTokens, analysis.Latency
+	req.DuplicatesRemoved = analysis.DuplicatesRemoved
+	return analysis.Findings, nil
+}
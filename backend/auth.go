@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// apiKeyAuth checks bearer tokens against the configured API keys. Keys are
+// held and compared as SHA-256 digests so the raw values never reach logs.
+type apiKeyAuth struct {
+	digests [][sha256.Size]byte
+}
+
+// newAPIKeyAuth returns an authenticator accepting any of keys.
+func newAPIKeyAuth(keys []string) *apiKeyAuth {
+	a := &apiKeyAuth{}
+	for _, k := range keys {
+		a.digests = append(a.digests, sha256.Sum256([]byte(k)))
+	}
+	return a
+}
+
+// keyID identifies an API key in logs by the first 8 hex characters of its SHA-256.
+func keyID(digest [sha256.Size]byte) string {
+	return hex.EncodeToString(digest[:])[:8]
+}
+
+// keyIDKey is the context key for the ID of the API key a request was
+// authenticated with.
+type keyIDKey struct{}
+
+// keyIDFromContext returns the ID of the API key ctx's request was
+// authenticated with, or "" when API keys are not required.
+func keyIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(keyIDKey{}).(string)
+	return id
+}
+
+// authenticate returns the key ID of the bearer token in r, if it is valid.
+func (a *apiKeyAuth) authenticate(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+
+	digest := sha256.Sum256([]byte(token))
+	valid := 0
+	for _, d := range a.digests {
+		valid |= subtle.ConstantTimeCompare(digest[:], d[:])
+	}
+	if valid == 0 {
+		return "", false
+	}
+	return keyID(digest), true
+}
+
+// publicPaths are the endpoints served without an API key: the API
+// description, so it can be browsed before a key is issued.
+var publicPaths = []string{"/openapi.json", "/docs"}
+
+// middleware requires a valid API key on every endpoint except /health, the
+// public paths and the /admin endpoints, which check admin keys themselves.
+// When no keys are configured every request is let through.
+func (a *apiKeyAuth) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(a.digests) == 0 || strings.HasPrefix(r.URL.Path, "/health") || strings.HasPrefix(r.URL.Path, "/admin/") || slices.Contains(publicPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, ok := a.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, "Missing or invalid API key")
+			return
+		}
+
+		logger := loggerFromContext(r.Context()).With("key_id", id)
+		logger.Info("Request authenticated", "path", r.URL.Path)
+		ctx := context.WithValue(context.WithValue(r.Context(), loggerKey{}, logger), keyIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
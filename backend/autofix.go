@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// maxAutofixFindings bounds the findings remediated by one /autofix request.
+const maxAutofixFindings = 20
+
+// AutofixRequest defines the structure of the incoming /autofix JSON request.
+type AutofixRequest struct {
+	Code       string   `json:"code"`
+	FindingIDs []string `json:"finding_ids"`
+}
+
+// AutofixResponse defines the structure of the /autofix JSON response.
+type AutofixResponse struct {
+	Original       string   `json:"original"`
+	Fixed          string   `json:"fixed"`
+	Diff           string   `json:"diff"`
+	AppliedFixes   []string `json:"applied_fixes"`
+	SecretWarnings []string `json:"secret_warnings,omitempty"`
+}
+
+// agentFix is the JSON object the agent returns for an autofix prompt.
+type agentFix struct {
+	Fixed        string   `json:"fixed"`
+	AppliedFixes []string `json:"applied_fixes"`
+}
+
+// autofixHandler handles the /autofix endpoint, asking the agent to rewrite
+// the code with only the requested findings remediated.
+func (api *BedrockConverseAPI) autofixHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	var req AutofixRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Code is required")
+		return
+	}
+	if api.rejectPromptInjection(w, loggerFromContext(r.Context()), req.Code) {
+		return
+	}
+	if len(req.FindingIDs) == 0 || len(req.FindingIDs) > maxAutofixFindings {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("finding_ids must list between 1 and %d finding IDs", maxAutofixFindings))
+		return
+	}
+	for _, id := range req.FindingIDs {
+		if !ruleIDPattern.MatchString(id) {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid finding ID %q: only letters, digits, '.', '_' and '-' are allowed", id))
+			return
+		}
+	}
+
+	sessionID, ok := api.requestSessionID(w, r)
+	if !ok {
+		return
+	}
+	logger := loggerFromContext(r.Context()).With("agent_id", api.Config().AgentID, "session_id", sessionID, "finding_ids", req.FindingIDs)
+
+	tf, ok := parseSource(r.Context(), w, "main.tf", req.Code)
+	if !ok {
+		return
+	}
+
+	// Secrets get numbered placeholders so the ones the agent leaves alone
+	// can be put back into the fixed code.
+	redacted, secretWarnings, restore := api.Secrets.redactNumbered(req.Code, tf)
+	if len(secretWarnings) > 0 {
+		logger.Warn("Redacted potential secrets from submitted code", "count", len(secretWarnings))
+	}
+
+	result, err := api.invokeAgentWithRetry(r.Context(), logger, api.Config().agentFor(""), sessionID, buildAutofixPrompt(redacted, req.FindingIDs), nil)
+	w.Header().Set(retryCountHeader, strconv.Itoa(result.Retries))
+	if result.Region != "" {
+		w.Header().Set(bedrockRegionHeader, result.Region)
+	}
+	if err != nil {
+		writeAgentError(w, err)
+		return
+	}
+
+	fix, err := parseAgentFix(result.Suggestion)
+	if err != nil {
+		logger.Warn("Failed to parse agent response", "error", err)
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: "Agent response could not be parsed", Detail: err.Error()})
+		return
+	}
+	fixed := restore(fix.Fixed)
+	if _, diags := parseTerraform("main.tf", fixed); diags.HasErrors() {
+		logger.Warn("Agent returned invalid Terraform", "error", diags)
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: "Agent returned invalid Terraform", Detail: diags.Error()})
+		return
+	}
+
+	// Only report fixes that were asked for.
+	applied := []string{}
+	for _, id := range fix.AppliedFixes {
+		if slices.Contains(req.FindingIDs, id) && !slices.Contains(applied, id) {
+			applied = append(applied, id)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, AutofixResponse{
+		Original:       req.Code,
+		Fixed:          fixed,
+		Diff:           unifiedDiff("main.tf", req.Code, fixed),
+		AppliedFixes:   applied,
+		SecretWarnings: secretWarnings,
+	})
+}
+
+// parseAgentFix decodes the JSON object returned by the agent, ignoring any
+// text the agent wraps around it.
+func parseAgentFix(output string) (agentFix, error) {
+	var fix agentFix
+
+	raw, ok := extractJSON(output, '{', '}')
+	if !ok {
+		return fix, errors.New("agent response does not contain a JSON object")
+	}
+	if err := json.Unmarshal(raw, &fix); err != nil {
+		return fix, fmt.Errorf("agent response is not a valid JSON object: %w", err)
+	}
+	if fix.Fixed == "" {
+		return fix, errors.New("agent response does not contain the fixed code")
+	}
+	return fix, nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Baseline is the approved analysis of a workspace against a framework.
+type Baseline struct {
+	WorkspaceID string    `json:"workspace_id"`
+	Framework   string    `json:"framework"`
+	ContentHash string    `json:"content_hash"`
+	CreatedAt   time.Time `json:"created_at"`
+	Findings    []Finding `json:"findings"`
+}
+
+// BaselineCompareResponse defines the structure of the /baseline/compare
+// JSON response.
+type BaselineCompareResponse struct {
+	Framework           string    `json:"framework"`
+	BaselineCreatedAt   time.Time `json:"baseline_created_at"`
+	NewViolations       []Finding `json:"new_violations"`
+	ResolvedViolations  []Finding `json:"resolved_violations"`
+	UnchangedViolations []Finding `json:"unchanged_violations"`
+	// Regression reports that the code has violations the baseline does not.
+	Regression bool `json:"regression"`
+}
+
+// saveBaseline stores b, replacing the workspace's previous baseline for
+// the framework.
+func (s *historyStore) saveBaseline(ctx context.Context, b Baseline) error {
+	findings, err := json.Marshal(b.Findings)
+	if err != nil {
+		return err
+	}
+	compressed, err := compressHistory(findings)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO baselines (workspace_id, framework, content_hash, created_at, findings, findings_len) VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (workspace_id, framework) DO UPDATE SET
+		 content_hash = excluded.content_hash, created_at = excluded.created_at, findings = excluded.findings, findings_len = excluded.findings_len`,
+		b.WorkspaceID, b.Framework, b.ContentHash, b.CreatedAt.UnixMilli(), compressed, len(findings))
+	return err
+}
+
+// baseline returns the workspace's baseline for the framework, or
+// sql.ErrNoRows.
+func (s *historyStore) baseline(ctx context.Context, workspaceID, framework string) (Baseline, error) {
+	b := Baseline{WorkspaceID: workspaceID, Framework: framework}
+	var (
+		createdAt int64
+		findings  []byte
+	)
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT content_hash, created_at, findings FROM baselines WHERE workspace_id = ? AND framework = ?`,
+		workspaceID, framework).Scan(&b.ContentHash, &createdAt, &findings); err != nil {
+		return Baseline{}, err
+	}
+	b.CreatedAt = time.UnixMilli(createdAt).UTC()
+	findings, err := decompressHistory(findings)
+	if err != nil {
+		return Baseline{}, fmt.Errorf("baseline has corrupt findings: %w", err)
+	}
+	if err := json.Unmarshal(findings, &b.Findings); err != nil {
+		return Baseline{}, fmt.Errorf("baseline has invalid findings: %w", err)
+	}
+	return b, nil
+}
+
+// compareBaseline classifies the current findings against the baseline's,
+// matching violations as /diff does.
+func compareBaseline(baseline, current []Finding) BaselineCompareResponse {
+	diff := diffFindings(baseline, current)
+	resp := BaselineCompareResponse{
+		NewViolations:       diff.Introduced,
+		ResolvedViolations:  diff.Resolved,
+		UnchangedViolations: []Finding{},
+		Regression:          len(diff.Introduced) > 0,
+	}
+	accepted := make(map[string]bool, len(baseline))
+	for _, f := range baseline {
+		accepted[f.diffKey()] = true
+	}
+	for _, f := range current {
+		if accepted[f.diffKey()] {
+			resp.UnchangedViolations = append(resp.UnchangedViolations, f)
+		}
+	}
+	return resp
+}
+
+// analyzeForBaseline analyzes the code of a /baseline request for the
+// workspace named in its X-Workspace-ID header. On failure it writes an
+// error response and returns false.
+func (api *BedrockConverseAPI) analyzeForBaseline(w http.ResponseWriter, r *http.Request) (Baseline, bool) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return Baseline{}, false
+	}
+	if api.History == nil {
+		writeJSONError(w, http.StatusNotFound, "Analysis history is not enabled")
+		return Baseline{}, false
+	}
+
+	logger := loggerFromContext(r.Context())
+
+	workspaceID := r.Header.Get(workspaceIDHeader)
+	if workspaceID == "" {
+		writeJSONError(w, http.StatusBadRequest, workspaceIDHeader+" header is required")
+		return Baseline{}, false
+	}
+
+	var req AnalyzeRequest
+	if !decodeJSONBody(w, r, &req) {
+		return Baseline{}, false
+	}
+
+	if req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query text is empty or not a string")
+		return Baseline{}, false
+	}
+	if api.rejectPromptInjection(w, logger, req.Code) {
+		return Baseline{}, false
+	}
+
+	fw, err := lookupFramework(req.Framework)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return Baseline{}, false
+	}
+
+	source, err := inputCode(req.Format, req.Code)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return Baseline{}, false
+	}
+
+	tf, ok := parseSource(r.Context(), w, "main.tf", source)
+	if !ok {
+		return Baseline{}, false
+	}
+	if req.Framework == "" {
+		fw = detectFramework(tf)
+	}
+
+	findings, err := api.analyzeSource(r.Context(), logger, source, tf, fw)
+	if err != nil {
+		writeAnalysisError(w, err)
+		return Baseline{}, false
+	}
+	return Baseline{
+		WorkspaceID: workspaceID,
+		Framework:   fw.ID,
+		ContentHash: contentHash(source),
+		CreatedAt:   time.Now().UTC(),
+		Findings:    findings,
+	}, true
+}
+
+// baselineSaveHandler handles the /baseline/save endpoint, storing the
+// analysis of the submitted code as the workspace's approved baseline for
+// its framework.
+func (api *BedrockConverseAPI) baselineSaveHandler(w http.ResponseWriter, r *http.Request) {
+	b, ok := api.analyzeForBaseline(w, r)
+	if !ok {
+		return
+	}
+
+	if err := api.History.saveBaseline(r.Context(), b); err != nil {
+		loggerFromContext(r.Context()).Error("Failed to save compliance baseline", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to save baseline")
+		return
+	}
+	writeJSON(w, http.StatusOK, b)
+}
+
+// baselineCompareHandler handles the /baseline/compare endpoint. It
+// analyzes the submitted code and compares the findings with the
+// workspace's baseline, answering 422 when the code regressed so CI can
+// gate on the status code.
+func (api *BedrockConverseAPI) baselineCompareHandler(w http.ResponseWriter, r *http.Request) {
+	current, ok := api.analyzeForBaseline(w, r)
+	if !ok {
+		return
+	}
+
+	logger := loggerFromContext(r.Context())
+
+	baseline, err := api.History.baseline(r.Context(), current.WorkspaceID, current.Framework)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("No baseline saved for workspace %q and framework %s", current.WorkspaceID, current.Framework))
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to read compliance baseline", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read baseline")
+		return
+	}
+
+	resp := compareBaseline(baseline.Findings, current.Findings)
+	resp.Framework = current.Framework
+	resp.BaselineCreatedAt = baseline.CreatedAt
+	status := http.StatusOK
+	if resp.Regression {
+		status = http.StatusUnprocessableEntity
+	}
+	logger.Info("Compared with baseline", "new_violations", len(resp.NewViolations), "resolved_violations", len(resp.ResolvedViolations))
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// BatchFile is a single Terraform file submitted to /batch.
+type BatchFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// BatchRequest defines the structure of the incoming /batch JSON request.
+type BatchRequest struct {
+	Files     []BatchFile `json:"files"`
+	Framework string      `json:"framework,omitempty"`
+}
+
+// BatchFileResult holds the suggestions for one file in a batch.
+type BatchFileResult struct {
+	Name           string    `json:"name"`
+	Suggestions    []Finding `json:"suggestions"`
+	SecretWarnings []string  `json:"secret_warnings,omitempty"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// BatchResponse defines the structure of the /batch JSON response.
+type BatchResponse struct {
+	Files []BatchFileResult `json:"files"`
+}
+
+// batchHandler handles the /batch endpoint. Every file is analyzed in its own
+// agent invocation, but each prompt describes the blocks declared across the
+// whole batch so cross-file references keep their context.
+func (api *BedrockConverseAPI) batchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	logger := loggerFromContext(r.Context())
+
+	var req BatchRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	fw, err := lookupFramework(req.Framework)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(req.Files) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "At least one file is required")
+		return
+	}
+	if len(req.Files) > api.Config().BatchMaxFiles {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Too many files: at most %d are allowed", api.Config().BatchMaxFiles))
+		return
+	}
+	totalBytes := 0
+	for _, f := range req.Files {
+		if f.Name == "" || f.Content == "" {
+			writeJSONError(w, http.StatusBadRequest, "Every file needs a name and content")
+			return
+		}
+		totalBytes += len(f.Content)
+	}
+	if totalBytes > api.Config().BatchMaxBytes {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Batch too large: at most %d bytes of content are allowed", api.Config().BatchMaxBytes))
+		return
+	}
+	for _, f := range req.Files {
+		if api.rejectPromptInjection(w, logger.With("file", f.Name), f.Content) {
+			return
+		}
+	}
+
+	// Parse every file up front so each prompt can see the whole module, and
+	// reject the batch if any file is broken.
+	parsed := make([]*TerraformFile, len(req.Files))
+	module := &TerraformFile{}
+	var syntaxErrs []SyntaxDiagnostic
+	for i, f := range req.Files {
+		var diags hcl.Diagnostics
+		parsed[i], diags = parseTerraform(f.Name, f.Content)
+		syntaxErrs = append(syntaxErrs, syntaxErrors(diags)...)
+		module.merge(parsed[i])
+	}
+	if len(syntaxErrs) > 0 {
+		logger.Warn("Batch contains Terraform syntax errors", "count", len(syntaxErrs))
+		writeSyntaxErrors(w, syntaxErrs)
+		return
+	}
+	if req.Framework == "" {
+		fw = detectFramework(module)
+	}
+
+	// Files run on the shared worker pool under the request's context, so a
+	// client disconnect cancels the files still queued or in flight.
+	results := make([]BatchFileResult, len(req.Files))
+	done := make([]<-chan error, len(req.Files))
+	for i, f := range req.Files {
+		results[i] = BatchFileResult{Name: f.Name}
+		done[i] = api.Batch.Submit(r.Context(), func(ctx context.Context) error {
+			results[i] = api.analyzeBatchFile(ctx, fw, f, parsed[i], module)
+			if results[i].Error != "" {
+				return errors.New(results[i].Error)
+			}
+			return nil
+		})
+	}
+	for i := range done {
+		if err := <-done[i]; err != nil && results[i].Error == "" {
+			results[i].Error = "Analysis cancelled"
+		}
+	}
+
+	writeJSON(w, http.StatusOK, BatchResponse{Files: results})
+}
+
+// analyzeBatchFile runs the agent against a single file of a batch. Agent
+// sessions cannot be shared by concurrent invocations, so each file gets its own.
+func (api *BedrockConverseAPI) analyzeBatchFile(ctx context.Context, fw Framework, f BatchFile, tf, module *TerraformFile) BatchFileResult {
+	result := BatchFileResult{Name: f.Name}
+
+	sessionID, err := newUUID()
+	if err != nil {
+		result.Error = "Failed to create session"
+		return result
+	}
+	agent := api.Config().agentFor(fw.ID)
+	logger := loggerFromContext(ctx).With("agent_id", agent.AgentID, "session_id", sessionID, "framework", fw.ID, "file", f.Name)
+
+	result.SecretWarnings = api.redactSource(logger, tf)
+
+	// In offline mode only the local analyzers check the file.
+	if !api.Config().OfflineMode {
+		prompt, err := api.buildAnalysisPrompt(ctx, tf.Source, tf.ResourceTypes(), module, fw)
+		if err != nil {
+			logger.Error("Failed to build analysis prompt", "error", err)
+			result.Error = "Analysis failed"
+			return result
+		}
+		invocation, err := api.invokeAnalysisAgent(ctx, logger, agent, sessionID, prompt, nil)
+		if err != nil {
+			_, result.Error = agentErrorStatus(err)
+			return result
+		}
+
+		if result.Suggestions, err = parseFindings(invocation.Suggestion); err != nil {
+			logger.Warn("Failed to parse agent response", "error", err)
+			result.Error = "Agent response could not be parsed"
+			return result
+		}
+		result.Suggestions, _ = dedupeFindings(tf, result.Suggestions)
+		api.annotateFindings(fw.ID, result.Suggestions)
+	}
+
+	// The agent has seen the whole module; the other analyzers check each file on its own.
+	local, err := runAnalyzers(withAnalysisRequest(ctx, &analysisRequest{Framework: fw, SessionID: sessionID}), *tf, bedrockAnalyzerName)
+	if err != nil {
+		logger.Warn("Analyzer failed", "error", err)
+		result.Error = "Analysis failed"
+		return result
+	}
+	merged, _ := tf.mergeFindings(result.Suggestions, local)
+	result.Suggestions = tf.withBlastRadius(tf.WorkspaceRules.apply(merged))
+	return result
+}
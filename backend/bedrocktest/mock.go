@@ -0,0 +1,90 @@
+// Package bedrocktest provides a mock Bedrock agent runtime for tests that
+// must not call AWS, such as integration tests run in CI without credentials.
+package bedrocktest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+)
+
+// DefaultResponse is the response of a Mock without a fixture: an agent
+// reporting no findings.
+const DefaultResponse = "[]"
+
+// Mock answers every agent invocation with a fixed response. It is safe for
+// concurrent use.
+type Mock struct {
+	// Response is the agent's response text, such as a JSON array of findings.
+	Response []byte
+	// Latency is how long each invocation waits before responding.
+	Latency time.Duration
+	// ChunkSize, if positive, splits the response into chunks of that many
+	// bytes, as a streaming agent would; otherwise it is sent as one chunk.
+	ChunkSize int
+	// Err, if set, is returned by every invocation after Latency.
+	Err error
+
+	mu          sync.Mutex
+	invocations []bedrockagentruntime.InvokeAgentInput
+}
+
+// New returns a Mock responding with response after latency.
+func New(response []byte, latency time.Duration) *Mock {
+	return &Mock{Response: response, Latency: latency}
+}
+
+// NewFromFile returns a Mock responding with the contents of the fixture at
+// path after latency, or with DefaultResponse when path is empty.
+func NewFromFile(path string, latency time.Duration) (*Mock, error) {
+	if path == "" {
+		return New([]byte(DefaultResponse), latency), nil
+	}
+	response, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mock response file: %w", err)
+	}
+	return New(response, latency), nil
+}
+
+// InvokeAgent records input and returns the response, calling onChunk, if
+// non-nil, with each chunk. It fails with the context's error if ctx is done
+// before Latency elapses.
+func (m *Mock) InvokeAgent(ctx context.Context, input *bedrockagentruntime.InvokeAgentInput, onChunk func([]byte)) (string, error) {
+	m.mu.Lock()
+	m.invocations = append(m.invocations, *input)
+	m.mu.Unlock()
+
+	timer := time.NewTimer(m.Latency)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-timer.C:
+	}
+	if m.Err != nil {
+		return "", m.Err
+	}
+
+	if onChunk != nil {
+		size := m.ChunkSize
+		if size <= 0 {
+			size = len(m.Response)
+		}
+		for start := 0; start < len(m.Response); start += size {
+			onChunk(m.Response[start:min(start+size, len(m.Response))])
+		}
+	}
+	return string(m.Response), nil
+}
+
+// Invocations returns the inputs of the invocations so far, in order.
+func (m *Mock) Invocations() []bedrockagentruntime.InvokeAgentInput {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]bedrockagentruntime.InvokeAgentInput(nil), m.invocations...)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// RequestTooLargeResponse defines the structure of the 413 JSON response.
+type RequestTooLargeResponse struct {
+	Error      string `json:"error"`
+	LimitBytes int64  `json:"limit_bytes"`
+}
+
+// writeRequestTooLarge writes a 413 response reporting the size limit.
+func writeRequestTooLarge(w http.ResponseWriter, limit int64) {
+	writeJSON(w, http.StatusRequestEntityTooLarge, RequestTooLargeResponse{
+		Error:      "Request body too large",
+		LimitBytes: limit,
+	})
+}
+
+// maxBytesMiddleware caps every request body at limit bytes. Requests that
+// declare a larger Content-Length are rejected before any of the body is read.
+func maxBytesMiddleware(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			writeRequestTooLarge(w, limit)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeJSONBody decodes the request body into v. It writes a 413 response
+// if the body exceeded the size limit, or a 400 response if it is not valid
+// JSON, and reports whether decoding succeeded.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeRequestTooLarge(w, tooLarge.Limit)
+		return false
+	}
+	writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+	return false
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+)
+
+// defaultLogRedactPatterns mask secrets in logged bodies. Unlike the
+// patterns applied to Terraform code, they also match quotes escaped by
+// the JSON encoding of a request.
+var defaultLogRedactPatterns = []SecretPattern{
+	{Name: "AWS access key", Pattern: `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`},
+	{Name: "AWS secret key", Pattern: `(?i)\b(?:aws_secret_access_key|secret_key)\s*=\s*\\?"([^"\\$][^"\\]*)\\?"`},
+	{Name: "private key", Pattern: `(-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----)`},
+	{Name: "password", Pattern: `(?i)\b\w*password\w*\s*=\s*\\?"([^"\\$][^"\\]*)\\?"`},
+	{Name: "API token", Pattern: `(?i)\b\w*(?:api_key|token|secret)\w*\s*=\s*\\?"([^"\\$][^"\\]*)\\?"`},
+}
+
+// logRedactionMargin is how much of a response beyond the logged limit is
+// kept, so a secret straddling the limit is still recognized and masked.
+const logRedactionMargin = 8192
+
+// bodyLogger logs request and response bodies with secrets masked.
+type bodyLogger struct {
+	scanner  *secretScanner
+	maxBytes int
+}
+
+// newBodyLogger returns a bodyLogger masking bodies with scanner and
+// truncating logged responses at maxBytes.
+func newBodyLogger(scanner *secretScanner, maxBytes int) *bodyLogger {
+	return &bodyLogger{scanner: scanner, maxBytes: maxBytes}
+}
+
+// bodyRecorder keeps the start of the response body written by a handler.
+type bodyRecorder struct {
+	http.ResponseWriter
+	body  bytes.Buffer
+	limit int
+	total int
+}
+
+// Write records up to limit bytes before delegating to the wrapped writer.
+func (rec *bodyRecorder) Write(p []byte) (int, error) {
+	if room := rec.limit - rec.body.Len(); room > 0 {
+		rec.body.Write(p[:min(room, len(p))])
+	}
+	rec.total += len(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (rec *bodyRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Hijack lets WebSocket upgrades take over the wrapped connection.
+func (rec *bodyRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(rec.ResponseWriter).Hijack()
+}
+
+// errorReader returns err once the body read before it is exhausted.
+type errorReader struct{ err error }
+
+// Read implements io.Reader.
+func (e errorReader) Read([]byte) (int, error) { return 0, e.err }
+
+// middleware logs the masked request body before calling next and the
+// masked, truncated response body after it.
+func (l *bodyLogger) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := loggerFromContext(r.Context())
+
+		// The body is read in full and replayed to next, including any read
+		// error so an oversized body is still rejected with a 413.
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errorReader{err}))
+		} else {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		if len(body) > 0 {
+			logger.Info("Request body", "method", r.Method, "path", r.URL.Path, "body", l.scanner.mask(string(body)))
+		}
+
+		rec := &bodyRecorder{ResponseWriter: w, limit: l.maxBytes + logRedactionMargin}
+		next.ServeHTTP(rec, r)
+
+		if rec.total == 0 {
+			return
+		}
+		logged := l.scanner.mask(rec.body.String())
+		truncated := rec.total > rec.body.Len() || len(logged) > l.maxBytes
+		if len(logged) > l.maxBytes {
+			logged = logged[:l.maxBytes]
+		}
+		logger.Info("Response body", "method", r.Method, "path", r.URL.Path, "body", logged, "body_bytes", rec.total, "truncated", truncated)
+	})
+}
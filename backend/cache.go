@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// cacheHeader reports whether a response was served from the analysis cache.
+const cacheHeader = "X-Cache"
+
+// cacheKey identifies an analysis by the code it was run on and the
+// compliance framework it was run against.
+func cacheKey(code, frameworkID string) string {
+	sum := sha256.Sum256([]byte(cleanCode(code) + frameworkID))
+	return hex.EncodeToString(sum[:])
+}
+
+// analysisCacheKey identifies the analysis of tf, parsed from source. The
+// variable values, workspace and suggestion count shape the prompt, so they
+// are part of the key.
+func (api *BedrockConverseAPI) analysisCacheKey(source string, tf *TerraformFile, frameworkID string) string {
+	return cacheKey(fmt.Sprintf("%s\x00%s\x00%s\x00%d", source, tf.VariableValues, tf.Workspace, api.maxSuggestions(tf)), frameworkID)
+}
+
+// analysisETag returns the entity tag of an analysis response, derived
+// from the agent's suggestion and the findings, which differ between
+// analyses without a suggestion, such as in offline mode, and in order
+// between sort_by values.
+func analysisETag(resp AnalyzeResponse) string {
+	h := sha256.New()
+	h.Write([]byte(resp.Suggestion))
+	h.Write([]byte{0})
+	_ = json.NewEncoder(h).Encode(resp.Findings)
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+// etagMatches reports whether the request's If-None-Match header lists
+// etag. Weak tags match too, since a response is only ever revalidated
+// against the analysis cache.
+func etagMatches(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheHandler handles the /cache endpoint. DELETE flushes every cached
+// analysis, for example after the knowledge base policies change.
+func (api *BedrockConverseAPI) cacheHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only DELETE method is allowed")
+		return
+	}
+
+	n := api.Cache.Len()
+	api.Cache.Purge()
+	loggerFromContext(r.Context()).Info("Analysis cache flushed", "entries", n)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+)
+
+// Exit codes of the check command.
+const (
+	checkExitClean    = 0
+	checkExitFindings = 1
+	checkExitError    = 2
+)
+
+// checkOutputs maps the check command's --output values to the formats the
+// analysis is negotiated in.
+var checkOutputs = map[string]string{
+	"json":  jsonContentType,
+	"text":  textContentType,
+	"sarif": sarifContentType,
+}
+
+// runCheck implements the check command, which analyzes a Terraform file
+// from the command line and writes the result to stdout. The file is
+// submitted to the /analyze handler in-process, so it goes through the same
+// pipeline as requests to the server. It returns the process exit code.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	file := flags.String("file", "", "Terraform file to analyze, or - to read standard input")
+	framework := flags.String("framework", "", "compliance framework ID, detected from the code when omitted")
+	maxSuggestions := flags.Int("max-suggestions", 0, "most suggestions the agent may give, MAX_SUGGESTIONS when omitted")
+	output := flags.String("output", "json", "output format: json, text or sarif")
+	if err := flags.Parse(args); err != nil {
+		return checkExitError
+	}
+	accept, ok := checkOutputs[*output]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "--output must be json, text or sarif, got %q\n", *output)
+		return checkExitError
+	}
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "--file is required")
+		flags.Usage()
+		return checkExitError
+	}
+
+	var code []byte
+	var err error
+	if *file == "-" {
+		code, err = io.ReadAll(os.Stdin)
+	} else {
+		code, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", *file, err)
+		return checkExitError
+	}
+
+	serverCfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return checkExitError
+	}
+	// Logs go to stderr so stdout holds only the result.
+	slog.SetDefault(newLogger(os.Stderr, serverCfg.LogLevel))
+
+	api, err := setupAnalysis(serverCfg)
+	if err != nil {
+		slog.Error("Failed to set up analysis", "error", err)
+		return checkExitError
+	}
+
+	body, err := json.Marshal(AnalyzeRequest{Code: string(code), Framework: *framework, MaxSuggestions: *maxSuggestions})
+	if err != nil {
+		slog.Error("Failed to encode analysis request", "error", err)
+		return checkExitError
+	}
+	r := httptest.NewRequest(http.MethodPost, "/analyze", bytes.NewReader(body))
+	r.Header.Set("Content-Type", jsonContentType)
+	r.Header.Set("Accept", accept)
+	rec := httptest.NewRecorder()
+	api.analyzeHandler(rec, r)
+
+	if rec.Code != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Analysis failed with status %d: %s\n", rec.Code, bytes.TrimSpace(rec.Body.Bytes()))
+		return checkExitError
+	}
+	if _, err := os.Stdout.Write(rec.Body.Bytes()); err != nil {
+		return checkExitError
+	}
+	if rec.Header().Get(findingCountHeader) != "0" {
+		return checkExitFindings
+	}
+	return checkExitClean
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// deadlineBuffer is how long before an analysis deadline the agent is cut
+// off, leaving time to return what it has answered so far.
+const deadlineBuffer = 2 * time.Second
+
+// truncatedDeadline is the reason given for a response cut short by the
+// analysis deadline.
+const truncatedDeadline = "deadline_exceeded"
+
+// errAnalysisDeadline is returned by agent invocations cut off by the
+// deadline of an analysis.
+var errAnalysisDeadline = errors.New("analysis deadline exceeded")
+
+// validateDeadline checks a requested deadline_seconds, which must leave
+// the agent time to answer before deadlineBuffer.
+func validateDeadline(seconds int) error {
+	if seconds < 0 || seconds > 0 && time.Duration(seconds)*time.Second <= deadlineBuffer {
+		return fmt.Errorf("deadline_seconds must be greater than %d, got %d; omit it to use the default", int(deadlineBuffer.Seconds()), seconds)
+	}
+	return nil
+}
+
+// analysisDeadline returns when an analysis starting now must return: after
+// the requested number of seconds or the configured default, or never.
+func (api *BedrockConverseAPI) analysisDeadline(seconds int) time.Time {
+	if seconds == 0 {
+		seconds = api.Config().DefaultDeadlineSeconds
+	}
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(seconds) * time.Second)
+}
+
+// agentAnalysis is the agent's review of a file, made over one invocation
+// per chunk when the file is too large for a single prompt.
+type agentAnalysis struct {
+	Suggestion string
+	Findings   []Finding
+	// Truncated reports that the agent found more issues than it could
+	// report for at least one chunk.
+	Truncated bool
+	Retries   int
+	Region    string
+	Chunks    int
+	// DeadlineExceeded reports that the analysis deadline cut the agent
+	// off, leaving Remaining, the resources it did not finish reviewing.
+	DeadlineExceeded bool
+	Remaining        []string
+	// PromptTokens is the estimated size of the prompts and Latency how
+	// long the agent took to answer them.
+	PromptTokens int
+	Latency      time.Duration
+	// DuplicatesRemoved is the number of findings dropped as repeats of
+	// another for the same resource type and rule.
+	DuplicatesRemoved int
+}
+
+// analysisPrompt is the prompt for one invocation of an analysis and the
+// resources it reviews.
+type analysisPrompt struct {
+	Text      string
+	Resources []string
+}
+
+// invokeAnalysis asks the agent to review tf against fw, passing the
+// response text to onChunk as it arrives if onChunk is not nil. Chunks are
+// analyzed one after another in the same session and their findings merged.
+// All chunks share one ANALYSIS_TIMEOUT_SECONDS deadline. When tf.Deadline
+// is set, the agent is cut off shortly before it and the findings answered
+// so far are returned.
+func (api *BedrockConverseAPI) invokeAnalysis(ctx context.Context, logger *slog.Logger, tf *TerraformFile, fw Framework, sessionID string, onChunk func([]byte)) (agentAnalysis, error) {
+	prompts, err := api.analysisPrompts(ctx, logger, tf, fw)
+	if err != nil {
+		return agentAnalysis{}, err
+	}
+
+	agentCtx, cancel := api.withAnalysisTimeout(ctx)
+	defer cancel()
+	if !tf.Deadline.IsZero() {
+		var cancelDeadline context.CancelFunc
+		agentCtx, cancelDeadline = context.WithDeadlineCause(agentCtx, tf.Deadline.Add(-deadlineBuffer), errAnalysisDeadline)
+		defer cancelDeadline()
+	}
+
+	analysis := agentAnalysis{Chunks: len(prompts)}
+	var suggestions []string
+	start := time.Now()
+	for i, prompt := range prompts {
+		analysis.PromptTokens += estimateTokens(prompt.Text)
+		if i > 0 && onChunk != nil {
+			onChunk([]byte("\n"))
+		}
+		result, err := api.invokeAnalysisAgent(agentCtx, logger, api.Config().agentFor(fw.ID), sessionID, prompt.Text, onChunk)
+		analysis.Retries += result.Retries
+		analysis.Region = result.Region
+		if errors.Is(err, errAnalysisDeadline) {
+			logger.Warn("Analysis deadline exceeded, returning partial results", "chunk", i+1, "chunks", len(prompts))
+			suggestions = append(suggestions, result.Suggestion)
+			analysis.Findings = append(analysis.Findings, parsePartialFindings(result.Suggestion)...)
+			analysis.DeadlineExceeded = true
+			for _, rest := range prompts[i:] {
+				analysis.Remaining = append(analysis.Remaining, rest.Resources...)
+			}
+			break
+		}
+		if err != nil {
+			return analysis, err
+		}
+		suggestions = append(suggestions, result.Suggestion)
+
+		findings, err := parseFindings(result.Suggestion)
+		if err != nil {
+			logger.Warn("Failed to parse agent response", "error", err, "chunk", i+1)
+			return analysis, fmt.Errorf("%w: %w", errUnparseableResponse, err)
+		}
+		findings, truncated := limitFindings(result.Suggestion, findings, api.maxSuggestions(tf))
+		analysis.Truncated = analysis.Truncated || truncated
+		analysis.Findings = append(analysis.Findings, findings...)
+	}
+	analysis.Latency = time.Since(start)
+	// The agent may word the same issue twice, and chunks share the file's
+	// providers and variables, so it may report an issue with them more
+	// than once.
+	analysis.Findings, analysis.DuplicatesRemoved = dedupeFindings(tf, analysis.Findings)
+	analysis.Suggestion = strings.Join(suggestions, "\n")
+	api.annotateFindings(fw.ID, analysis.Findings)
+	return analysis, nil
+}
+
+// analysisPrompts returns the prompts for analyzing tf: one for the whole
+// file or, when that would exceed MAX_PROMPT_TOKENS, one per chunk of its
+// resource blocks. Every chunk also carries the file's other blocks, such
+// as providers, variables and locals.
+func (api *BedrockConverseAPI) analysisPrompts(ctx context.Context, logger *slog.Logger, tf *TerraformFile, fw Framework) ([]analysisPrompt, error) {
+	prompt, err := api.buildAnalysisPrompt(ctx, tf.Source, tf.ResourceTypes(), tf, fw)
+	if err != nil {
+		return nil, err
+	}
+	whole := []analysisPrompt{{Text: prompt, Resources: tf.resourceAddresses()}}
+	tokens := estimateTokens(prompt)
+	if tokens <= api.Config().MaxPromptTokens {
+		return whole, nil
+	}
+
+	shared, resources, ok := splitResources(tf.Source)
+	if !ok || len(resources) < 2 {
+		logger.Warn("Prompt exceeds MAX_PROMPT_TOKENS and cannot be split", "estimated_tokens", tokens, "max_prompt_tokens", api.Config().MaxPromptTokens)
+		return whole, nil
+	}
+
+	// Each chunk may give as many suggestions as the whole file.
+	limit := api.maxSuggestions(tf)
+	empty := tf.chunk(shared, nil)
+	empty.MaxSuggestions = limit
+	overhead, err := api.buildAnalysisPrompt(ctx, empty.Source, nil, &empty, fw)
+	if err != nil {
+		return nil, err
+	}
+	budget := api.Config().MaxPromptTokens - estimateTokens(overhead)
+
+	var chunks [][]resourceSource
+	used := 0
+	for _, res := range resources {
+		// The code is cleaned up like the rest of the prompt, and the
+		// address is also listed among the declared blocks.
+		cost := estimateTokens(" "+cleanCode(res.Text)) + estimateTokens(", "+res.Address)
+		if len(chunks) == 0 || used+cost > budget {
+			chunks = append(chunks, nil)
+			used = 0
+		}
+		chunks[len(chunks)-1] = append(chunks[len(chunks)-1], res)
+		used += cost
+	}
+
+	prompts := make([]analysisPrompt, len(chunks))
+	for i, resources := range chunks {
+		chunk := tf.chunk(shared, resources)
+		chunk.MaxSuggestions = limit
+		if prompts[i].Text, err = api.buildAnalysisPrompt(ctx, chunk.Source, chunk.ResourceTypes(), &chunk, fw); err != nil {
+			return nil, err
+		}
+		prompts[i].Resources = chunk.resourceAddresses()
+	}
+	logger.Info("Prompt exceeds MAX_PROMPT_TOKENS, analyzing in chunks", "estimated_tokens", tokens, "max_prompt_tokens", api.Config().MaxPromptTokens, "chunks", len(chunks))
+	return prompts, nil
+}
+
+// resourceSource is the source text of one resource block.
+type resourceSource struct {
+	Address string
+	Text    string
+}
+
+// splitResources separates the resource blocks of source from the rest of
+// the file. It reports false if source does not parse.
+func splitResources(source string) (string, []resourceSource, bool) {
+	file, diags := hclsyntax.ParseConfig([]byte(source), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return "", nil, false
+	}
+
+	var shared strings.Builder
+	var resources []resourceSource
+	last := 0
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Type != "resource" || len(block.Labels) != 2 {
+			continue
+		}
+		r := block.Range()
+		shared.WriteString(source[last:r.Start.Byte])
+		resources = append(resources, resourceSource{
+			Address: block.Labels[0] + "." + block.Labels[1],
+			Text:    source[r.Start.Byte:r.End.Byte],
+		})
+		last = r.End.Byte
+	}
+	shared.WriteString(source[last:])
+	return strings.TrimSpace(shared.String()), resources, true
+}
+
+// resourceAddresses returns the addresses of the resources in tf.
+func (tf *TerraformFile) resourceAddresses() []string {
+	addresses := make([]string, len(tf.Resources))
+	for i, b := range tf.Resources {
+		addresses[i] = b.Address()
+	}
+	return addresses
+}
+
+// chunk returns a copy of tf holding only the given resources, whose code
+// is the shared source followed by theirs.
+func (tf *TerraformFile) chunk(shared string, resources []resourceSource) TerraformFile {
+	texts := []string{shared}
+	addresses := make([]string, len(resources))
+	for i, res := range resources {
+		texts = append(texts, res.Text)
+		addresses[i] = res.Address
+	}
+
+	chunk := *tf
+	chunk.Source = strings.Join(texts, "\n\n")
+	chunk.Resources = slices.DeleteFunc(slices.Clone(tf.Resources), func(b TerraformBlock) bool {
+		return !slices.Contains(addresses, b.Address())
+	})
+	chunk.SecurityGroupRules = slices.DeleteFunc(slices.Clone(tf.SecurityGroupRules), func(r SecurityGroupRule) bool {
+		return !slices.Contains(addresses, r.Resource)
+	})
+	chunk.LambdaFunctions = slices.DeleteFunc(slices.Clone(tf.LambdaFunctions), func(f LambdaFunction) bool {
+		return !slices.Contains(addresses, f.Resource)
+	})
+	return chunk
+}
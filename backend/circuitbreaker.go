@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// errCircuitOpen is returned instead of invoking Bedrock while the circuit
+// breaker is open.
+var errCircuitOpen = errors.New("bedrock circuit breaker is open")
+
+// circuitState is the state of a CircuitBreaker.
+type circuitState int
+
+// Circuit breaker states.
+const (
+	// CircuitClosed lets every call through.
+	CircuitClosed circuitState = iota
+	// CircuitOpen rejects every call until the reset timeout has passed.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe calls through; the first to
+	// succeed closes the circuit and any failure opens it again.
+	CircuitHalfOpen
+)
+
+// String returns the state name reported by /health.
+func (s circuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreaker stops calls to Bedrock after repeated failures so that
+// requests fail fast while it is degraded rather than each waiting out the
+// analysis timeout.
+type CircuitBreaker struct {
+	threshold      int
+	resetTimeout   time.Duration
+	halfOpenProbes int
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+// newCircuitBreaker returns a closed breaker that opens after threshold
+// consecutive failures and allows probes calls through once resetTimeout
+// has passed.
+func newCircuitBreaker(threshold int, resetTimeout time.Duration, probes int) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, resetTimeout: resetTimeout, halfOpenProbes: probes}
+}
+
+// State returns the breaker's current state.
+func (cb *CircuitBreaker) State() circuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.resetTimeout {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// allow reports whether a call may proceed. Every allowed call must be
+// followed by a call to record with its outcome.
+func (cb *CircuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitOpen {
+		if time.Since(cb.openedAt) < cb.resetTimeout {
+			return false
+		}
+		cb.state, cb.probes = CircuitHalfOpen, 0
+		slog.Info("Bedrock circuit breaker half-open, probing")
+	}
+	if cb.state == CircuitHalfOpen {
+		if cb.probes >= cb.halfOpenProbes {
+			return false
+		}
+		cb.probes++
+	}
+	return true
+}
+
+// record updates the breaker with the outcome of an allowed call. Calls the
+// client cancelled say nothing about Bedrock's health and are not counted.
+func (cb *CircuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, errAnalysisDeadline):
+		if cb.state == CircuitHalfOpen {
+			cb.probes--
+		}
+	case err != nil && (errors.Is(err, context.DeadlineExceeded) || isRegionalFailure(err)):
+		cb.failures++
+		if cb.state == CircuitHalfOpen || cb.failures >= cb.threshold {
+			if cb.state != CircuitOpen {
+				slog.Warn("Bedrock circuit breaker opened", "consecutive_failures", cb.failures, "reset_timeout_seconds", cb.resetTimeout.Seconds())
+			}
+			cb.state, cb.openedAt = CircuitOpen, time.Now()
+		}
+	default:
+		// Bedrock answered, even if only to reject the request.
+		if cb.state != CircuitClosed {
+			slog.Info("Bedrock circuit breaker closed")
+		}
+		cb.state, cb.failures = CircuitClosed, 0
+	}
+}
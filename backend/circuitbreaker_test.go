@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	const resetTimeout = time.Minute
+	// elapse is a step that lets the reset timeout pass.
+	elapse := errors.New("elapse")
+
+	tests := []struct {
+		name string
+		// steps are the outcomes of successive allowed calls; elapse instead
+		// moves the breaker past its reset timeout.
+		steps []error
+		want  circuitState
+		allow bool
+	}{
+		{"new breaker is closed", nil, CircuitClosed, true},
+		{"failures below the threshold stay closed", []error{context.DeadlineExceeded, context.DeadlineExceeded}, CircuitClosed, true},
+		{"a success resets the failure count", []error{context.DeadlineExceeded, context.DeadlineExceeded, nil, context.DeadlineExceeded}, CircuitClosed, true},
+		{"failures reaching the threshold open it", []error{context.DeadlineExceeded, context.DeadlineExceeded, context.DeadlineExceeded}, CircuitOpen, false},
+		{"client cancellations are not counted", []error{context.Canceled, context.Canceled, context.Canceled}, CircuitClosed, true},
+		{"rejected requests count as answers", []error{context.DeadlineExceeded, context.DeadlineExceeded, errors.New("validation failed"), context.DeadlineExceeded}, CircuitClosed, true},
+		{"open breaker half-opens after the reset timeout", []error{context.DeadlineExceeded, context.DeadlineExceeded, context.DeadlineExceeded, elapse}, CircuitHalfOpen, true},
+		{"successful probe closes it", []error{context.DeadlineExceeded, context.DeadlineExceeded, context.DeadlineExceeded, elapse, nil}, CircuitClosed, true},
+		{"failed probe opens it again", []error{context.DeadlineExceeded, context.DeadlineExceeded, context.DeadlineExceeded, elapse, context.DeadlineExceeded}, CircuitOpen, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := newCircuitBreaker(3, resetTimeout, 1)
+			for i, err := range tt.steps {
+				if err == elapse {
+					cb.openedAt = cb.openedAt.Add(-resetTimeout)
+					continue
+				}
+				if !cb.allow() {
+					t.Fatalf("step %d: call rejected", i)
+				}
+				cb.record(err)
+			}
+			if got := cb.State(); got != tt.want {
+				t.Errorf("State() = %s, want %s", got, tt.want)
+			}
+			if got := cb.allow(); got != tt.allow {
+				t.Errorf("allow() = %t, want %t", got, tt.allow)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbes(t *testing.T) {
+	cb := newCircuitBreaker(1, time.Minute, 2)
+	cb.allow()
+	cb.record(context.DeadlineExceeded)
+	cb.openedAt = cb.openedAt.Add(-time.Minute)
+
+	for i, want := range []bool{true, true, false} {
+		if got := cb.allow(); got != want {
+			t.Errorf("probe %d: allow() = %t, want %t", i+1, got, want)
+		}
+	}
+	// A cancelled probe frees its slot for another.
+	cb.record(context.Canceled)
+	if !cb.allow() {
+		t.Error("allow() after a cancelled probe = false, want true")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// cisControlPattern matches a CIS Benchmark control number, such as 2.1.1.
+var cisControlPattern = regexp.MustCompile(`\d+(?:\.\d+)*`)
+
+// cisLevelPattern matches the profile number in a benchmark level such as
+// "Level 1" or "L2".
+var cisLevelPattern = regexp.MustCompile(`^(?i:level|l)?\s*([12])$`)
+
+// tagCISControl normalizes the CIS control of f to the form "CIS 2.1.1",
+// taking it from the rule ID, such as CIS.2.1.1, when the agent gave none,
+// and its benchmark level to "Level 1" or "Level 2". Values that are
+// neither are dropped.
+func tagCISControl(f *Finding) {
+	source := f.CISControl
+	if source == "" && strings.HasPrefix(f.RuleID, "CIS.") {
+		source = f.RuleID
+	}
+	f.CISControl = ""
+	if number := cisControlPattern.FindString(source); number != "" {
+		f.CISControl = "CIS " + number
+	}
+
+	m := cisLevelPattern.FindStringSubmatch(strings.TrimSpace(f.BenchmarkLevel))
+	f.BenchmarkLevel = ""
+	if m != nil {
+		f.BenchmarkLevel = "Level " + m[1]
+	}
+}
+
+// publicAccessBlockSettings must all be true for S3 Block Public Access to
+// be enabled. Each defaults to false.
+var publicAccessBlockSettings = []string{"block_public_acls", "block_public_policy", "ignore_public_acls", "restrict_public_buckets"}
+
+// cisAWSAnalyzer checks the most common CIS AWS Foundations Benchmark
+// controls locally when analyzing against cis-aws, so results appear
+// before the agent answers: CloudTrail enabled in all regions (3.1) and
+// S3 Block Public Access (2.1.4). Root account MFA (1.5) is configured
+// outside Terraform and is left to the agent.
+type cisAWSAnalyzer struct{}
+
+// Analyze implements Analyzer.
+func (cisAWSAnalyzer) Analyze(ctx context.Context, tf TerraformFile) ([]Finding, error) {
+	if req, ok := analysisRequestFromContext(ctx); !ok || req.Framework.ID != "cis-aws" {
+		return nil, nil
+	}
+
+	var findings []Finding
+	blocked := tf.bucketsWith("aws_s3_bucket_public_access_block")
+	accountBlocked := false
+	for _, b := range tf.Resources {
+		if b.Type == "aws_s3_account_public_access_block" && b.Body != nil && len(disabledSettings(b, publicAccessBlockSettings)) == 0 {
+			accountBlocked = true
+		}
+	}
+
+	for _, b := range tf.Resources {
+		if b.Body == nil {
+			continue
+		}
+		switch b.Type {
+		case "aws_cloudtrail":
+			if problems := disabledSettings(b, []string{"is_multi_region_trail"}); len(problems) > 0 {
+				findings = append(findings, cisFinding(b, "3.1", "Level 1", SeverityHigh,
+					fmt.Sprintf("%s records only its home region (%s); CloudTrail must be enabled in all regions", b, problems[0]),
+					"is_multi_region_trail = true"))
+			}
+			if v, _, ok := literalValue(b, "enable_logging"); ok && v.Type() == cty.Bool && v.False() {
+				findings = append(findings, cisFinding(b, "3.1", "Level 1", SeverityHigh,
+					fmt.Sprintf("%s has logging disabled (enable_logging = false)", b),
+					"enable_logging = true"))
+			}
+		case "aws_s3_bucket_public_access_block", "aws_s3_account_public_access_block":
+			if problems := disabledSettings(b, publicAccessBlockSettings); len(problems) > 0 {
+				findings = append(findings, cisFinding(b, "2.1.4", "Level 1", SeverityHigh,
+					fmt.Sprintf("%s does not fully enable Block Public Access (%s)", b, strings.Join(problems, ", ")),
+					strings.Join(publicAccessBlockSettings, " = true\n")+" = true"))
+			}
+		case "aws_s3_bucket":
+			if accountBlocked || b.configuredBy(blocked) {
+				continue
+			}
+			findings = append(findings, cisFinding(b, "2.1.4", "Level 1", SeverityHigh,
+				fmt.Sprintf("%s has no aws_s3_bucket_public_access_block and the account-level block is not configured", b),
+				fmt.Sprintf(`resource "aws_s3_bucket_public_access_block" %q {
+  bucket = %s.id
+
+  block_public_acls       = true
+  block_public_policy     = true
+  ignore_public_acls      = true
+  restrict_public_buckets = true
+}`, b.Name, b.Address())))
+		}
+	}
+	return findings, nil
+}
+
+// disabledSettings describes each of the boolean attributes of b that is
+// false or unset.
+func disabledSettings(b TerraformBlock, attrs []string) []string {
+	var problems []string
+	for _, attr := range attrs {
+		if v, _, ok := literalValue(b, attr); ok && v.Type() == cty.Bool && v.False() {
+			problems = append(problems, attr+" = false")
+		} else if _, set := b.Body.Attributes[attr]; !set {
+			problems = append(problems, attr+" is not set")
+		}
+	}
+	return problems
+}
+
+// cisFinding builds a finding for a CIS AWS Foundations Benchmark control.
+func cisFinding(b TerraformBlock, control, level, severity, description, remediation string) Finding {
+	return Finding{
+		Severity:        severity,
+		ResourceType:    b.Type,
+		RuleID:          "CIS." + control,
+		Description:     description,
+		RemediationCode: remediation,
+		CISControl:      "CIS " + control,
+		BenchmarkLevel:  level,
+	}
+}
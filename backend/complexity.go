@@ -0,0 +1,45 @@
+package main
+
+import "time"
+
+// AnalysisMetrics describes the size of an analyzed configuration and the
+// cost of analyzing it, so clients can warn about files that are expensive
+// to review.
+type AnalysisMetrics struct {
+	ResourceCount       int `json:"resource_count"`
+	DataSourceCount     int `json:"data_source_count"`
+	ModuleCallCount     int `json:"module_call_count"`
+	UniqueResourceTypes int `json:"unique_resource_types"`
+	// EstimatedPromptTokens and BedrockLatencyMs are zero when the agent
+	// was not invoked.
+	EstimatedPromptTokens int   `json:"estimated_prompt_tokens"`
+	BedrockLatencyMs      int64 `json:"bedrock_latency_ms"`
+	CacheHit              bool  `json:"cache_hit"`
+	// DuplicatesRemoved is the number of findings dropped as repeats of
+	// another for the same resource type and rule.
+	DuplicatesRemoved int `json:"duplicates_removed"`
+}
+
+// analysisMetrics returns the size metrics of tf.
+func (tf *TerraformFile) analysisMetrics() AnalysisMetrics {
+	return AnalysisMetrics{
+		ResourceCount:       len(tf.Resources),
+		DataSourceCount:     len(tf.DataSources),
+		ModuleCallCount:     len(tf.Modules),
+		UniqueResourceTypes: len(tf.ResourceTypes()),
+	}
+}
+
+// recordAgent records the prompt size and latency of the agent's analysis.
+func (m *AnalysisMetrics) recordAgent(promptTokens int, latency time.Duration) {
+	m.EstimatedPromptTokens = promptTokens
+	m.BedrockLatencyMs = latency.Milliseconds()
+}
+
+// fromCache returns m for a response served from the cache, which did not
+// wait on the agent.
+func (m AnalysisMetrics) fromCache() AnalysisMetrics {
+	m.CacheHit = true
+	m.BedrockLatencyMs = 0
+	return m
+}
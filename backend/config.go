@@ -0,0 +1,494 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AgentConfig identifies the Bedrock agent alias that analyzes a framework.
+// InvokeAgent has no output limit of its own, so a non-zero MaxTokens is
+// passed to the agent as the max_tokens prompt session attribute for its
+// prompt templates to apply.
+type AgentConfig struct {
+	AgentID      string `json:"agent_id"`
+	AgentAliasID string `json:"agent_alias_id"`
+	MaxTokens    int    `json:"max_tokens,omitempty"`
+}
+
+// ServerConfig holds the runtime settings read from the environment at
+// startup. Some of them can be changed later by reloading the configuration.
+type ServerConfig struct {
+	AgentID         string
+	AgentAliasID    string
+	AWSRegion       string
+	BedrockRegions  []string
+	ListenPort      string
+	MaxSuggestions  int
+	LogLevel        slog.Level
+	AnalysisTimeout time.Duration
+	AllowedOrigins  []string
+	MaxRetries      int
+	ShutdownGrace   time.Duration
+	MaxRequestBytes int64
+
+	// ModelID names the model behind the agents, and InputPricePer1K and
+	// OutputPricePer1K its price in USD per thousand tokens, for /estimate.
+	ModelID          string
+	InputPricePer1K  float64
+	OutputPricePer1K float64
+
+	// MaxPromptTokens is the largest prompt sent in one agent invocation;
+	// larger files are analyzed in chunks of resource blocks.
+	MaxPromptTokens int
+
+	// OfflineMode disables Bedrock, leaving analysis to the local analyzers.
+	OfflineMode bool
+
+	// MockBedrock replaces the Bedrock agent with a mock, for integration
+	// tests without AWS credentials. It responds with the fixture in
+	// MockResponseFile, or an empty findings array, after MockLatency.
+	MockBedrock      bool
+	MockResponseFile string
+	MockLatency      time.Duration
+
+	// Agents routes frameworks to their own agents, keyed by framework ID.
+	// Frameworks without an entry use AgentID and AgentAliasID.
+	Agents map[string]AgentConfig
+
+	// WorkspaceRules adjusts the analysis per Terraform workspace, keyed by
+	// workspace name.
+	WorkspaceRules map[string]WorkspaceRules
+
+	BatchMaxFiles    int
+	BatchMaxBytes    int
+	BatchConcurrency int
+
+	// MaxZipSize caps a ZIP upload to /analyze and MaxFilesInZip the
+	// entries it may hold. Extracted files share the BatchMaxBytes budget.
+	MaxZipSize    int
+	MaxFilesInZip int
+
+	CacheSize int
+	CacheTTL  time.Duration
+
+	RateLimitRPS   float64
+	RateLimitBurst int
+
+	JobTTL time.Duration
+
+	// SessionTTL is how long a workspace keeps resuming its agent session.
+	SessionTTL time.Duration
+
+	// DefaultDeadlineSeconds is the deadline_seconds of analyses that do not
+	// set one, or 0 for no deadline.
+	DefaultDeadlineSeconds int
+
+	// StreamRetryCount is how many times an analysis whose response stream
+	// ended before its JSON array was complete asks the agent to continue.
+	StreamRetryCount int
+
+	// SecretPatternsFile optionally replaces the built-in secret patterns.
+	SecretPatternsFile string
+
+	// PromptInjectionPatternsFile optionally replaces the built-in prompt
+	// injection patterns.
+	PromptInjectionPatternsFile string
+
+	// LogRequestBodies logs request and response bodies, masked with the
+	// patterns in LogRedactPatternsFile or the built-in ones. Response
+	// bodies are truncated at LogMaxBodyBytes.
+	LogRequestBodies      bool
+	LogRedactPatternsFile string
+	LogMaxBodyBytes       int
+
+	// PromptTemplateFile optionally replaces the built-in analysis prompt
+	// template; OrgName is available to it as {{.OrgName}}.
+	PromptTemplateFile string
+	OrgName            string
+
+	// APIKeys are the bearer tokens accepted by the server. Authentication
+	// is disabled when it is empty.
+	APIKeys []string
+
+	// AdminAPIKeys are the bearer tokens accepted by the /admin endpoints,
+	// which are disabled when it is empty.
+	AdminAPIKeys []string
+
+	// OTLPEndpoint is the OTLP/HTTP collector URL traces are exported to.
+	// Tracing is disabled when it is empty.
+	OTLPEndpoint string
+
+	// TLS is served on ListenPort from TLSCertFile and TLSKeyFile, or from
+	// certificates for ACMEDomain obtained through Let's Encrypt. Plaintext
+	// requests on HTTPPort are then redirected to HTTPS.
+	TLSCertFile  string
+	TLSKeyFile   string
+	ACMEDomain   string
+	ACMECacheDir string
+	HTTPPort     string
+
+	// The circuit breaker opens after CircuitFailureThreshold consecutive
+	// Bedrock failures and lets CircuitHalfOpenProbes calls through once
+	// CircuitResetTimeout has passed.
+	CircuitFailureThreshold int
+	CircuitResetTimeout     time.Duration
+	CircuitHalfOpenProbes   int
+
+	// AllowModuleFetch lets the backend query the public Terraform Registry:
+	// analysis downloads registry modules' source so the resources they
+	// create are analyzed too, and /analyze/providers can look up the latest
+	// provider releases. It is off by default for air-gapped environments.
+	AllowModuleFetch bool
+
+	// DatabasePath is the SQLite file analysis history is stored in.
+	// History is disabled when it is empty.
+	DatabasePath string
+
+	// PluginDir is scanned for Go plugins providing extra analyzers.
+	PluginDir string
+
+	// WebhookSecret signs the results POSTed to async jobs' callback URLs.
+	// Callbacks are refused when it is empty.
+	WebhookSecret string
+}
+
+// loadConfig builds a ServerConfig from environment variables. The Bedrock
+// agent identifiers are required; every other setting has a default.
+func loadConfig() (*ServerConfig, error) {
+	cfg := &ServerConfig{
+		AgentID:        os.Getenv("BEDROCK_AGENT_ID"),
+		AgentAliasID:   os.Getenv("BEDROCK_AGENT_ALIAS_ID"),
+		AWSRegion:      envString("AWS_REGION", "us-east-1"),
+		ListenPort:     envString("LISTEN_PORT", "3000"),
+		AllowedOrigins: envList("CORS_ALLOWED_ORIGINS", []string{"*"}),
+
+		SecretPatternsFile: os.Getenv("SECRET_PATTERNS_FILE"),
+		PromptTemplateFile: os.Getenv("PROMPT_TEMPLATE_FILE"),
+		OrgName:            os.Getenv("ORG_NAME"),
+		ModelID:            envString("BEDROCK_MODEL_ID", "anthropic.claude-3-sonnet"),
+		APIKeys:            envList("API_KEYS", nil),
+		AdminAPIKeys:       envList("ADMIN_API_KEYS", nil),
+		OTLPEndpoint:       os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		PluginDir:          os.Getenv("PLUGIN_DIR"),
+		DatabasePath:       os.Getenv("DATABASE_PATH"),
+
+		TLSCertFile:  os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:   os.Getenv("TLS_KEY_FILE"),
+		ACMEDomain:   os.Getenv("ACME_DOMAIN"),
+		ACMECacheDir: envString("ACME_CACHE_DIR", "acme-cache"),
+		HTTPPort:     envString("HTTP_PORT", "3000"),
+	}
+
+	var err error
+	if cfg.OfflineMode, err = envBool("OFFLINE_MODE", false); err != nil {
+		return nil, err
+	}
+
+	if cfg.MockBedrock, err = envBool("MOCK_BEDROCK", false); err != nil {
+		return nil, err
+	}
+	if cfg.MockBedrock && cfg.OfflineMode {
+		return nil, errors.New("MOCK_BEDROCK and OFFLINE_MODE cannot both be set")
+	}
+	cfg.MockResponseFile = os.Getenv("MOCK_RESPONSE_FILE")
+	mockLatencyMS, err := envInt("MOCK_LATENCY_MS", 0)
+	if err != nil {
+		return nil, err
+	}
+	if mockLatencyMS < 0 {
+		return nil, fmt.Errorf("MOCK_LATENCY_MS must not be negative, got %d", mockLatencyMS)
+	}
+	cfg.MockLatency = time.Duration(mockLatencyMS) * time.Millisecond
+
+	// The real agent is not invoked in offline or mock mode, so it need not
+	// be configured.
+	var missing []string
+	if cfg.AgentID == "" && !cfg.OfflineMode && !cfg.MockBedrock {
+		missing = append(missing, "BEDROCK_AGENT_ID")
+	}
+	if cfg.AgentAliasID == "" && !cfg.OfflineMode && !cfg.MockBedrock {
+		missing = append(missing, "BEDROCK_AGENT_ALIAS_ID")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required environment variables: %s", strings.Join(missing, ", "))
+	}
+
+	// Bedrock is tried in each region in turn, starting with AWS_REGION by default.
+	cfg.BedrockRegions = envList("BEDROCK_REGIONS", []string{cfg.AWSRegion})
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.TLSCertFile != "" && cfg.ACMEDomain != "" {
+		return nil, errors.New("TLS_CERT_FILE and ACME_DOMAIN cannot both be set")
+	}
+	if cfg.tlsEnabled() {
+		// HTTP_PORT keeps the plaintext default, so HTTPS moves off it.
+		cfg.ListenPort = envString("LISTEN_PORT", "3443")
+		if cfg.ListenPort == cfg.HTTPPort {
+			return nil, fmt.Errorf("LISTEN_PORT and HTTP_PORT must differ when TLS is enabled, both are %s", cfg.ListenPort)
+		}
+	}
+
+	if cfg.Agents, err = loadAgentConfigs(os.Getenv("FRAMEWORK_AGENTS_FILE")); err != nil {
+		return nil, err
+	}
+	if cfg.WorkspaceRules, err = loadWorkspaceRules(os.Getenv("WORKSPACE_RULES_FILE")); err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxSuggestions, err = envPositiveInt("MAX_SUGGESTIONS", 2); err != nil {
+		return nil, err
+	}
+	if err := validateMaxSuggestions(cfg.MaxSuggestions); err != nil {
+		return nil, fmt.Errorf("MAX_SUGGESTIONS: %w", err)
+	}
+	if cfg.AnalysisTimeout, err = envSeconds("ANALYSIS_TIMEOUT_SECONDS", 30); err != nil {
+		return nil, err
+	}
+	if cfg.DefaultDeadlineSeconds, err = envInt("DEFAULT_DEADLINE_SECONDS", 0); err != nil {
+		return nil, err
+	}
+	if err := validateDeadline(cfg.DefaultDeadlineSeconds); err != nil {
+		return nil, fmt.Errorf("DEFAULT_DEADLINE_SECONDS: %w", err)
+	}
+	if cfg.MaxRetries, err = envInt("BEDROCK_MAX_RETRIES", 3); err != nil {
+		return nil, err
+	}
+	if cfg.MaxRetries < 0 {
+		return nil, fmt.Errorf("BEDROCK_MAX_RETRIES must not be negative, got %d", cfg.MaxRetries)
+	}
+	if cfg.StreamRetryCount, err = envInt("STREAM_RETRY_COUNT", 2); err != nil {
+		return nil, err
+	}
+	if cfg.StreamRetryCount < 0 {
+		return nil, fmt.Errorf("STREAM_RETRY_COUNT must not be negative, got %d", cfg.StreamRetryCount)
+	}
+	if cfg.ShutdownGrace, err = envSeconds("SHUTDOWN_GRACE_SECONDS", 15); err != nil {
+		return nil, err
+	}
+	maxRequestBytes, err := envPositiveInt("MAX_REQUEST_BYTES", 512<<10)
+	if err != nil {
+		return nil, err
+	}
+	cfg.MaxRequestBytes = int64(maxRequestBytes)
+
+	if cfg.BatchMaxFiles, err = envPositiveInt("BATCH_MAX_FILES", 20); err != nil {
+		return nil, err
+	}
+	if cfg.BatchMaxBytes, err = envPositiveInt("BATCH_MAX_BYTES", 256<<10); err != nil {
+		return nil, err
+	}
+	if cfg.BatchConcurrency, err = envPositiveInt("BATCH_CONCURRENCY", 3); err != nil {
+		return nil, err
+	}
+	if cfg.MaxZipSize, err = envPositiveInt("MAX_ZIP_SIZE", 256<<10); err != nil {
+		return nil, err
+	}
+	if int64(cfg.MaxZipSize) > cfg.MaxRequestBytes {
+		return nil, fmt.Errorf("MAX_ZIP_SIZE must not exceed MAX_REQUEST_BYTES (%d), got %d", cfg.MaxRequestBytes, cfg.MaxZipSize)
+	}
+	if cfg.MaxFilesInZip, err = envPositiveInt("MAX_FILES_IN_ZIP", 100); err != nil {
+		return nil, err
+	}
+	if cfg.CacheSize, err = envPositiveInt("CACHE_SIZE", 100); err != nil {
+		return nil, err
+	}
+	if cfg.CacheTTL, err = envSeconds("CACHE_TTL_SECONDS", 300); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimitRPS, err = envFloat("RATE_LIMIT_RPS", 2); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimitRPS <= 0 {
+		return nil, fmt.Errorf("RATE_LIMIT_RPS must be positive, got %g", cfg.RateLimitRPS)
+	}
+	if cfg.RateLimitBurst, err = envPositiveInt("RATE_LIMIT_BURST", 5); err != nil {
+		return nil, err
+	}
+
+	if cfg.JobTTL, err = envMinutes("JOB_TTL_MINUTES", 10); err != nil {
+		return nil, err
+	}
+	if cfg.SessionTTL, err = envMinutes("SESSION_TTL_MINUTES", 30); err != nil {
+		return nil, err
+	}
+	if cfg.MaxPromptTokens, err = envPositiveInt("MAX_PROMPT_TOKENS", 90000); err != nil {
+		return nil, err
+	}
+	if cfg.InputPricePer1K, err = envFloat("BEDROCK_INPUT_PRICE_PER_1K", 0.003); err != nil {
+		return nil, err
+	}
+	if cfg.OutputPricePer1K, err = envFloat("BEDROCK_OUTPUT_PRICE_PER_1K", 0.015); err != nil {
+		return nil, err
+	}
+	if cfg.InputPricePer1K < 0 || cfg.OutputPricePer1K < 0 {
+		return nil, errors.New("BEDROCK_INPUT_PRICE_PER_1K and BEDROCK_OUTPUT_PRICE_PER_1K must not be negative")
+	}
+	if cfg.CircuitFailureThreshold, err = envPositiveInt("CB_FAILURE_THRESHOLD", 5); err != nil {
+		return nil, err
+	}
+	if cfg.CircuitResetTimeout, err = envSeconds("CB_RESET_TIMEOUT_SECONDS", 60); err != nil {
+		return nil, err
+	}
+	if cfg.CircuitHalfOpenProbes, err = envPositiveInt("CB_HALF_OPEN_PROBES", 1); err != nil {
+		return nil, err
+	}
+	if cfg.AllowModuleFetch, err = envBool("ALLOW_MODULE_FETCH", false); err != nil {
+		return nil, err
+	}
+	if cfg.LogRequestBodies, err = envBool("LOG_REQUEST_BODIES", false); err != nil {
+		return nil, err
+	}
+	cfg.LogRedactPatternsFile = os.Getenv("LOG_REDACT_PATTERNS_FILE")
+	cfg.WebhookSecret = os.Getenv("WEBHOOK_SECRET")
+	cfg.PromptInjectionPatternsFile = os.Getenv("PROMPT_INJECTION_PATTERNS_FILE")
+	if cfg.LogMaxBodyBytes, err = envPositiveInt("LOG_MAX_BODY_BYTES", 4096); err != nil {
+		return nil, err
+	}
+
+	if cfg.LogLevel, err = parseLogLevel(envString("LOG_LEVEL", "INFO")); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadAgentConfigs reads the per-framework agents from the JSON file at
+// path, an object keyed by framework ID. An empty path configures none.
+func loadAgentConfigs(path string) (map[string]AgentConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read framework agents: %w", err)
+	}
+	var agents map[string]AgentConfig
+	if err := json.Unmarshal(data, &agents); err != nil {
+		return nil, fmt.Errorf("failed to decode framework agents: %w", err)
+	}
+
+	for id, agent := range agents {
+		if _, err := lookupFramework(id); err != nil || id == "" {
+			return nil, fmt.Errorf("framework agents: unknown framework %q", id)
+		}
+		if agent.AgentID == "" || agent.AgentAliasID == "" {
+			return nil, fmt.Errorf("framework agents: %s needs both agent_id and agent_alias_id", id)
+		}
+		if agent.MaxTokens < 0 {
+			return nil, fmt.Errorf("framework agents: %s max_tokens must not be negative, got %d", id, agent.MaxTokens)
+		}
+	}
+	return agents, nil
+}
+
+// agentFor returns the agent that analyzes the framework with the given ID,
+// falling back to the default agent.
+func (cfg *ServerConfig) agentFor(frameworkID string) AgentConfig {
+	if agent, ok := cfg.Agents[frameworkID]; ok {
+		return agent
+	}
+	return AgentConfig{AgentID: cfg.AgentID, AgentAliasID: cfg.AgentAliasID}
+}
+
+// envString returns the value of the environment variable key, or def when it is unset or empty.
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envList splits the comma-separated environment variable key into trimmed,
+// non-empty values, or returns def when it is unset or empty.
+func envList(key string, def []string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return def
+	}
+	return values
+}
+
+// envInt parses the environment variable key as an integer, or returns def when it is unset or empty.
+func envInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q for %s: %w", v, key, err)
+	}
+	return n, nil
+}
+
+// envFloat parses the environment variable key as a float, or returns def when it is unset or empty.
+func envFloat(key string, def float64) (float64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q for %s: %w", v, key, err)
+	}
+	return f, nil
+}
+
+// envBool parses the environment variable key as a boolean, or returns def when it is unset or empty.
+func envBool(key string, def bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid value %q for %s: %w", v, key, err)
+	}
+	return b, nil
+}
+
+// envPositiveInt is like envInt but rejects values below 1.
+func envPositiveInt(key string, def int) (int, error) {
+	n, err := envInt(key, def)
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("%s must be at least 1, got %d", key, n)
+	}
+	return n, nil
+}
+
+// envSeconds reads the environment variable key as a positive number of seconds.
+func envSeconds(key string, def int) (time.Duration, error) {
+	n, err := envPositiveInt(key, def)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+// envMinutes reads a positive number of minutes from the environment variable key.
+func envMinutes(key string, def int) (time.Duration, error) {
+	n, err := envPositiveInt(key, def)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(n) * time.Minute, nil
+}
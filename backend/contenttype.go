@@ -0,0 +1,51 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+)
+
+// jsonContentType is the media type POST endpoints accept.
+const jsonContentType = "application/json"
+
+// UnsupportedMediaTypeResponse defines the structure of the 415 JSON response.
+type UnsupportedMediaTypeResponse struct {
+	Error    string `json:"error"`
+	Expected string `json:"expected"`
+}
+
+// contentTypeExemptPaths are the endpoints whose POST bodies are not JSON or
+// that take no body. /analyze also accepts multipart ZIP uploads, which are
+// allowed separately.
+var contentTypeExemptPaths = []string{"/ws", "/admin/reload"}
+
+// jsonContentTypeMiddleware rejects POST requests whose Content-Type is not
+// application/json with a 415, rather than letting them fail JSON decoding.
+func jsonContentTypeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !requiresJSONBody(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != jsonContentType {
+			writeJSON(w, http.StatusUnsupportedMediaType, UnsupportedMediaTypeResponse{
+				Error:    "content_type_required",
+				Expected: jsonContentType,
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requiresJSONBody reports whether a POST request to r's path must carry a
+// JSON body.
+func requiresJSONBody(r *http.Request) bool {
+	if slices.Contains(contentTypeExemptPaths, r.URL.Path) {
+		return false
+	}
+	return r.URL.Path != "/analyze" || !isMultipartUpload(r)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsMiddleware adds CORS headers so the VS Code webview can call the
+// backend, and answers preflight requests without reaching the handlers.
+// An allowlist containing "*" permits every origin.
+func corsMiddleware(allowedOrigins []string, next http.Handler) http.Handler {
+	allowAll := slices.Contains(allowedOrigins, "*")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		switch {
+		case allowAll:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && slices.Contains(allowedOrigins, origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join([]string{"Content-Type", "Authorization", sessionIDHeader, requestIDHeader, workspaceIDHeader, "If-None-Match"}, ", "))
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join([]string{sessionIDHeader, sessionResumedHeader, requestIDHeader, cacheHeader, retryCountHeader, "ETag"}, ", "))
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
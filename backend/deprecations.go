@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Rule IDs of findings for deprecated resource types and attributes.
+const (
+	deprecatedResourceRuleID  = "DEPRECATED.1"
+	deprecatedAttributeRuleID = "DEPRECATED.2"
+)
+
+// deprecationsManifest lists resource types and attributes that providers
+// have deprecated or removed.
+//
+//go:embed providers/deprecated_resources.json
+var deprecationsManifest []byte
+
+// Deprecation describes a deprecated resource type, or an attribute or
+// nested block of one when Attribute is set.
+type Deprecation struct {
+	ResourceType string `json:"resource_type"`
+	Attribute    string `json:"attribute,omitempty"`
+	// Match limits the deprecation to resources whose literal arguments
+	// have these values, such as an ElastiCache cluster running Redis.
+	Match          map[string]string `json:"match,omitempty"`
+	Provider       string            `json:"provider"`
+	Replacement    string            `json:"replacement"`
+	DeprecatedIn   string            `json:"deprecated_in,omitempty"`
+	RemovedIn      string            `json:"removed_in,omitempty"`
+	Reason         string            `json:"reason"`
+	MigrationGuide string            `json:"migration_guide"`
+	Example        string            `json:"example"`
+}
+
+// loadDeprecations decodes the embedded deprecation manifest.
+func loadDeprecations() ([]Deprecation, error) {
+	var deprecations []Deprecation
+	if err := json.Unmarshal(deprecationsManifest, &deprecations); err != nil {
+		return nil, fmt.Errorf("failed to decode deprecated resources: %w", err)
+	}
+	for _, d := range deprecations {
+		for _, v := range []string{d.DeprecatedIn, d.RemovedIn} {
+			if v != "" && !isExactVersion(v) {
+				return nil, fmt.Errorf("invalid provider version %q for deprecated %s", v, d.ResourceType)
+			}
+		}
+	}
+	return deprecations, nil
+}
+
+// deprecationAnalyzer flags deprecated resource types and attributes
+// locally, without invoking Bedrock.
+type deprecationAnalyzer struct {
+	deprecations []Deprecation
+}
+
+// newDeprecationAnalyzer returns an analyzer using the embedded manifest.
+func newDeprecationAnalyzer() (*deprecationAnalyzer, error) {
+	deprecations, err := loadDeprecations()
+	if err != nil {
+		return nil, err
+	}
+	return &deprecationAnalyzer{deprecations: deprecations}, nil
+}
+
+// Analyze implements Analyzer.
+func (a *deprecationAnalyzer) Analyze(_ context.Context, tf TerraformFile) ([]Finding, error) {
+	var findings []Finding
+	for _, res := range tf.Resources {
+		for _, d := range a.deprecations {
+			if d.ResourceType != res.Type || !d.matches(res) {
+				continue
+			}
+			ruleID, subject := deprecatedResourceRuleID, res.String()+" uses "+res.Type
+			if d.Attribute != "" {
+				ruleID, subject = deprecatedAttributeRuleID, res.String()+" sets "+d.Attribute
+			}
+			findings = append(findings, Finding{
+				Severity:        SeverityDeprecated,
+				ResourceType:    res.Type,
+				RuleID:          ruleID,
+				Description:     fmt.Sprintf("%s, which is %s because %s; use %s instead. Migration guide: %s", subject, d.status(), d.Reason, d.Replacement, d.MigrationGuide),
+				RemediationCode: d.Example,
+			})
+		}
+	}
+	return findings, nil
+}
+
+// matches reports whether the deprecation applies to res.
+func (d Deprecation) matches(res TerraformBlock) bool {
+	if d.Attribute != "" {
+		_, isAttr := res.Body.Attributes[d.Attribute]
+		isBlock := false
+		for _, b := range res.Body.Blocks {
+			isBlock = isBlock || b.Type == d.Attribute
+		}
+		if !isAttr && !isBlock {
+			return false
+		}
+	}
+	for name, want := range d.Match {
+		if !strings.EqualFold(literalString(res, name), want) {
+			return false
+		}
+	}
+	return true
+}
+
+// status describes when the provider deprecated or removed the resource.
+func (d Deprecation) status() string {
+	switch {
+	case d.RemovedIn != "":
+		return fmt.Sprintf("removed in %s %s", d.Provider, d.RemovedIn)
+	case d.DeprecatedIn != "":
+		return fmt.Sprintf("deprecated since %s %s", d.Provider, d.DeprecatedIn)
+	default:
+		return "superseded"
+	}
+}
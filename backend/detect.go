@@ -0,0 +1,78 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// hclBlockPattern matches the top-level blocks HCL code usually starts with.
+var hclBlockPattern = regexp.MustCompile(`^(?:(?:resource|data|module|provider|variable|output)\s+"|(?:terraform|locals|moved|import)\s*\{)`)
+
+// pulumiResourcesPattern matches the resources section of a Pulumi YAML
+// program, a top-level resources key followed by an indented entry.
+var pulumiResourcesPattern = regexp.MustCompile(`(?m)^resources:[ \t]*\r?\n[ \t]+\S`)
+
+// DetectFormat guesses the format of code submitted without one. Code
+// starting with { is plan JSON, code starting with an HCL block is HCL and
+// code with a resources: section is Pulumi YAML. Otherwise each format is
+// tried in turn and the first whose parser accepts the code is used,
+// falling back to HCL so its parse errors are reported. Terragrunt files
+// are HCL too and must be submitted as terragrunt.
+func DetectFormat(code string) Format {
+	start := skipLeadingComments(code)
+	switch {
+	case strings.HasPrefix(start, "{"):
+		return formatPlanJSON
+	case hclBlockPattern.MatchString(start):
+		return formatHCL
+	case pulumiResourcesPattern.MatchString(code):
+		return formatPulumiYAML
+	}
+
+	if _, diags := hclsyntax.ParseConfig([]byte(code), "main.tf", hcl.InitialPos); !diags.HasErrors() {
+		return formatHCL
+	}
+	for _, format := range []Format{formatPlanJSON, formatPulumiYAML} {
+		if _, err := inputCode(format, code); err == nil {
+			return format
+		}
+	}
+	return formatHCL
+}
+
+// skipLeadingComments returns code from its first character that is not
+// whitespace or part of a comment.
+func skipLeadingComments(code string) string {
+	for {
+		code = strings.TrimLeft(code, " \t\r\n")
+		switch {
+		case strings.HasPrefix(code, "#"), strings.HasPrefix(code, "//"):
+			_, rest, found := strings.Cut(code, "\n")
+			if !found {
+				return ""
+			}
+			code = rest
+		case strings.HasPrefix(code, "/*"):
+			_, rest, found := strings.Cut(code, "*/")
+			if !found {
+				return ""
+			}
+			code = rest
+		default:
+			return code
+		}
+	}
+}
+
+// resolveFormat sets the format of a request that did not name one to the
+// detected format, which it returns. It returns "" if the format was named.
+func (req *AnalyzeRequest) resolveFormat() Format {
+	if req.Format != "" {
+		return ""
+	}
+	req.Format = DetectFormat(req.Code)
+	return req.Format
+}
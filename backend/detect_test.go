@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name, code string
+		want       Format
+	}{
+		{"resource block", `resource "aws_s3_bucket" "b" {}`, formatHCL},
+		{"terraform block after comments", "# main.tf\n/* settings */\n// providers\nterraform {\n}\n", formatHCL},
+		{"locals block", "locals {\n  name = \"x\"\n}\n", formatHCL},
+		{"plan JSON", `{"format_version": "1.2", "planned_values": {}}`, formatPlanJSON},
+		{"plan JSON after whitespace", "\n\t {\"resource_changes\": []}", formatPlanJSON},
+		{"Pulumi YAML", "name: app\nruntime: yaml\nresources:\n  bucket:\n    type: aws:s3:Bucket\n", formatPulumiYAML},
+		{"HCL attributes only", "region = \"us-east-1\"\n", formatHCL},
+		{"unparseable code falls back to HCL", "resources: [", formatHCL},
+		{"empty code", "", formatHCL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFormat(tt.code); got != tt.want {
+				t.Errorf("DetectFormat(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DiffRequest defines the structure of the incoming /diff JSON request.
+type DiffRequest struct {
+	Before    string `json:"before"`
+	After     string `json:"after"`
+	Framework string `json:"framework,omitempty"`
+	Format    Format `json:"format,omitempty"`
+}
+
+// DiffResponse defines the structure of the /diff JSON response.
+type DiffResponse struct {
+	Introduced     []Finding `json:"introduced"`
+	Resolved       []Finding `json:"resolved"`
+	UnchangedCount int       `json:"unchanged_count"`
+}
+
+// diffKey identifies a finding across two analyses: the same rule on the
+// same resource is considered the same violation. The resource is the first
+// address of the finding's type named in its description, or the type alone
+// when none is, so a second violating resource is a new violation.
+func (f Finding) diffKey() string {
+	if f.RuleID == "" {
+		return f.ResourceType + "\x00" + f.Description
+	}
+	return f.resourceAddress() + "\x00" + f.RuleID
+}
+
+// resourceAddress returns the first address of the finding's resource type,
+// such as aws_s3_bucket.logs, named in its description, or its type alone.
+func (f Finding) resourceAddress() string {
+	if f.ResourceType == "" {
+		return ""
+	}
+	for i := 0; ; {
+		j := strings.Index(f.Description[i:], f.ResourceType+".")
+		if j < 0 {
+			return f.ResourceType
+		}
+		start := i + j
+		end := start + len(f.ResourceType) + 1
+		for end < len(f.Description) && isAddressChar(f.Description[end]) {
+			end++
+		}
+		name := f.Description[start+len(f.ResourceType)+1 : end]
+		if name != "" && (start == 0 || (f.Description[start-1] != '.' && !isAddressChar(f.Description[start-1]))) {
+			return f.Description[start:end]
+		}
+		i = start + 1
+	}
+}
+
+// diffHandler handles the /diff endpoint. Both versions are analyzed in
+// parallel and the findings compared to show what a change introduced or fixed.
+func (api *BedrockConverseAPI) diffHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	logger := loggerFromContext(r.Context())
+
+	var req DiffRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if api.rejectPromptInjection(w, logger, req.Before, req.After) {
+		return
+	}
+
+	if req.Before == "" && req.After == "" {
+		writeJSONError(w, http.StatusBadRequest, "At least one of before and after is required")
+		return
+	}
+
+	fw, err := lookupFramework(req.Framework)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	labels := []string{"before", "after"}
+	versions := []string{req.Before, req.After}
+	parsed := make([]*TerraformFile, len(versions))
+	for i, code := range versions {
+		if code == "" {
+			continue
+		}
+		if versions[i], err = inputCode(req.Format, code); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var ok bool
+		if parsed[i], ok = parseSource(r.Context(), w, labels[i]+".tf", versions[i]); !ok {
+			return
+		}
+	}
+	if req.Framework == "" {
+		// Both versions are analyzed against the same framework so their
+		// findings are comparable.
+		both := &TerraformFile{}
+		for _, tf := range parsed {
+			if tf != nil {
+				both.merge(tf)
+			}
+		}
+		fw = detectFramework(both)
+	}
+
+	// An empty version has no findings, so only non-empty ones are analyzed.
+	findings := make([][]Finding, len(versions))
+	errs := make([]error, len(versions))
+	var wg sync.WaitGroup
+	for i, source := range versions {
+		if source == "" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			findings[i], errs[i] = api.analyzeSource(r.Context(), logger.With("version", labels[i]), source, parsed[i], fw)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		writeAnalysisError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, diffFindings(findings[0], findings[1]))
+}
+
+// diffFindings compares the findings of the before and after versions.
+func diffFindings(before, after []Finding) DiffResponse {
+	resp := DiffResponse{Introduced: []Finding{}, Resolved: []Finding{}}
+
+	seen := make(map[string]bool, len(before))
+	for _, f := range before {
+		seen[f.diffKey()] = true
+	}
+	current := make(map[string]bool, len(after))
+	for _, f := range after {
+		current[f.diffKey()] = true
+		if seen[f.diffKey()] {
+			resp.UnchangedCount++
+		} else {
+			resp.Introduced = append(resp.Introduced, f)
+		}
+	}
+	for _, f := range before {
+		if !current[f.diffKey()] {
+			resp.Resolved = append(resp.Resolved, f)
+		}
+	}
+	return resp
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// driftAttributeSeverities are the attributes whose drift affects
+// compliance, with the severity of a change made outside Terraform.
+var driftAttributeSeverities = map[string]string{
+	"ingress":                              SeverityHigh,
+	"egress":                               SeverityHigh,
+	"cidr_blocks":                          SeverityHigh,
+	"ipv6_cidr_blocks":                     SeverityHigh,
+	"from_port":                            SeverityHigh,
+	"to_port":                              SeverityHigh,
+	"policy":                               SeverityHigh,
+	"assume_role_policy":                   SeverityHigh,
+	"inline_policy":                        SeverityHigh,
+	"managed_policy_arns":                  SeverityHigh,
+	"acl":                                  SeverityHigh,
+	"grant":                                SeverityHigh,
+	"publicly_accessible":                  SeverityHigh,
+	"associate_public_ip_address":          SeverityHigh,
+	"map_public_ip_on_launch":              SeverityHigh,
+	"block_public_acls":                    SeverityHigh,
+	"block_public_policy":                  SeverityHigh,
+	"ignore_public_acls":                   SeverityHigh,
+	"restrict_public_buckets":              SeverityHigh,
+	"vpc_security_group_ids":               SeverityMedium,
+	"security_groups":                      SeverityMedium,
+	"encrypted":                            SeverityMedium,
+	"storage_encrypted":                    SeverityMedium,
+	"kms_key_id":                           SeverityMedium,
+	"kms_master_key_id":                    SeverityMedium,
+	"sqs_managed_sse_enabled":              SeverityMedium,
+	"server_side_encryption_configuration": SeverityMedium,
+	"enable_key_rotation":                  SeverityMedium,
+	"metadata_options":                     SeverityMedium,
+	"is_multi_region_trail":                SeverityMedium,
+	"enable_logging":                       SeverityMedium,
+	"enable_log_file_validation":           SeverityMedium,
+	"logging":                              SeverityMedium,
+	"versioning":                           SeverityMedium,
+	"deletion_protection":                  SeverityLow,
+	"backup_retention_period":              SeverityLow,
+	"multi_az":                             SeverityLow,
+	"point_in_time_recovery":               SeverityLow,
+}
+
+// DriftRequest defines the structure of the /analyze/drift JSON request.
+type DriftRequest struct {
+	AnalyzeRequest
+	// Plan is the `terraform show -json` output of a plan of Code.
+	Plan string `json:"plan"`
+}
+
+// DriftResponse defines the structure of the /analyze/drift JSON response.
+type DriftResponse struct {
+	// DriftFindings report compliance-relevant changes made to the deployed
+	// infrastructure outside Terraform, and CodeFindings the analysis of
+	// the code itself.
+	DriftFindings []Finding `json:"drift_findings"`
+	CodeFindings  []Finding `json:"code_findings"`
+}
+
+// parsePlanDrift returns the resource_drift entries of a Terraform plan.
+func parsePlanDrift(plan string) ([]planResourceChange, error) {
+	dec := json.NewDecoder(strings.NewReader(plan))
+	dec.UseNumber()
+
+	var doc planDocument
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid Terraform plan JSON: %w", err)
+	}
+	if doc.FormatVersion == "" {
+		return nil, errors.New("invalid Terraform plan JSON: missing format_version, expected `terraform show -json` output")
+	}
+	return doc.ResourceDrift, nil
+}
+
+// driftFindings reports the compliance-relevant attributes of the drifted
+// resources, comparing the deployed values with those set in tf.
+func driftFindings(tf *TerraformFile, drift []planResourceChange) []Finding {
+	findings := []Finding{}
+	for _, rc := range drift {
+		if rc.Mode == "data" {
+			continue
+		}
+		block, inCode := driftBlock(tf, rc)
+		if rc.Change.After == nil {
+			consequence := "the next apply recreates it, so any controls it provides are missing until then"
+			if !inCode {
+				consequence = "it is not declared in the submitted code, so check whether another configuration still depends on it"
+			}
+			findings = append(findings, Finding{
+				Severity:     SeverityMedium,
+				ResourceType: rc.Type,
+				RuleID:       "DRIFT.2",
+				Description:  fmt.Sprintf("%s was deleted outside Terraform; %s.", rc.Address, consequence),
+			})
+			continue
+		}
+
+		for _, attr := range sortedKeys(rc.Change.After) {
+			severity, relevant := driftAttributeSeverities[attr]
+			before, after := rc.Change.Before[attr], rc.Change.After[attr]
+			if !relevant || reflect.DeepEqual(before, after) {
+				continue
+			}
+
+			var source string
+			switch {
+			case !inCode:
+				source = "the resource is not declared in the submitted code"
+			case block.Body.Attributes[attr] != nil || hasNestedBlock(block, attr):
+				source = "the code still declares the previous configuration"
+			default:
+				source = attr + " is not set in the code"
+			}
+			findings = append(findings, Finding{
+				Severity:     severity,
+				ResourceType: rc.Type,
+				RuleID:       "DRIFT.1",
+				Description:  fmt.Sprintf("%s %s was changed outside Terraform (%s); %s. Revert the change with terraform apply or bring it into the code after review.", rc.Address, attr, describeDrift(before, after), source),
+			})
+		}
+	}
+	return findings
+}
+
+// driftBlock returns the resource block in tf a drifted instance belongs
+// to. Instances of counted, for_each and module resources share the block
+// of their type and name.
+func driftBlock(tf *TerraformFile, rc planResourceChange) (TerraformBlock, bool) {
+	for _, b := range tf.Resources {
+		if b.Type == rc.Type && b.Name == rc.Name && b.Body != nil {
+			return b, true
+		}
+	}
+	return TerraformBlock{}, false
+}
+
+// describeDrift summarizes how an attribute changed. Lists, such as
+// security group rules, are described by the elements added and removed.
+func describeDrift(before, after any) string {
+	beforeList, beforeIsList := before.([]any)
+	afterList, afterIsList := after.([]any)
+	if !beforeIsList || !afterIsList {
+		return fmt.Sprintf("from %s to %s", driftValue(before), driftValue(after))
+	}
+
+	var changes []string
+	for _, item := range afterList {
+		if !containsValue(beforeList, item) {
+			changes = append(changes, "added "+driftValue(item))
+		}
+	}
+	for _, item := range beforeList {
+		if !containsValue(afterList, item) {
+			changes = append(changes, "removed "+driftValue(item))
+		}
+	}
+	if len(changes) == 0 {
+		return "elements reordered"
+	}
+	return strings.Join(changes, "; ")
+}
+
+// containsValue reports whether list holds a value deeply equal to v.
+func containsValue(list []any, v any) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// driftValue renders a plan value compactly for a finding description,
+// leaving out null and empty attributes of objects.
+func driftValue(v any) string {
+	if obj, ok := v.(map[string]any); ok {
+		compact := make(map[string]any, len(obj))
+		for k, item := range obj {
+			if list, ok := item.([]any); item == nil || ok && len(list) == 0 {
+				continue
+			}
+			compact[k] = item
+		}
+		v = compact
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// analyzeDriftHandler handles the /analyze/drift endpoint. It reports the
+// compliance-relevant drift recorded in a Terraform plan alongside the
+// analysis of the code the plan was made from.
+func (api *BedrockConverseAPI) analyzeDriftHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	logger := loggerFromContext(r.Context())
+
+	var req DriftRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	if req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query text is empty or not a string")
+		return
+	}
+	if req.Plan == "" {
+		writeJSONError(w, http.StatusBadRequest, "plan is required: pass the `terraform show -json` output of a plan")
+		return
+	}
+	if api.rejectPromptInjection(w, logger, req.Code) {
+		return
+	}
+
+	fw, err := lookupFramework(req.Framework)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	drift, err := parsePlanDrift(req.Plan)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tf, ok := parseSource(r.Context(), w, "main.tf", req.Code)
+	if !ok {
+		return
+	}
+	if req.Framework == "" {
+		fw = detectFramework(tf)
+	}
+
+	// Drift is compared with the code before its secrets are redacted.
+	resp := DriftResponse{DriftFindings: driftFindings(tf, drift)}
+	if resp.CodeFindings, err = api.analyzeSource(r.Context(), logger, req.Code, tf, fw); err != nil {
+		writeAnalysisError(w, err)
+		return
+	}
+	logger.Info("Analyzed plan drift", "drifted_resources", len(drift), "drift_findings", len(resp.DriftFindings))
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// encryptionAtRest describes how a storage resource type enables
+// encryption at rest.
+type encryptionAtRest struct {
+	RuleID   string
+	Severity string
+	// Attribute is the boolean attribute that enables encryption, which
+	// defaults to false. When Block is set, the block must be present and
+	// Attribute, if any, is read inside it.
+	Attribute string
+	Block     string
+	// BucketResource is a resource type that can configure encryption for a
+	// bucket instead, such as aws_s3_bucket_server_side_encryption_configuration,
+	// and which the remediation declares.
+	BucketResource string
+	Remediation    string
+}
+
+// encryptionAtRestSettings are how each storage resource type the FSBP
+// pre-check covers enables encryption at rest.
+var encryptionAtRestSettings = map[string]encryptionAtRest{
+	"aws_db_instance":     {RuleID: "RDS.3", Severity: SeverityMedium, Attribute: "storage_encrypted", Remediation: "storage_encrypted = true"},
+	"aws_rds_cluster":     {RuleID: "RDS.27", Severity: SeverityMedium, Attribute: "storage_encrypted", Remediation: "storage_encrypted = true"},
+	"aws_ebs_volume":      {RuleID: "EC2.3", Severity: SeverityMedium, Attribute: "encrypted", Remediation: "encrypted = true"},
+	"aws_efs_file_system": {RuleID: "EFS.1", Severity: SeverityMedium, Attribute: "encrypted", Remediation: "encrypted = true"},
+	"aws_elasticache_replication_group": {
+		RuleID:      "ElastiCache.4",
+		Severity:    SeverityMedium,
+		Attribute:   "at_rest_encryption_enabled",
+		Remediation: "at_rest_encryption_enabled = true",
+	},
+	"aws_s3_bucket": {
+		RuleID:         "S3.4",
+		Severity:       SeverityMedium,
+		Block:          "server_side_encryption_configuration",
+		BucketResource: "aws_s3_bucket_server_side_encryption_configuration",
+	},
+	"aws_dynamodb_table": {
+		RuleID:    "DYNAMODB.SSE.1",
+		Severity:  SeverityLow,
+		Attribute: "enabled",
+		Block:     "sse_specification",
+		Remediation: `sse_specification {
+  enabled = true
+}`,
+	},
+}
+
+// problem describes how b leaves encryption at rest disabled, or returns ""
+// when it is enabled or set to an expression, which is left to the agent.
+func (s encryptionAtRest) problem(b TerraformBlock) string {
+	settings := b
+	if s.Block != "" {
+		found := false
+		for _, nested := range b.Body.Blocks {
+			if nested.Type == s.Block {
+				settings, found = TerraformBlock{Body: nested.Body}, true
+				break
+			}
+		}
+		if !found {
+			return s.Block + " is not set"
+		}
+		if s.Attribute == "" {
+			return ""
+		}
+	}
+	if v, _, ok := literalValue(settings, s.Attribute); ok && v.Type() == cty.Bool && v.False() {
+		return s.Attribute + " = false"
+	}
+	if _, set := settings.Body.Attributes[s.Attribute]; !set {
+		return s.Attribute + " is not set"
+	}
+	return ""
+}
+
+// checkEncryptionAtRest reports resources of the given storage type that are
+// not encrypted at rest, as described by encryptionAtRestSettings.
+func checkEncryptionAtRest(resourceType string) func(tf TerraformFile) []Finding {
+	setting := encryptionAtRestSettings[resourceType]
+	return func(tf TerraformFile) []Finding {
+		var configured map[string]bool
+		if setting.BucketResource != "" {
+			configured = tf.bucketsWith(setting.BucketResource)
+		}
+		var findings []Finding
+		for _, b := range resourcesOfType(tf, resourceType) {
+			problem := setting.problem(b)
+			if problem == "" || (configured != nil && b.configuredBy(configured)) {
+				continue
+			}
+			remediation := setting.Remediation
+			if setting.BucketResource != "" {
+				remediation = fmt.Sprintf(`resource %q %q {
+  bucket = %s.id
+
+  rule {
+    apply_server_side_encryption_by_default {
+      sse_algorithm = "aws:kms"
+    }
+  }
+}`, setting.BucketResource, b.Name, b.Address())
+			}
+			findings = append(findings, localFinding(b, setting.RuleID, setting.Severity,
+				fmt.Sprintf("%s should be encrypted at rest (%s)", b, problem), remediation))
+		}
+		return findings
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"regexp"
+)
+
+// estimatedTokensPerSuggestion approximates the output tokens of one
+// suggestion, including its remediation code.
+const estimatedTokensPerSuggestion = 250
+
+// tokenPattern splits text the way tiktoken's cl100k_base pre-tokenizer
+// does: contractions, runs of letters, up to three digits, runs of
+// punctuation, and whitespace.
+var tokenPattern = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// estimateTokens approximates the number of tokens a tiktoken-compatible
+// tokenizer produces for text. Each pre-token becomes one token, plus one
+// for every further four bytes, which is how long words break into
+// sub-word tokens.
+func estimateTokens(text string) int {
+	tokens := 0
+	for _, piece := range tokenPattern.FindAllString(text, -1) {
+		tokens += 1 + (len(piece)-1)/4
+	}
+	return tokens
+}
+
+// EstimateResponse defines the structure of the /estimate JSON response.
+type EstimateResponse struct {
+	EstimatedInputTokens  int     `json:"estimated_input_tokens"`
+	EstimatedOutputTokens int     `json:"estimated_output_tokens"`
+	EstimatedCostUSD      float64 `json:"estimated_cost_usd"`
+	Model                 string  `json:"model"`
+}
+
+// estimateHandler handles the /estimate endpoint. It builds the prompt an
+// /analyze request would send and prices it without invoking Bedrock.
+func (api *BedrockConverseAPI) estimateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	logger := loggerFromContext(r.Context())
+
+	var req AnalyzeRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	if req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query text is empty or not a string")
+		return
+	}
+
+	fw, err := lookupFramework(req.Framework)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	source, err := inputCode(req.Format, req.Code)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := req.mergeTerragruntInputs(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateVariables(req.Variables); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	workspace, err := resolveWorkspace(req.Workspace)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	source = substituteWorkspace(source, workspace)
+	if err := validateMaxSuggestions(req.MaxSuggestions); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tf, ok := parseSource(r.Context(), w, "main.tf", source)
+	if !ok {
+		return
+	}
+	if req.Framework == "" {
+		fw = detectFramework(tf)
+	}
+	api.applyVariables(logger, tf, req.Variables)
+	api.applyWorkspace(tf, workspace)
+	tf.MaxSuggestions = req.MaxSuggestions
+	api.redactSource(logger, tf)
+
+	prompts, err := api.analysisPrompts(r.Context(), logger, tf, fw)
+	if err != nil {
+		logger.Error("Failed to build analysis prompt", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to build analysis prompt")
+		return
+	}
+
+	// Code too large for one prompt is analyzed in chunks, each answered
+	// separately.
+	input := 0
+	for _, prompt := range prompts {
+		input += estimateTokens(prompt.Text)
+	}
+	output := len(prompts) * api.maxSuggestions(tf) * estimatedTokensPerSuggestion
+	cost := float64(input)/1000*api.Config().InputPricePer1K + float64(output)/1000*api.Config().OutputPricePer1K
+
+	writeJSON(w, http.StatusOK, EstimateResponse{
+		EstimatedInputTokens:  input,
+		EstimatedOutputTokens: output,
+		// Rounded to a millionth of a dollar, finer than Bedrock bills.
+		EstimatedCostUSD: math.Round(cost*1e6) / 1e6,
+		Model:            api.Config().ModelID,
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+// examplesManifest holds the intentionally non-compliant snippets served by
+// /analyze/examples.
+//
+//go:embed examples/examples.yaml
+var examplesManifest []byte
+
+// ExpectedFinding is a violation an example is known to contain.
+type ExpectedFinding struct {
+	RuleID   string `json:"rule_id" yaml:"rule_id"`
+	Severity string `json:"severity" yaml:"severity"`
+}
+
+// Example is a Terraform snippet with known violations, for trying out the
+// analysis.
+type Example struct {
+	Name         string `json:"name" yaml:"name"`
+	Description  string `json:"description" yaml:"description"`
+	ResourceType string `json:"resource_type" yaml:"resource_type"`
+	// Framework is the framework the example is meant to be analyzed
+	// against, which its expected findings belong to.
+	Framework        string            `json:"framework" yaml:"framework"`
+	Code             string            `json:"code" yaml:"code"`
+	ExpectedFindings []ExpectedFinding `json:"expected_findings" yaml:"expected_findings"`
+}
+
+// ExamplesResponse defines the structure of the /analyze/examples JSON response.
+type ExamplesResponse struct {
+	Examples []Example `json:"examples"`
+}
+
+// loadExamples decodes the embedded example manifest.
+func loadExamples() ([]Example, error) {
+	var examples []Example
+	if err := yaml.Unmarshal(examplesManifest, &examples); err != nil {
+		return nil, fmt.Errorf("failed to decode examples: %w", err)
+	}
+	for _, e := range examples {
+		if _, err := lookupFramework(e.Framework); err != nil {
+			return nil, fmt.Errorf("example %s: %w", e.Name, err)
+		}
+	}
+	return examples, nil
+}
+
+// analyzeExamplesHandler handles the /analyze/examples endpoint, listing
+// the examples for the resource_type and framework query parameters, or
+// all of them.
+func (api *BedrockConverseAPI) analyzeExamplesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	framework := query.Get("framework")
+	if framework != "" {
+		if _, err := lookupFramework(framework); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	resourceType := query.Get("resource_type")
+
+	resp := ExamplesResponse{Examples: []Example{}}
+	for _, e := range api.Examples {
+		if (framework == "" || e.Framework == framework) && (resourceType == "" || e.ResourceType == resourceType) {
+			resp.Examples = append(resp.Examples, e)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
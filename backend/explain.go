@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// Rule IDs and resource types are interpolated into the prompt, so they are
+// restricted to the characters real identifiers use.
+var (
+	ruleIDPattern       = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+	resourceTypePattern = regexp.MustCompile(`^[a-z0-9_]{1,128}$`)
+)
+
+// ExplainRequest defines the structure of the incoming /explain JSON request.
+type ExplainRequest struct {
+	RuleID       string `json:"rule_id"`
+	ResourceType string `json:"resource_type,omitempty"`
+}
+
+// ExplainResponse defines the structure of the /explain JSON response.
+type ExplainResponse struct {
+	RuleID              string   `json:"rule_id"`
+	Title               string   `json:"title"`
+	Explanation         string   `json:"explanation"`
+	CompliantExample    string   `json:"compliant_example"`
+	NonCompliantExample string   `json:"non_compliant_example,omitempty"`
+	References          []string `json:"references"`
+}
+
+// explainHandler handles the /explain endpoint, asking the agent to describe
+// why a control exists rather than how to fix a specific violation.
+func (api *BedrockConverseAPI) explainHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	var req ExplainRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if !ruleIDPattern.MatchString(req.RuleID) {
+		writeJSONError(w, http.StatusBadRequest, "rule_id is required and may only contain letters, digits, '.', '_' and '-'")
+		return
+	}
+	if req.ResourceType != "" && !resourceTypePattern.MatchString(req.ResourceType) {
+		writeJSONError(w, http.StatusBadRequest, "resource_type must be a Terraform resource type such as aws_instance")
+		return
+	}
+
+	sessionID, ok := api.requestSessionID(w, r)
+	if !ok {
+		return
+	}
+	logger := loggerFromContext(r.Context()).With("agent_id", api.Config().AgentID, "session_id", sessionID, "rule_id", req.RuleID)
+
+	result, err := api.invokeAgentWithRetry(r.Context(), logger, api.Config().agentFor(""), sessionID, buildExplainPrompt(req.RuleID, req.ResourceType), nil)
+	w.Header().Set(retryCountHeader, strconv.Itoa(result.Retries))
+	if result.Region != "" {
+		w.Header().Set(bedrockRegionHeader, result.Region)
+	}
+	if err != nil {
+		writeAgentError(w, err)
+		return
+	}
+
+	resp, err := parseExplanation(result.Suggestion)
+	if err != nil {
+		logger.Warn("Failed to parse agent response", "error", err)
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: "Agent response could not be parsed", Detail: err.Error()})
+		return
+	}
+	if resp.RuleID == "" {
+		resp.RuleID = req.RuleID
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseExplanation decodes the JSON object returned by the agent, ignoring
+// any text the agent wraps around it.
+func parseExplanation(output string) (ExplainResponse, error) {
+	var resp ExplainResponse
+
+	raw, ok := extractJSON(output, '{', '}')
+	if !ok {
+		return resp, errors.New("agent response does not contain a JSON object")
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return resp, fmt.Errorf("agent response is not a valid JSON object: %w", err)
+	}
+	if resp.References == nil {
+		resp.References = []string{}
+	}
+	return resp, nil
+}
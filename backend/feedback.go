@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Feedback verdicts on a finding.
+const (
+	verdictFalsePositive = "false_positive"
+	verdictFalseNegative = "false_negative"
+	verdictConfirmed     = "confirmed"
+)
+
+// maxFeedbackCommentBytes bounds the comment stored with feedback.
+const maxFeedbackCommentBytes = 4 << 10
+
+// FeedbackRequest defines the structure of the incoming /feedback JSON
+// request. FindingID is the rule_id of the finding; for a false negative it
+// names the rule the analysis should have reported.
+type FeedbackRequest struct {
+	AnalysisID int64  `json:"analysis_id"`
+	FindingID  string `json:"finding_id"`
+	Verdict    string `json:"verdict"`
+	Comment    string `json:"comment,omitempty"`
+}
+
+// FeedbackResponse defines the structure of the /feedback JSON response.
+type FeedbackResponse struct {
+	ID int64 `json:"id"`
+}
+
+// RuleFeedback aggregates the feedback on one rule. Accuracy is the share
+// of reviewed findings that were confirmed rather than false positives, and
+// is omitted until at least one has been reviewed.
+type RuleFeedback struct {
+	RuleID         string   `json:"rule_id"`
+	Confirmed      int      `json:"confirmed"`
+	FalsePositives int      `json:"false_positives"`
+	FalseNegatives int      `json:"false_negatives"`
+	Accuracy       *float64 `json:"accuracy,omitempty"`
+}
+
+// FeedbackSummaryResponse defines the structure of the /feedback/summary JSON response.
+type FeedbackSummaryResponse struct {
+	Rules []RuleFeedback `json:"rules"`
+}
+
+// addFeedback stores a verdict on a finding of analysis and returns its ID.
+func (s *historyStore) addFeedback(ctx context.Context, req FeedbackRequest) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO feedback (analysis_id, rule_id, verdict, comment, created_at) VALUES (?, ?, ?, ?, ?)`,
+		req.AnalysisID, req.FindingID, req.Verdict, req.Comment, time.Now().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// feedbackSummary counts the verdicts for each rule, or only for ruleID when it is set.
+func (s *historyStore) feedbackSummary(ctx context.Context, ruleID string) ([]RuleFeedback, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT rule_id, verdict, COUNT(*) FROM feedback
+		 WHERE ? = '' OR rule_id = ? GROUP BY rule_id, verdict ORDER BY rule_id`,
+		ruleID, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []RuleFeedback{}
+	for rows.Next() {
+		var id, verdict string
+		var n int
+		if err := rows.Scan(&id, &verdict, &n); err != nil {
+			return nil, err
+		}
+		if len(rules) == 0 || rules[len(rules)-1].RuleID != id {
+			rules = append(rules, RuleFeedback{RuleID: id})
+		}
+		rule := &rules[len(rules)-1]
+		switch verdict {
+		case verdictConfirmed:
+			rule.Confirmed = n
+		case verdictFalsePositive:
+			rule.FalsePositives = n
+		case verdictFalseNegative:
+			rule.FalseNegatives = n
+		}
+	}
+
+	for i := range rules {
+		if reviewed := rules[i].Confirmed + rules[i].FalsePositives; reviewed > 0 {
+			accuracy := float64(rules[i].Confirmed) / float64(reviewed)
+			rules[i].Accuracy = &accuracy
+		}
+	}
+	return rules, rows.Err()
+}
+
+// feedbackHandler handles the /feedback endpoint, recording whether a
+// finding of a past analysis was correct.
+func (api *BedrockConverseAPI) feedbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+	if api.History == nil {
+		writeJSONError(w, http.StatusNotFound, "Analysis history is not enabled")
+		return
+	}
+
+	var req FeedbackRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if !ruleIDPattern.MatchString(req.FindingID) {
+		writeJSONError(w, http.StatusBadRequest, "finding_id is required and may only contain letters, digits, '.', '_' and '-'")
+		return
+	}
+	if !slices.Contains([]string{verdictFalsePositive, verdictFalseNegative, verdictConfirmed}, req.Verdict) {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("verdict must be one of %s, %s, %s", verdictFalsePositive, verdictFalseNegative, verdictConfirmed))
+		return
+	}
+	if len(req.Comment) > maxFeedbackCommentBytes {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("comment must be at most %d bytes", maxFeedbackCommentBytes))
+		return
+	}
+
+	logger := loggerFromContext(r.Context())
+	entry, err := api.History.get(r.Context(), req.AnalysisID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("Analysis %d not found", req.AnalysisID))
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to read analysis history", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read analysis history")
+		return
+	}
+
+	// Only a finding the analysis reported can be confirmed or refuted.
+	reported := slices.ContainsFunc(entry.Findings, func(f Finding) bool { return f.RuleID == req.FindingID })
+	if req.Verdict != verdictFalseNegative && !reported {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Analysis %d has no finding %s", req.AnalysisID, req.FindingID))
+		return
+	}
+
+	id, err := api.History.addFeedback(r.Context(), req)
+	if err != nil {
+		logger.Error("Failed to record feedback", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to record feedback")
+		return
+	}
+	logger.Info("Feedback recorded", "analysis_id", req.AnalysisID, "rule_id", req.FindingID, "verdict", req.Verdict)
+
+	writeJSON(w, http.StatusCreated, FeedbackResponse{ID: id})
+}
+
+// feedbackSummaryHandler handles the /feedback/summary endpoint, reporting
+// per-rule accuracy optionally filtered by the rule_id query parameter.
+func (api *BedrockConverseAPI) feedbackSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	if !api.historyEnabled(w, r) {
+		return
+	}
+
+	rules, err := api.History.feedbackSummary(r.Context(), r.URL.Query().Get("rule_id"))
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Failed to summarize feedback", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read feedback")
+		return
+	}
+	writeJSON(w, http.StatusOK, FeedbackSummaryResponse{Rules: rules})
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Severity levels a finding can carry, from most to least severe.
+const (
+	SeverityCritical = "CRITICAL"
+	SeverityHigh     = "HIGH"
+	SeverityMedium   = "MEDIUM"
+	SeverityLow      = "LOW"
+	SeverityInfo     = "INFO"
+)
+
+// SeverityDeprecated marks findings for deprecated resources and
+// attributes, which need migrating rather than remediating.
+const SeverityDeprecated = "DEPRECATED"
+
+// Finding is a single non-compliant pattern identified in the analyzed code.
+type Finding struct {
+	Severity        string `json:"severity,omitempty"`
+	ResourceType    string `json:"resource_type,omitempty"`
+	RuleID          string `json:"rule_id,omitempty"`
+	Description     string `json:"description"`
+	RemediationCode string `json:"remediation_code,omitempty"`
+	// ControlIDs are the framework controls the finding maps to, such as
+	// SOC 2 trust services criteria.
+	ControlIDs []string `json:"control_ids,omitempty"`
+	// NISTControlID is the NIST SP 800-53 control, such as SC-28(1), and
+	// ControlFamily its family, such as SC.
+	NISTControlID string `json:"nist_control_id,omitempty"`
+	ControlFamily string `json:"control_family,omitempty"`
+	// CISControl is the CIS Benchmark control, such as "CIS 2.1.1", and
+	// BenchmarkLevel its profile, "Level 1" or "Level 2".
+	CISControl     string `json:"cis_control,omitempty"`
+	BenchmarkLevel string `json:"benchmark_level,omitempty"`
+	// RemediationEffort is how much work the fix is: trivial, moderate or
+	// significant.
+	RemediationEffort string `json:"remediation_effort,omitempty"`
+	// BlastRadius lists the blocks that depend on the finding's resource,
+	// directly or transitively.
+	BlastRadius []string `json:"blast_radius,omitempty"`
+	// OWASPCategories are the OWASP IaC Security Top 10 categories of the
+	// finding's rule, such as IaC-SEC-01.
+	OWASPCategories []string `json:"owasp_categories,omitempty"`
+	// Category groups related findings, such as cross_account_access.
+	Category string `json:"category,omitempty"`
+}
+
+// agentFinding is a suggestion as emitted by the agent. Older agent versions
+// describe a fix with reasoning and suggested_code_snippet instead of
+// description and remediation_code, so both spellings are accepted.
+type agentFinding struct {
+	Finding
+	Reasoning            string `json:"reasoning"`
+	SuggestedCodeSnippet string `json:"suggested_code_snippet"`
+}
+
+// normalizeSeverity upper-cases a severity and drops values outside the known levels.
+func normalizeSeverity(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	switch s {
+	case SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo, SeverityDeprecated:
+		return s
+	}
+	return ""
+}
+
+// parseFindings decodes the JSON array returned by the agent. Any text the
+// agent wraps around the array, such as a markdown code fence, is ignored.
+func parseFindings(suggestion string) ([]Finding, error) {
+	raw, ok := extractJSON(suggestion, '[', ']')
+	if !ok {
+		return nil, errors.New("agent response does not contain a JSON array")
+	}
+
+	var parsed []agentFinding
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("agent response is not a valid JSON array: %w", err)
+	}
+
+	findings := make([]Finding, len(parsed))
+	for i, p := range parsed {
+		findings[i] = p.finding()
+	}
+	return findings, nil
+}
+
+// parsePartialFindings returns the complete findings at the start of an
+// agent response that was cut off, such as by the analysis deadline.
+func parsePartialFindings(suggestion string) []Finding {
+	start := strings.IndexByte(suggestion, '[')
+	if start < 0 {
+		return nil
+	}
+	dec := json.NewDecoder(strings.NewReader(suggestion[start:]))
+	if _, err := dec.Token(); err != nil {
+		return nil
+	}
+	var findings []Finding
+	for dec.More() {
+		var p agentFinding
+		if err := dec.Decode(&p); err != nil {
+			break
+		}
+		findings = append(findings, p.finding())
+	}
+	return findings
+}
+
+// finding converts p to a Finding, accepting the older field names.
+func (p agentFinding) finding() Finding {
+	f := p.Finding
+	if f.Description == "" {
+		f.Description = p.Reasoning
+	}
+	if f.RemediationCode == "" {
+		f.RemediationCode = p.SuggestedCodeSnippet
+	}
+	f.Severity = normalizeSeverity(f.Severity)
+	return f
+}
+
+// truncatedMarker is written by the agent after its JSON array when the
+// code has more issues than it was allowed to report.
+const truncatedMarker = "TRUNCATED"
+
+// severityRank orders severities from INFO up to CRITICAL, with unknown
+// severities below all of them.
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return 5
+	case SeverityHigh:
+		return 4
+	case SeverityMedium:
+		return 3
+	case SeverityLow:
+		return 2
+	case SeverityInfo, SeverityDeprecated:
+		return 1
+	}
+	return 0
+}
+
+// dedupeFindings keeps one finding per block of tf and rule, the most
+// severe, in the position of the first, and returns how many duplicates it
+// dropped. Blocks are matched as findingBlock does, falling back to the
+// resource type when none resolves. Findings without a rule ID are only
+// dropped when their description repeats too.
+func dedupeFindings(tf *TerraformFile, findings []Finding) ([]Finding, int) {
+	index := make(map[string]int)
+	var kept []Finding
+	for _, f := range findings {
+		resource := f.ResourceType
+		if b, ok := findingBlock(tf, f); ok {
+			resource = b.Address()
+		}
+		key := resource + "\x00" + f.RuleID
+		if f.RuleID == "" {
+			key += "\x00" + f.Description
+		}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(kept)
+			kept = append(kept, f)
+			continue
+		}
+		if severityRank(f.Severity) > severityRank(kept[i].Severity) {
+			kept[i] = f
+		}
+	}
+	return kept, len(findings) - len(kept)
+}
+
+// limitFindings caps findings parsed from suggestion at limit and reports
+// whether more issues exist, either because the agent said so or because
+// it returned more findings than allowed.
+func limitFindings(suggestion string, findings []Finding, limit int) ([]Finding, bool) {
+	truncated := false
+	if end := strings.LastIndexByte(suggestion, ']'); end >= 0 {
+		truncated = strings.Contains(suggestion[end:], truncatedMarker)
+	}
+	if len(findings) > limit {
+		return findings[:limit], true
+	}
+	return findings, truncated
+}
+
+// extractJSON returns the outermost span of output delimited by open and
+// close, such as a JSON array the agent surrounded with prose.
+func extractJSON(output string, open, close byte) ([]byte, bool) {
+	raw := []byte(output)
+	start := bytes.IndexByte(raw, open)
+	end := bytes.LastIndexByte(raw, close)
+	if start < 0 || end < start {
+		return nil, false
+	}
+	return raw[start : end+1], true
+}
@@ -0,0 +1,94 @@
+package main
+
+import "testing"
+
+func TestDedupeFindings(t *testing.T) {
+	tf, diags := parseTerraform("main.tf", `
+resource "aws_s3_bucket" "logs" {}
+resource "aws_s3_bucket" "data" {}
+`)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	tests := []struct {
+		name     string
+		findings []Finding
+		want     []Finding
+		dropped  int
+	}{
+		{
+			name: "same block and rule keeps the most severe",
+			findings: []Finding{
+				{Severity: SeverityLow, ResourceType: "aws_s3_bucket", RuleID: "S3.1", Description: "aws_s3_bucket.logs is public"},
+				{Severity: SeverityHigh, ResourceType: "aws_s3_bucket", RuleID: "S3.1", Description: "aws_s3_bucket.logs allows public reads"},
+			},
+			want: []Finding{
+				{Severity: SeverityHigh, ResourceType: "aws_s3_bucket", RuleID: "S3.1", Description: "aws_s3_bucket.logs allows public reads"},
+			},
+			dropped: 1,
+		},
+		{
+			name: "same rule on different blocks is kept",
+			findings: []Finding{
+				{Severity: SeverityHigh, ResourceType: "aws_s3_bucket", RuleID: "S3.1", Description: "aws_s3_bucket.logs is public"},
+				{Severity: SeverityHigh, ResourceType: "aws_s3_bucket", RuleID: "S3.1", Description: "aws_s3_bucket.data is public"},
+			},
+			want: []Finding{
+				{Severity: SeverityHigh, ResourceType: "aws_s3_bucket", RuleID: "S3.1", Description: "aws_s3_bucket.logs is public"},
+				{Severity: SeverityHigh, ResourceType: "aws_s3_bucket", RuleID: "S3.1", Description: "aws_s3_bucket.data is public"},
+			},
+		},
+		{
+			name: "different rules on the same block are kept",
+			findings: []Finding{
+				{Severity: SeverityHigh, ResourceType: "aws_s3_bucket", RuleID: "S3.1", Description: "aws_s3_bucket.logs is public"},
+				{Severity: SeverityMedium, ResourceType: "aws_s3_bucket", RuleID: "S3.4", Description: "aws_s3_bucket.logs is not encrypted"},
+			},
+			want: []Finding{
+				{Severity: SeverityHigh, ResourceType: "aws_s3_bucket", RuleID: "S3.1", Description: "aws_s3_bucket.logs is public"},
+				{Severity: SeverityMedium, ResourceType: "aws_s3_bucket", RuleID: "S3.4", Description: "aws_s3_bucket.logs is not encrypted"},
+			},
+		},
+		{
+			name: "resource types without a block are matched by type",
+			findings: []Finding{
+				{Severity: SeverityMedium, ResourceType: "aws_iam_role", RuleID: "IAM.1", Description: "role is too broad"},
+				{Severity: SeverityCritical, ResourceType: "aws_iam_role", RuleID: "IAM.1", Description: "role allows *"},
+			},
+			want: []Finding{
+				{Severity: SeverityCritical, ResourceType: "aws_iam_role", RuleID: "IAM.1", Description: "role allows *"},
+			},
+			dropped: 1,
+		},
+		{
+			name: "findings without a rule are dropped only when the description repeats",
+			findings: []Finding{
+				{Severity: SeverityLow, ResourceType: "aws_iam_role", Description: "role has no description"},
+				{Severity: SeverityLow, ResourceType: "aws_iam_role", Description: "role has no tags"},
+				{Severity: SeverityLow, ResourceType: "aws_iam_role", Description: "role has no description"},
+			},
+			want: []Finding{
+				{Severity: SeverityLow, ResourceType: "aws_iam_role", Description: "role has no description"},
+				{Severity: SeverityLow, ResourceType: "aws_iam_role", Description: "role has no tags"},
+			},
+			dropped: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dropped := dedupeFindings(tf, tt.findings)
+			if dropped != tt.dropped {
+				t.Errorf("dropped = %d, want %d", dropped, tt.dropped)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d findings, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i].Severity != tt.want[i].Severity || got[i].RuleID != tt.want[i].RuleID || got[i].Description != tt.want[i].Description {
+					t.Errorf("finding %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// defaultFrameworkID is the compliance framework used when a request does not name one.
+const defaultFrameworkID = "fsbp"
+
+// Framework is a compliance framework the agent can analyze against.
+type Framework struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Provider is the Terraform provider the framework is specific to, if any.
+	Provider string `json:"provider,omitempty"`
+	// Guidance completes the prompt sentence "identify non-compliant
+	// patterns based on ..." and steers the agent toward the framework.
+	Guidance string `json:"-"`
+}
+
+// frameworks lists the supported compliance frameworks.
+var frameworks = []Framework{
+	{
+		ID:       "fsbp",
+		Name:     "AWS Foundational Security Best Practices",
+		Provider: "aws",
+		Guidance: "the FSBP sentinel policies in the knowledge base",
+	},
+	{
+		ID:       "azure-cis",
+		Name:     "CIS Microsoft Azure Foundations Benchmark",
+		Provider: "azurerm",
+		Guidance: "the CIS Microsoft Azure Foundations Benchmark controls for azurerm resources, covering storage account public access, disk encryption, network security group rules, key vault soft delete and purge protection, SQL server auditing, and Azure AD authentication",
+	},
+	{
+		ID:       "gcp-cis",
+		Name:     "CIS Google Cloud Platform Foundation Benchmark",
+		Provider: "google",
+		Guidance: "the CIS Google Cloud Platform Foundation Benchmark controls for google resources, covering Cloud Storage uniform bucket-level access, Cloud SQL SSL enforcement, Compute Engine disk encryption with customer-managed keys, IAM policy binding auditing, VPC firewall rule logging, and GKE cluster security settings; remediations should use google provider arguments such as uniform_bucket_level_access, ip_configuration.ssl_mode, disk_encryption_key, log_config and private_cluster_config",
+	},
+	{
+		ID:       "cis-aws",
+		Name:     "CIS Amazon Web Services Foundations Benchmark",
+		Provider: "aws",
+		Guidance: "the CIS Amazon Web Services Foundations Benchmark v3.0.0 Level 1 and Level 2 controls for aws resources, covering IAM (section 1) such as root account MFA and password policy, storage (section 2) such as S3 Block Public Access and RDS encryption, logging (section 3) such as multi-region CloudTrail, log file validation, KMS key rotation and VPC flow logs, and networking (section 5) such as security groups admitting administration ports from 0.0.0.0/0; cite the control in each rule_id, such as CIS.3.1, and give it in a cis_control field, such as \"CIS 3.1\", with its profile in a benchmark_level field, \"Level 1\" or \"Level 2\"",
+	},
+	{
+		ID:       "cis",
+		Name:     "CIS Benchmarks",
+		Guidance: "the CIS Benchmark controls for the cloud providers used in the code",
+	},
+	{
+		ID:       "nist-800-53",
+		Name:     "NIST SP 800-53 Rev. 5",
+		Guidance: "the NIST SP 800-53 Rev. 5 security controls, in particular the Access Control (AC), Audit and Accountability (AU), Assessment, Authorization, and Monitoring (CA), Configuration Management (CM), Identification and Authentication (IA), System and Communications Protection (SC) and System and Information Integrity (SI) families as assessed for FedRAMP; cite the control in each rule_id, such as NIST.SC-28(1), and give the control with any enhancement in a nist_control_id field, such as SC-28(1), and its two-letter family in a control_family field, such as SC",
+	},
+	{
+		ID:       "pci-dss",
+		Name:     "PCI DSS v4.0",
+		Guidance: "the PCI DSS v4.0 requirements for cardholder data environments, in particular encryption of stored cardholder data (Req 3.5), strong cryptography for transmission over open networks (Req 4.2), network segmentation of the cardholder data environment (Req 1.3), audit logging (Req 10.2) and multi-factor authentication into the cardholder data environment (Req 8.4); treat databases, caches, ECS services and VPCs that may hold or carry payment data as in scope, and cite the requirement in each rule_id, such as PCI.3.5.1",
+	},
+	{
+		ID:       "hipaa",
+		Name:     "HIPAA Security Rule",
+		Guidance: "the HIPAA Security Rule safeguards for systems handling protected health information, using only HIPAA-eligible services covered by the AWS Business Associate Addendum: encryption at rest of RDS and Aurora databases and S3 buckets (164.312(a)(2)(iv)), CloudTrail logging in all regions and VPC flow logs (164.312(b)), rotation of customer managed KMS keys, GuardDuty enabled for threat detection (164.308(a)(1)(ii)(D)), AWS Config rules recording configuration compliance (164.308(a)(8)) and deletion protection and backups for stores of PHI (164.308(a)(7)); treat databases, buckets and logs that may hold PHI as in scope, and cite the section in each rule_id, such as HIPAA.164.312(b)",
+	},
+	{
+		ID:       "soc2",
+		Name:     "SOC 2",
+		Guidance: "the SOC 2 Trust Services Criteria a Type II audit tests the operating effectiveness of: logical access controls and least-privilege IAM (CC6), system operations monitoring with CloudTrail and GuardDuty (CC7), availability through multi-AZ RDS and ELB health checks (A1) and confidentiality through KMS encryption and S3 bucket policies (C1); cite the criterion in each rule_id, such as SOC2.CC6.1, and list every criterion the finding is evidence against in a control_ids array, such as [\"CC6.1\", \"CC6.3\"]",
+	},
+}
+
+// lookupFramework returns the framework with the given ID. An empty ID
+// selects the default framework.
+func lookupFramework(id string) (Framework, error) {
+	if id == "" {
+		id = defaultFrameworkID
+	}
+	for _, fw := range frameworks {
+		if fw.ID == id {
+			return fw, nil
+		}
+	}
+
+	ids := make([]string, len(frameworks))
+	for i, fw := range frameworks {
+		ids[i] = fw.ID
+	}
+	return Framework{}, fmt.Errorf("unknown framework %q: must be one of %s", id, strings.Join(ids, ", "))
+}
+
+// detectFramework picks the framework for a request that did not name one.
+// Code written for a single cloud provider with its own framework is
+// analyzed against that framework; anything else uses the default.
+func detectFramework(tf *TerraformFile) Framework {
+	var matched []Framework
+	for _, fw := range frameworks {
+		if fw.Provider != "" && tf.usesProvider(fw.Provider) {
+			matched = append(matched, fw)
+		}
+	}
+	if len(matched) == 1 {
+		return matched[0]
+	}
+	fw, _ := lookupFramework(defaultFrameworkID)
+	return fw
+}
+
+// FrameworksResponse defines the structure of the /frameworks JSON response.
+type FrameworksResponse struct {
+	Frameworks []Framework `json:"frameworks"`
+}
+
+// frameworksHandler handles the /frameworks endpoint.
+func (api *BedrockConverseAPI) frameworksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, FrameworksResponse{Frameworks: frameworks})
+}
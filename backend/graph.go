@@ -0,0 +1,202 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// ResourceNode is a resource, data source or module call in the
+// dependency graph.
+type ResourceNode struct {
+	// Address is the block's Terraform address, such as aws_vpc.main,
+	// data.aws_ami.ubuntu or module.network.
+	Address string `json:"address"`
+	Kind    string `json:"kind"`
+	Type    string `json:"type,omitempty"`
+	Line    int    `json:"line"`
+}
+
+// ResourceEdge records that From references To, so From depends on it.
+type ResourceEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Attribute is the argument holding the reference, such as subnet_id
+	// or ingress.security_groups for one in a nested block.
+	Attribute string `json:"attribute"`
+}
+
+// ResourceGraph defines the structure of the /graph JSON response.
+type ResourceGraph struct {
+	Nodes []ResourceNode `json:"nodes"`
+	Edges []ResourceEdge `json:"edges"`
+}
+
+// graphAddress returns the address b is referenced by.
+func graphAddress(b TerraformBlock) string {
+	switch b.Kind {
+	case "data":
+		return "data." + b.Address()
+	case "module":
+		return "module." + b.Name
+	}
+	return b.Address()
+}
+
+// buildResourceGraph links the resources, data sources and module calls of
+// tf by the references in their arguments, including depends_on. References
+// to blocks the file does not declare are left out.
+func buildResourceGraph(tf *TerraformFile) ResourceGraph {
+	graph := ResourceGraph{Nodes: []ResourceNode{}, Edges: []ResourceEdge{}}
+	blocks := slices.Concat(tf.Resources, tf.DataSources, tf.Modules)
+	declared := make(map[string]bool, len(blocks))
+	for _, b := range blocks {
+		address := graphAddress(b)
+		declared[address] = true
+		graph.Nodes = append(graph.Nodes, ResourceNode{Address: address, Kind: b.Kind, Type: b.Type, Line: b.Line})
+	}
+
+	seen := make(map[ResourceEdge]bool)
+	for _, b := range blocks {
+		if b.Body == nil {
+			continue
+		}
+		from := graphAddress(b)
+		walkReferences(b.Body, "", func(attribute string, traversal hcl.Traversal) {
+			to := referenceAddress(traversal)
+			edge := ResourceEdge{From: from, To: to, Attribute: attribute}
+			if to != "" && to != from && declared[to] && !seen[edge] {
+				seen[edge] = true
+				graph.Edges = append(graph.Edges, edge)
+			}
+		})
+	}
+	return graph
+}
+
+// walkReferences calls fn with every variable reference in body and its
+// nested blocks, along with the dotted path of the argument holding it.
+func walkReferences(body *hclsyntax.Body, prefix string, fn func(string, hcl.Traversal)) {
+	for _, name := range sortedKeys(body.Attributes) {
+		for _, traversal := range body.Attributes[name].Expr.Variables() {
+			fn(prefix+name, traversal)
+		}
+	}
+	for _, block := range body.Blocks {
+		walkReferences(block.Body, prefix+block.Type+".", fn)
+	}
+}
+
+// referenceAddress returns the address of the resource, data source or
+// module a reference such as aws_subnet.private[0].id points at, or "" for
+// references to variables, locals and other values.
+func referenceAddress(traversal hcl.Traversal) string {
+	var names []string
+steps:
+	for _, step := range traversal {
+		switch s := step.(type) {
+		case hcl.TraverseRoot:
+			names = append(names, s.Name)
+		case hcl.TraverseAttr:
+			names = append(names, s.Name)
+		default:
+			// An index or splat ends the address.
+			break steps
+		}
+	}
+
+	root := traversal.RootName()
+	switch root {
+	case "var", "local", "each", "count", "self", "path", "terraform":
+		return ""
+	case "data":
+		if len(names) < 3 {
+			return ""
+		}
+		return "data." + names[1] + "." + names[2]
+	case "module":
+		if len(names) < 2 {
+			return ""
+		}
+		return "module." + names[1]
+	}
+	if len(names) < 2 {
+		return ""
+	}
+	return root + "." + names[1]
+}
+
+// dependents returns the addresses of the blocks that depend on address,
+// directly or through other blocks, sorted.
+func (g ResourceGraph) dependents(address string) []string {
+	referrers := make(map[string][]string)
+	for _, e := range g.Edges {
+		referrers[e.To] = append(referrers[e.To], e.From)
+	}
+
+	visited := map[string]bool{address: true}
+	queue := []string{address}
+	var found []string
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, from := range referrers[next] {
+			if !visited[from] {
+				visited[from] = true
+				found = append(found, from)
+				queue = append(queue, from)
+			}
+		}
+	}
+	slices.Sort(found)
+	return found
+}
+
+// withBlastRadius lists on each finding the blocks that depend on the
+// resource it most likely refers to, which a fix may affect.
+func (tf *TerraformFile) withBlastRadius(findings []Finding) []Finding {
+	graph := buildResourceGraph(tf)
+	for i, f := range findings {
+		findings[i].BlastRadius = nil
+		if b, ok := findingBlock(tf, f); ok {
+			findings[i].BlastRadius = graph.dependents(graphAddress(b))
+		}
+	}
+	return findings
+}
+
+// graphHandler handles the /graph endpoint, returning the dependency graph
+// of the resources in the code.
+func (api *BedrockConverseAPI) graphHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	var req AnalyzeRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	if req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query text is empty or not a string")
+		return
+	}
+
+	source, err := inputCode(req.Format, req.Code)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tf, ok := parseSource(r.Context(), w, "main.tf", source)
+	if !ok {
+		return
+	}
+
+	graph := buildResourceGraph(tf)
+	loggerFromContext(r.Context()).Info("Built resource graph", "nodes", len(graph.Nodes), "edges", len(graph.Edges))
+	writeJSON(w, http.StatusOK, graph)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// gzipMinSize is the smallest response body worth compressing.
+const gzipMinSize = 1 << 10
+
+// gzipMiddleware decompresses gzip-encoded request bodies and compresses
+// responses of at least gzipMinSize bytes for clients that accept gzip.
+// Server-Sent Event streams are never compressed so each event is delivered
+// as soon as it is flushed.
+func gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+			body, err := gzip.NewReader(r.Body)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "Invalid gzip request body")
+				return
+			}
+			defer body.Close()
+			r.Body = body
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+		}
+
+		if !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		gw := &gzipResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether the client listed gzip in Accept-Encoding.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the start of a response until it knows whether
+// the body is large enough to compress, then either streams it through a
+// gzip.Writer or writes it unchanged.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	decided     bool
+	passthrough bool
+	buf         bytes.Buffer
+	gz          *gzip.Writer
+}
+
+// WriteHeader records the status code. Responses that must not be
+// compressed are switched to pass-through immediately.
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+	g.status = status
+
+	h := g.Header()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" || strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") {
+		g.decided, g.passthrough = true, true
+		g.ResponseWriter.WriteHeader(status)
+	}
+}
+
+// Write buffers p until gzipMinSize bytes are available, then starts compressing.
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	switch {
+	case g.passthrough:
+		return g.ResponseWriter.Write(p)
+	case g.gz != nil:
+		return g.gz.Write(p)
+	}
+
+	g.buf.Write(p)
+	if g.buf.Len() >= gzipMinSize {
+		if err := g.flushBuffer(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flushBuffer commits to compressing or not and writes out the buffered body.
+func (g *gzipResponseWriter) flushBuffer(compress bool) error {
+	g.decided = true
+	h := g.Header()
+	if !compress {
+		g.passthrough = true
+		g.ResponseWriter.WriteHeader(g.status)
+		_, err := g.ResponseWriter.Write(g.buf.Bytes())
+		return err
+	}
+
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	g.ResponseWriter.WriteHeader(g.status)
+	g.gz = gzip.NewWriter(g.ResponseWriter)
+	_, err := g.gz.Write(g.buf.Bytes())
+	return err
+}
+
+// Flush sends any buffered data to the client. A response still below
+// gzipMinSize at its first flush is sent uncompressed.
+func (g *gzipResponseWriter) Flush() {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if !g.decided {
+		_ = g.flushBuffer(false)
+	}
+	if g.gz != nil {
+		_ = g.gz.Flush()
+	}
+	_ = http.NewResponseController(g.ResponseWriter).Flush()
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// Hijack lets WebSocket upgrades take over the wrapped connection.
+func (g *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	g.decided, g.passthrough = true, true
+	return http.NewResponseController(g.ResponseWriter).Hijack()
+}
+
+// close writes any body still buffered and terminates the gzip stream.
+func (g *gzipResponseWriter) close() {
+	if !g.decided {
+		if !g.wroteHeader {
+			// The handler wrote nothing; let the server send its default response.
+			return
+		}
+		_ = g.flushBuffer(false)
+	}
+	if g.gz != nil {
+		_ = g.gz.Close()
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagent"
+)
+
+// healthCheckTimeout bounds how long the readiness probe waits on Bedrock.
+const healthCheckTimeout = 5 * time.Second
+
+// HealthResponse defines the structure of the health check JSON response.
+type HealthResponse struct {
+	Status  string `json:"status"`
+	Bedrock string `json:"bedrock,omitempty"`
+	// Circuit is the state of the Bedrock circuit breaker.
+	Circuit string `json:"circuit,omitempty"`
+}
+
+// livenessHandler handles the /health/live endpoint. It succeeds for as long
+// as the process is able to serve HTTP requests.
+func (api *BedrockConverseAPI) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// healthHandler handles the /health endpoint. It reports the backend as ready
+// only when the configured Bedrock agent can be described with the current
+// AWS credentials. In offline and mock mode Bedrock is not checked.
+func (api *BedrockConverseAPI) healthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if api.Config().OfflineMode {
+		writeJSON(w, http.StatusOK, HealthResponse{Status: "ok", Bedrock: "disabled"})
+		return
+	}
+	if api.Config().MockBedrock {
+		writeJSON(w, http.StatusOK, HealthResponse{Status: "ok", Bedrock: "mocked", Circuit: api.Breaker.State().String()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	_, err := api.AgentClient.GetAgent(ctx, &bedrockagent.GetAgentInput{
+		AgentId: aws.String(api.Config().AgentID),
+	})
+	if err != nil {
+		loggerFromContext(r.Context()).Warn("Health check failed to reach Bedrock", "agent_id", api.Config().AgentID, "error", err)
+		writeJSON(w, http.StatusServiceUnavailable, HealthResponse{Status: "degraded", Bedrock: "unreachable", Circuit: api.Breaker.State().String()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok", Bedrock: "reachable", Circuit: api.Breaker.State().String()})
+}
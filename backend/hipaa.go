@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// hipaaAnalyzer reports S3 buckets without server-side encryption
+// configured (HIPAA 164.312(a)(2)(iv)) and Aurora clusters without deletion
+// protection (164.308(a)(7)(ii)(A)) when analyzing against HIPAA, without
+// waiting for the agent. Both are found in nearly every PHI workload review.
+type hipaaAnalyzer struct{}
+
+// Analyze implements Analyzer.
+func (hipaaAnalyzer) Analyze(ctx context.Context, tf TerraformFile) ([]Finding, error) {
+	if req, ok := analysisRequestFromContext(ctx); !ok || req.Framework.ID != "hipaa" {
+		return nil, nil
+	}
+
+	var findings []Finding
+	encrypted := tf.bucketsWith("aws_s3_bucket_server_side_encryption_configuration")
+	for _, b := range tf.Resources {
+		if b.Body == nil {
+			continue
+		}
+		switch b.Type {
+		case "aws_s3_bucket":
+			if hasNestedBlock(b, "server_side_encryption_configuration") || b.configuredBy(encrypted) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Severity:     SeverityHigh,
+				ResourceType: b.Type,
+				RuleID:       "HIPAA.164.312(a)(2)(iv)",
+				Description:  fmt.Sprintf("%s has no server-side encryption configuration; HIPAA 164.312(a)(2)(iv) requires electronic PHI to be encrypted at rest", b),
+				RemediationCode: fmt.Sprintf(`resource "aws_s3_bucket_server_side_encryption_configuration" %q {
+  bucket = %s.id
+
+  rule {
+    apply_server_side_encryption_by_default {
+      sse_algorithm = "aws:kms"
+    }
+  }
+}`, b.Name, b.Address()),
+			})
+		case "aws_rds_cluster":
+			var problem string
+			if v, _, ok := literalValue(b, "deletion_protection"); ok && v.Type() == cty.Bool && v.False() {
+				problem = "deletion_protection = false"
+			} else if _, set := b.Body.Attributes["deletion_protection"]; !set {
+				problem = "deletion_protection is not set"
+			} else {
+				continue
+			}
+			findings = append(findings, Finding{
+				Severity:        SeverityHigh,
+				ResourceType:    b.Type,
+				RuleID:          "HIPAA.164.308(a)(7)(ii)(A)",
+				Description:     fmt.Sprintf("%s can be deleted with its data (%s); HIPAA 164.308(a)(7)(ii)(A) requires retrievable copies of electronic PHI to be maintained", b, problem),
+				RemediationCode: "deletion_protection = true",
+			})
+		}
+	}
+	return findings, nil
+}
+
+// hasNestedBlock reports whether b declares a nested block of the given type.
+func hasNestedBlock(b TerraformBlock, blockType string) bool {
+	for _, nested := range b.Body.Blocks {
+		if nested.Type == blockType {
+			return true
+		}
+	}
+	return false
+}
+
+// bucketsWith returns the buckets a resource of the given type, such as
+// aws_s3_bucket_server_side_encryption_configuration, applies to, keyed by
+// the referenced resource address or the literal bucket name.
+func (tf *TerraformFile) bucketsWith(resourceType string) map[string]bool {
+	buckets := make(map[string]bool)
+	for _, b := range tf.Resources {
+		if b.Type != resourceType || b.Body == nil {
+			continue
+		}
+		if name := literalString(b, "bucket"); name != "" {
+			buckets[name] = true
+		}
+		attr, ok := b.Body.Attributes["bucket"]
+		if !ok {
+			continue
+		}
+		for _, traversal := range attr.Expr.Variables() {
+			if len(traversal) < 2 || traversal.RootName() != "aws_s3_bucket" {
+				continue
+			}
+			if name, ok := traversal[1].(hcl.TraverseAttr); ok {
+				buckets["aws_s3_bucket."+name.Name] = true
+			}
+		}
+	}
+	return buckets
+}
+
+// configuredBy reports whether bucket b is covered by buckets, as
+// returned by bucketsWith.
+func (b TerraformBlock) configuredBy(buckets map[string]bool) bool {
+	if buckets[b.Address()] {
+		return true
+	}
+	name := literalString(b, "bucket")
+	return name != "" && buckets[name]
+}
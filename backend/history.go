@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// workspaceIDHeader identifies the VS Code workspace an analysis belongs to.
+const workspaceIDHeader = "X-Workspace-ID"
+
+// Paging limits for /history.
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS analyses (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	content_hash TEXT    NOT NULL,
+	created_at   INTEGER NOT NULL,
+	framework    TEXT    NOT NULL,
+	workspace_id TEXT    NOT NULL,
+	findings     BLOB    NOT NULL,
+	findings_len INTEGER
+);
+CREATE INDEX IF NOT EXISTS analyses_workspace ON analyses (workspace_id, created_at);
+CREATE TABLE IF NOT EXISTS feedback (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	analysis_id INTEGER NOT NULL REFERENCES analyses (id),
+	rule_id     TEXT    NOT NULL,
+	verdict     TEXT    NOT NULL,
+	comment     TEXT    NOT NULL,
+	created_at  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS feedback_rule ON feedback (rule_id);
+CREATE TABLE IF NOT EXISTS baselines (
+	workspace_id TEXT    NOT NULL,
+	framework    TEXT    NOT NULL,
+	content_hash TEXT    NOT NULL,
+	created_at   INTEGER NOT NULL,
+	findings     BLOB    NOT NULL,
+	findings_len INTEGER NOT NULL,
+	PRIMARY KEY (workspace_id, framework)
+);
+`
+
+// HistoryEntry is a stored analysis.
+type HistoryEntry struct {
+	ID          int64     `json:"id"`
+	ContentHash string    `json:"content_hash"`
+	CreatedAt   time.Time `json:"created_at"`
+	Framework   string    `json:"framework"`
+	WorkspaceID string    `json:"workspace_id"`
+	Findings    []Finding `json:"findings"`
+}
+
+// HistoryResponse defines the structure of the /history JSON response.
+type HistoryResponse struct {
+	Entries []HistoryEntry `json:"entries"`
+	Total   int            `json:"total"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+	// StorageBytesSaved is how much smaller the workspace's stored
+	// analyses are for being compressed.
+	StorageBytesSaved int64 `json:"storage_bytes_saved"`
+}
+
+// historyStore persists analyses in SQLite.
+type historyStore struct {
+	db *sql.DB
+}
+
+// openHistoryStore opens, creating if needed, the SQLite database at path.
+func openHistoryStore(path string) (*historyStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	// SQLite allows a single writer; serializing through one connection
+	// avoids "database is locked" errors under concurrent requests.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history schema: %w", err)
+	}
+	if err := migrateHistoryCompression(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to compress stored history: %w", err)
+	}
+	return &historyStore{db: db}, nil
+}
+
+// historyMigrationBatch is how many rows migrateHistoryCompression
+// compresses per transaction.
+const historyMigrationBatch = 500
+
+// migrateHistoryCompression compresses the findings of rows stored before
+// history was compressed, adding the findings_len column to databases
+// created before it existed. Uncompressed rows are those without a length.
+func migrateHistoryCompression(db *sql.DB) error {
+	var hasLen bool
+	if err := db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info('analyses') WHERE name = 'findings_len'`).Scan(&hasLen); err != nil {
+		return err
+	}
+	if !hasLen {
+		if _, err := db.Exec(`ALTER TABLE analyses ADD COLUMN findings_len INTEGER`); err != nil {
+			return err
+		}
+	}
+
+	for {
+		rows, err := db.Query(`SELECT id, findings FROM analyses WHERE findings_len IS NULL LIMIT ?`, historyMigrationBatch)
+		if err != nil {
+			return err
+		}
+		type row struct {
+			id       int64
+			findings []byte
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.findings); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, r := range batch {
+			compressed, err := compressHistory(r.findings)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			if _, err := tx.Exec(`UPDATE analyses SET findings = ?, findings_len = ? WHERE id = ?`, compressed, len(r.findings), r.id); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+}
+
+// compressHistory zlib-compresses a stored history field.
+func compressHistory(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressHistory reverses compressHistory.
+func decompressHistory(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// Close closes the database.
+func (s *historyStore) Close() error {
+	return s.db.Close()
+}
+
+// contentHash returns the hex SHA-256 of the analyzed code.
+func contentHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// add stores e and sets its ID.
+func (s *historyStore) add(ctx context.Context, e *HistoryEntry) error {
+	findings, err := json.Marshal(e.Findings)
+	if err != nil {
+		return err
+	}
+	compressed, err := compressHistory(findings)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO analyses (content_hash, created_at, framework, workspace_id, findings, findings_len) VALUES (?, ?, ?, ?, ?, ?)`,
+		e.ContentHash, e.CreatedAt.UnixMilli(), e.Framework, e.WorkspaceID, compressed, len(findings))
+	if err != nil {
+		return err
+	}
+	e.ID, err = res.LastInsertId()
+	return err
+}
+
+// list returns a page of the workspace's analyses, newest first, the
+// total number stored for it, and the bytes compression saved on them.
+func (s *historyStore) list(ctx context.Context, workspaceID string, limit, offset int) ([]HistoryEntry, int, int64, error) {
+	var total int
+	var saved int64
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*), COALESCE(SUM(findings_len - LENGTH(findings)), 0) FROM analyses WHERE workspace_id = ?`,
+		workspaceID).Scan(&total, &saved); err != nil {
+		return nil, 0, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, content_hash, created_at, framework, workspace_id, findings FROM analyses
+		 WHERE workspace_id = ? ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`,
+		workspaceID, limit, offset)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer rows.Close()
+
+	entries := []HistoryEntry{}
+	for rows.Next() {
+		e, err := scanHistoryEntry(rows)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, saved, rows.Err()
+}
+
+// get returns the analysis with the given ID, or sql.ErrNoRows.
+func (s *historyStore) get(ctx context.Context, id int64) (HistoryEntry, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT id, content_hash, created_at, framework, workspace_id, findings FROM analyses WHERE id = ?`, id)
+	return scanHistoryEntry(row)
+}
+
+// scanHistoryEntry decodes one analyses row.
+func scanHistoryEntry(row interface{ Scan(...any) error }) (HistoryEntry, error) {
+	var (
+		e         HistoryEntry
+		createdAt int64
+		findings  []byte
+	)
+	if err := row.Scan(&e.ID, &e.ContentHash, &createdAt, &e.Framework, &e.WorkspaceID, &findings); err != nil {
+		return HistoryEntry{}, err
+	}
+	e.CreatedAt = time.UnixMilli(createdAt).UTC()
+	findings, err := decompressHistory(findings)
+	if err != nil {
+		return HistoryEntry{}, fmt.Errorf("history entry %d has corrupt findings: %w", e.ID, err)
+	}
+	if err := json.Unmarshal(findings, &e.Findings); err != nil {
+		return HistoryEntry{}, fmt.Errorf("history entry %d has invalid findings: %w", e.ID, err)
+	}
+	return e, nil
+}
+
+// recordHistory stores a completed analysis for the workspace named in the
+// request's X-Workspace-ID header and returns its ID. Requests without one
+// are not recorded, and storage failures are logged rather than failing the
+// analysis; both return 0.
+func (api *BedrockConverseAPI) recordHistory(r *http.Request, code string, fw Framework, findings []Finding) int64 {
+	workspaceID := r.Header.Get(workspaceIDHeader)
+	if api.History == nil || workspaceID == "" {
+		return 0
+	}
+
+	e := &HistoryEntry{
+		ContentHash: contentHash(code),
+		CreatedAt:   time.Now().UTC(),
+		Framework:   fw.ID,
+		WorkspaceID: workspaceID,
+		Findings:    findings,
+	}
+	if err := api.History.add(context.WithoutCancel(r.Context()), e); err != nil {
+		loggerFromContext(r.Context()).Error("Failed to record analysis history", "error", err)
+		return 0
+	}
+	return e.ID
+}
+
+// historyEnabled writes a 404 response and returns false when no history database is configured.
+func (api *BedrockConverseAPI) historyEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return false
+	}
+	if api.History == nil {
+		writeJSONError(w, http.StatusNotFound, "Analysis history is not enabled")
+		return false
+	}
+	return true
+}
+
+// historyHandler handles the /history endpoint, listing a workspace's past
+// analyses, with only the findings of a remediation_effort or
+// owasp_category if one is given.
+func (api *BedrockConverseAPI) historyHandler(w http.ResponseWriter, r *http.Request) {
+	if !api.historyEnabled(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	workspaceID := query.Get("workspace_id")
+	if workspaceID == "" {
+		writeJSONError(w, http.StatusBadRequest, "workspace_id is required")
+		return
+	}
+	limit, err := queryInt(query.Get("limit"), defaultHistoryLimit)
+	if err != nil || limit < 1 || limit > maxHistoryLimit {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxHistoryLimit))
+		return
+	}
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		writeJSONError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+		return
+	}
+	effort := query.Get("remediation_effort")
+	if effort != "" {
+		if err := validateRemediationEffort(effort); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	category := query.Get("owasp_category")
+	if category != "" {
+		if err := validateOWASPCategory(category); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	entries, total, saved, err := api.History.list(r.Context(), workspaceID, limit, offset)
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Failed to list analysis history", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read analysis history")
+		return
+	}
+	for i := range entries {
+		if effort != "" {
+			entries[i].Findings = filterByEffort(entries[i].Findings, effort)
+		}
+		if category != "" {
+			entries[i].Findings = filterByOWASPCategory(entries[i].Findings, category)
+		}
+	}
+	writeJSON(w, http.StatusOK, HistoryResponse{Entries: entries, Total: total, Limit: limit, Offset: offset, StorageBytesSaved: saved})
+}
+
+// historyEntryHandler handles the /history/{id} endpoint.
+func (api *BedrockConverseAPI) historyEntryHandler(w http.ResponseWriter, r *http.Request) {
+	if !api.historyEnabled(w, r) {
+		return
+	}
+
+	e, ok := api.lookupHistoryEntry(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, e)
+}
+
+// historyDiffHandler handles the /history/diff endpoint, comparing the
+// findings of two stored analyses named by the from and to query parameters.
+func (api *BedrockConverseAPI) historyDiffHandler(w http.ResponseWriter, r *http.Request) {
+	if !api.historyEnabled(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	from, ok := api.lookupHistoryEntry(w, r, query.Get("from"))
+	if !ok {
+		return
+	}
+	to, ok := api.lookupHistoryEntry(w, r, query.Get("to"))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, diffFindings(from.Findings, to.Findings))
+}
+
+// lookupHistoryEntry loads the entry with the given ID, writing an error
+// response and returning false if it is malformed or does not exist.
+func (api *BedrockConverseAPI) lookupHistoryEntry(w http.ResponseWriter, r *http.Request, rawID string) (HistoryEntry, bool) {
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil || id < 1 {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid history entry ID %q", rawID))
+		return HistoryEntry{}, false
+	}
+
+	e, err := api.History.get(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("History entry %d not found", id))
+		return HistoryEntry{}, false
+	}
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Failed to read analysis history", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read analysis history")
+		return HistoryEntry{}, false
+	}
+	return e, true
+}
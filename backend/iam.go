@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// iamPolicyResourceTypes are the resources whose policy attribute holds an
+// IAM policy document.
+var iamPolicyResourceTypes = []string{"aws_iam_policy", "aws_iam_role_policy", "aws_iam_user_policy"}
+
+// iamResourceNouns name the resource a service's ARNs usually identify, for
+// remediation hints.
+var iamResourceNouns = map[string]string{
+	"dynamodb":       "table",
+	"kms":            "key",
+	"lambda":         "function",
+	"s3":             "bucket",
+	"secretsmanager": "secret",
+	"sns":            "topic",
+	"sqs":            "queue",
+}
+
+// policyEvalContext evaluates policy attributes written with jsonencode.
+var policyEvalContext = &hcl.EvalContext{
+	Functions: map[string]function.Function{"jsonencode": stdlib.JSONEncodeFunc},
+}
+
+// stringList is an IAM policy element that may be a single string or a list.
+type stringList []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = stringList{s}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// policyStatement is one statement of an IAM policy document.
+type policyStatement struct {
+	Effect    string          `json:"Effect"`
+	Principal policyPrincipal `json:"Principal"`
+	Action    stringList      `json:"Action"`
+	Resource  stringList      `json:"Resource"`
+	// Condition maps condition operators, such as StringEquals, to their
+	// keys and values.
+	Condition map[string]json.RawMessage `json:"Condition"`
+}
+
+// policyStatements is the Statement element, which may be a single
+// statement or a list.
+type policyStatements []policyStatement
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *policyStatements) UnmarshalJSON(data []byte) error {
+	var one policyStatement
+	if err := json.Unmarshal(data, &one); err == nil {
+		*s = policyStatements{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]policyStatement)(s))
+}
+
+// policyDocument is an IAM policy document.
+type policyDocument struct {
+	Statement policyStatements `json:"Statement"`
+}
+
+// iamAnalyzer flags wildcard actions and resources in IAM policies declared
+// inline in the file or in aws_iam_policy_document data sources.
+type iamAnalyzer struct{}
+
+// Analyze implements Analyzer. Policies built from variables or locals
+// cannot be evaluated and are left to the agent.
+func (iamAnalyzer) Analyze(_ context.Context, tf TerraformFile) ([]Finding, error) {
+	var findings []Finding
+	for _, b := range tf.Resources {
+		if !slices.Contains(iamPolicyResourceTypes, b.Type) {
+			continue
+		}
+		doc, ok := policyAttribute(b)
+		if !ok {
+			continue
+		}
+		for _, st := range doc.Statement {
+			findings = append(findings, statementFindings(b, st)...)
+		}
+	}
+	for _, b := range tf.DataSources {
+		if b.Type != "aws_iam_policy_document" || b.Body == nil {
+			continue
+		}
+		for _, st := range policyDocumentStatements(b) {
+			findings = append(findings, statementFindings(b, st)...)
+		}
+	}
+	return findings, nil
+}
+
+// policyDocumentStatements returns the statement blocks of an
+// aws_iam_policy_document data source whose actions can be read. Resources
+// given by reference are named by their Terraform expression.
+func policyDocumentStatements(b TerraformBlock) []policyStatement {
+	var statements []policyStatement
+	for _, block := range b.Body.Blocks {
+		if block.Type != "statement" {
+			continue
+		}
+		st := TerraformBlock{Body: block.Body}
+		effect := literalString(st, "effect")
+		if _, set := block.Body.Attributes["effect"]; !set {
+			effect = "Allow"
+		}
+		actions, ok := policyDocumentList(block.Body, "actions")
+		if !ok {
+			continue
+		}
+		resources, _ := policyDocumentList(block.Body, "resources")
+		statement := policyStatement{Effect: effect, Action: actions, Resource: resources}
+		for _, nested := range block.Body.Blocks {
+			switch nested.Type {
+			case "principals":
+				identifiers, _ := policyDocumentList(nested.Body, "identifiers")
+				if statement.Principal == nil {
+					statement.Principal = policyPrincipal{}
+				}
+				kind := literalString(TerraformBlock{Body: nested.Body}, "type")
+				statement.Principal[kind] = append(statement.Principal[kind], identifiers...)
+			case "condition":
+				if statement.Condition == nil {
+					statement.Condition = map[string]json.RawMessage{}
+				}
+				statement.Condition[literalString(TerraformBlock{Body: nested.Body}, "test")] = nil
+			}
+		}
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+// policyDocumentList returns the elements of list attribute name in body.
+// Literal strings are returned as is and references as their traversal,
+// such as aws_s3_bucket.logs.arn; other expressions are skipped. It reports
+// false if no element could be read.
+func policyDocumentList(body *hclsyntax.Body, name string) (stringList, bool) {
+	attr, ok := body.Attributes[name]
+	if !ok {
+		return nil, false
+	}
+	elems := []hclsyntax.Expression{attr.Expr}
+	if tuple, ok := attr.Expr.(*hclsyntax.TupleConsExpr); ok {
+		elems = tuple.Exprs
+	}
+
+	var values stringList
+	for _, expr := range elems {
+		if traversal, ok := expr.(*hclsyntax.ScopeTraversalExpr); ok {
+			values = append(values, traversalString(traversal.Traversal))
+			continue
+		}
+		if len(expr.Variables()) > 0 {
+			continue
+		}
+		v, diags := expr.Value(nil)
+		if diags.HasErrors() || !v.IsWhollyKnown() || v.IsNull() || v.Type() != cty.String {
+			continue
+		}
+		values = append(values, v.AsString())
+	}
+	return values, len(values) > 0
+}
+
+// traversalString formats a reference such as var.arns or
+// aws_s3_bucket.logs.arn.
+func traversalString(traversal hcl.Traversal) string {
+	parts := []string{traversal.RootName()}
+	for _, step := range traversal[1:] {
+		switch step := step.(type) {
+		case hcl.TraverseAttr:
+			parts = append(parts, step.Name)
+		case hcl.TraverseIndex:
+			switch step.Key.Type() {
+			case cty.String:
+				parts[len(parts)-1] += "[" + strconv.Quote(step.Key.AsString()) + "]"
+			case cty.Number:
+				parts[len(parts)-1] += "[" + step.Key.AsBigFloat().Text('f', -1) + "]"
+			}
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// policyAttribute parses the policy attribute of b when it is a literal JSON
+// string or a jsonencode call on a literal object.
+func policyAttribute(b TerraformBlock) (policyDocument, bool) {
+	var doc policyDocument
+	if b.Body == nil {
+		return doc, false
+	}
+	attr, ok := b.Body.Attributes["policy"]
+	if !ok || len(attr.Expr.Variables()) > 0 {
+		return doc, false
+	}
+	v, diags := attr.Expr.Value(policyEvalContext)
+	if diags.HasErrors() || !v.IsWhollyKnown() || v.IsNull() || v.Type() != cty.String {
+		return doc, false
+	}
+	if err := json.Unmarshal([]byte(v.AsString()), &doc); err != nil {
+		return doc, false
+	}
+	return doc, true
+}
+
+// statementFindings reports the wildcards an Allow statement grants.
+func statementFindings(b TerraformBlock, st policyStatement) []Finding {
+	if !strings.EqualFold(st.Effect, "Allow") {
+		return nil
+	}
+	allResources := slices.Contains(st.Resource, "*")
+	scope := "on all resources"
+	switch {
+	case len(st.Resource) == 0:
+		scope = "on the statement's resources"
+	case !allResources:
+		scope = "on " + strings.Join(st.Resource, ", ")
+	}
+
+	var findings []Finding
+	var scoped []string
+	for _, action := range st.Action {
+		service, name, _ := strings.Cut(action, ":")
+		switch {
+		case action == "*":
+			severity := SeverityHigh
+			if allResources {
+				severity = SeverityCritical
+			}
+			findings = append(findings, Finding{
+				Severity:     severity,
+				ResourceType: b.Type,
+				RuleID:       "IAM.1",
+				Description:  fmt.Sprintf("Policy in %s grants all actions %s; should list only the actions the principal needs.", b, scope),
+			})
+		case name == "*":
+			severity := SeverityMedium
+			hint := "should list only the " + service + " actions the principal needs."
+			if allResources {
+				severity = SeverityHigh
+				hint = "should be scoped to specific " + iamResourceNoun(service) + " ARN."
+			}
+			findings = append(findings, Finding{
+				Severity:     severity,
+				ResourceType: b.Type,
+				RuleID:       "IAM.21",
+				Description:  fmt.Sprintf("Policy in %s grants %s %s; %s", b, action, scope, hint),
+			})
+		case allResources:
+			scoped = append(scoped, action)
+		}
+	}
+	if len(scoped) > 0 {
+		findings = append(findings, Finding{
+			Severity:     SeverityMedium,
+			ResourceType: b.Type,
+			RuleID:       "IAM.RESOURCE.1",
+			Description:  fmt.Sprintf("Policy in %s grants %s on all resources; should be scoped to specific resource ARNs.", b, strings.Join(scoped, ", ")),
+		})
+	}
+	return findings
+}
+
+// iamResourceNoun names the resource a service's ARNs identify.
+func iamResourceNoun(service string) string {
+	if noun, ok := iamResourceNouns[service]; ok {
+		return noun
+	}
+	return "resource"
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// awsManagedPolicies are the statements of AWS managed policies commonly
+// attached by ARN, which cannot be read from the file.
+var awsManagedPolicies = map[string][]policyStatement{
+	"arn:aws:iam::aws:policy/AdministratorAccess": {{Effect: "Allow", Action: stringList{"*"}, Resource: stringList{"*"}}},
+	"arn:aws:iam::aws:policy/AmazonS3FullAccess":  {{Effect: "Allow", Action: stringList{"s3:*"}, Resource: stringList{"*"}}},
+	"arn:aws:iam::aws:policy/IAMFullAccess":       {{Effect: "Allow", Action: stringList{"iam:*"}, Resource: stringList{"*"}}},
+}
+
+// permissionCombination is a set of actions that together allow more than
+// least privilege permits, even when each is justified on its own.
+type permissionCombination struct {
+	Actions  []string
+	Severity string
+	Risk     string
+	// AnyResource reports that the combination is dangerous whatever
+	// resources it is granted on, rather than only on all resources.
+	AnyResource bool
+}
+
+// permissionCombinations are the combinations /analyze/iam-simulate flags.
+var permissionCombinations = []permissionCombination{
+	{Actions: []string{"s3:GetObject", "s3:DeleteObject"}, Severity: SeverityHigh, Risk: "read and then delete any object in any bucket, enabling exfiltration and destruction of data"},
+	{Actions: []string{"s3:GetObject", "s3:PutBucketPolicy"}, Severity: SeverityHigh, Risk: "read any object and open any bucket to other accounts"},
+	{Actions: []string{"kms:Decrypt", "s3:GetObject"}, Severity: SeverityHigh, Risk: "read and decrypt any object, including ones protected with customer managed keys"},
+	{Actions: []string{"secretsmanager:GetSecretValue", "secretsmanager:PutSecretValue"}, Severity: SeverityHigh, Risk: "read and overwrite any secret"},
+	{Actions: []string{"iam:PassRole", "ec2:RunInstances"}, Severity: SeverityCritical, Risk: "launch an instance with any role passed to it, escalating to that role's permissions", AnyResource: true},
+	{Actions: []string{"iam:PassRole", "lambda:CreateFunction", "lambda:InvokeFunction"}, Severity: SeverityCritical, Risk: "create and invoke a function running as any role passed to it, escalating to that role's permissions", AnyResource: true},
+	{Actions: []string{"iam:PassRole", "cloudformation:CreateStack"}, Severity: SeverityCritical, Risk: "create a stack that runs as any role passed to it, escalating to that role's permissions", AnyResource: true},
+	{Actions: []string{"iam:CreatePolicyVersion", "iam:SetDefaultPolicyVersion"}, Severity: SeverityCritical, Risk: "rewrite any managed policy, including its own, to grant any permission"},
+	{Actions: []string{"iam:AttachRolePolicy", "sts:AssumeRole"}, Severity: SeverityCritical, Risk: "attach any policy to a role and then assume it"},
+}
+
+// RolePermissions is the effective permission set of one IAM role.
+type RolePermissions struct {
+	Role string `json:"role"`
+	// Policies are the inline, attached and managed policies the role's
+	// permissions were combined from.
+	Policies    []string          `json:"policies"`
+	Permissions []PermissionGrant `json:"permissions"`
+}
+
+// PermissionGrant is an action pattern a role is allowed, and where.
+type PermissionGrant struct {
+	Action    string   `json:"action"`
+	Resources []string `json:"resources"`
+}
+
+// IAMSimulateResponse defines the structure of the /analyze/iam-simulate
+// JSON response.
+type IAMSimulateResponse struct {
+	Roles    []RolePermissions `json:"roles"`
+	Findings []Finding         `json:"findings"`
+}
+
+// roleStatements are the statements of the policies attached to a role.
+type roleStatements struct {
+	policies   []string
+	statements []policyStatement
+}
+
+// collectRoleStatements combines, for each aws_iam_role in tf, the
+// statements of its inline_policy blocks and managed_policy_arns with
+// those of the aws_iam_role_policy and aws_iam_role_policy_attachment
+// resources referring to it. Policies that cannot be evaluated are skipped.
+func collectRoleStatements(tf *TerraformFile) (map[string]*roleStatements, []TerraformBlock) {
+	roles := make(map[string]*roleStatements)
+	var order []TerraformBlock
+	for _, b := range tf.Resources {
+		if b.Type != "aws_iam_role" || b.Body == nil {
+			continue
+		}
+		rs := &roleStatements{}
+		roles[b.Name] = rs
+		order = append(order, b)
+
+		for _, block := range b.Body.Blocks {
+			if block.Type != "inline_policy" {
+				continue
+			}
+			inline := TerraformBlock{Type: b.Type, Name: b.Name, Kind: b.Kind, Body: block.Body}
+			if statements, ok := policyStatementsOf(tf, inline); ok {
+				name := literalString(inline, "name")
+				if name == "" {
+					name = "inline_policy"
+				}
+				rs.add(b.String()+" "+name, statements)
+			}
+		}
+		if attr, ok := b.Body.Attributes["managed_policy_arns"]; ok {
+			elems := []hclsyntax.Expression{attr.Expr}
+			if tuple, ok := attr.Expr.(*hclsyntax.TupleConsExpr); ok {
+				elems = tuple.Exprs
+			}
+			for _, expr := range elems {
+				rs.attach(tf, expr)
+			}
+		}
+	}
+
+	for _, b := range tf.Resources {
+		if b.Body == nil {
+			continue
+		}
+		switch b.Type {
+		case "aws_iam_role_policy":
+			if rs := roles[roleReference(tf, b)]; rs != nil {
+				if statements, ok := policyStatementsOf(tf, b); ok {
+					rs.add(b.String(), statements)
+				}
+			}
+		case "aws_iam_role_policy_attachment":
+			if rs := roles[roleReference(tf, b)]; rs != nil {
+				if attr, ok := b.Body.Attributes["policy_arn"]; ok {
+					rs.attach(tf, attr.Expr)
+				}
+			}
+		}
+	}
+	return roles, order
+}
+
+// add records the statements of the named policy.
+func (rs *roleStatements) add(name string, statements []policyStatement) {
+	rs.policies = append(rs.policies, name)
+	rs.statements = append(rs.statements, statements...)
+}
+
+// attach records the policy a policy ARN expression refers to: an
+// aws_iam_policy in the file or a known AWS managed policy.
+func (rs *roleStatements) attach(tf *TerraformFile, expr hclsyntax.Expression) {
+	if traversal, ok := expr.(*hclsyntax.ScopeTraversalExpr); ok {
+		if policy, ok := referencedBlock(tf.Resources, "aws_iam_policy", traversal.Traversal); ok {
+			if statements, ok := policyStatementsOf(tf, policy); ok {
+				rs.add(policy.String(), statements)
+			}
+		}
+		return
+	}
+	arn, ok := literalExprString(expr)
+	if !ok {
+		return
+	}
+	if statements, ok := awsManagedPolicies[arn]; ok {
+		rs.add(arn, statements)
+	}
+}
+
+// policyStatementsOf returns the statements of b's policy attribute, read
+// as a literal document or from the aws_iam_policy_document data source it
+// refers to.
+func policyStatementsOf(tf *TerraformFile, b TerraformBlock) ([]policyStatement, bool) {
+	if doc, ok := policyAttribute(b); ok {
+		return doc.Statement, true
+	}
+	attr, ok := b.Body.Attributes["policy"]
+	if !ok {
+		return nil, false
+	}
+	traversal, ok := attr.Expr.(*hclsyntax.ScopeTraversalExpr)
+	if !ok || traversal.Traversal.RootName() != "data" || len(traversal.Traversal) < 3 {
+		return nil, false
+	}
+	doc, ok := referencedBlock(tf.DataSources, "aws_iam_policy_document", traversal.Traversal[1:])
+	if !ok {
+		return nil, false
+	}
+	return policyDocumentStatements(doc), true
+}
+
+// referencedBlock returns the block of the given type a traversal such as
+// aws_iam_policy.app.arn refers to.
+func referencedBlock(blocks []TerraformBlock, blockType string, traversal hcl.Traversal) (TerraformBlock, bool) {
+	var parts []string
+	for _, step := range traversal {
+		switch step := step.(type) {
+		case hcl.TraverseRoot:
+			parts = append(parts, step.Name)
+		case hcl.TraverseAttr:
+			parts = append(parts, step.Name)
+		}
+	}
+	if len(parts) < 2 || parts[0] != blockType {
+		return TerraformBlock{}, false
+	}
+	for _, b := range blocks {
+		if b.Type == blockType && b.Name == parts[1] && b.Body != nil {
+			return b, true
+		}
+	}
+	return TerraformBlock{}, false
+}
+
+// roleReference returns the local name of the aws_iam_role b's role
+// argument refers to, by reference or by the role's literal name.
+func roleReference(tf *TerraformFile, b TerraformBlock) string {
+	attr, ok := b.Body.Attributes["role"]
+	if !ok {
+		return ""
+	}
+	if traversal, ok := attr.Expr.(*hclsyntax.ScopeTraversalExpr); ok {
+		role, _ := referencedBlock(tf.Resources, "aws_iam_role", traversal.Traversal)
+		return role.Name
+	}
+	name, ok := literalExprString(attr.Expr)
+	if !ok {
+		return ""
+	}
+	for _, role := range tf.Resources {
+		if role.Type == "aws_iam_role" && role.Body != nil && literalString(role, "name") == name {
+			return role.Name
+		}
+	}
+	return ""
+}
+
+// literalExprString returns the value of a literal string expression.
+func literalExprString(expr hclsyntax.Expression) (string, bool) {
+	if len(expr.Variables()) > 0 {
+		return "", false
+	}
+	v, diags := expr.Value(nil)
+	if diags.HasErrors() || !v.IsWhollyKnown() || v.IsNull() || v.Type() != cty.String {
+		return "", false
+	}
+	return v.AsString(), true
+}
+
+// effectivePermissions merges the role's Allow statements into one grant
+// per action pattern, sorted by action.
+func (rs *roleStatements) effectivePermissions() []PermissionGrant {
+	resources := make(map[string][]string)
+	for _, st := range rs.statements {
+		if !strings.EqualFold(st.Effect, "Allow") {
+			continue
+		}
+		for _, action := range st.Action {
+			for _, resource := range st.Resource {
+				if !slices.Contains(resources[action], resource) {
+					resources[action] = append(resources[action], resource)
+				}
+			}
+		}
+	}
+
+	grants := []PermissionGrant{}
+	for _, action := range sortedKeys(resources) {
+		slices.Sort(resources[action])
+		grants = append(grants, PermissionGrant{Action: action, Resources: resources[action]})
+	}
+	return grants
+}
+
+// allows reports whether the role is allowed action, on all resources
+// unless anyResource is set, and not denied it on all resources.
+func (rs *roleStatements) allows(action string, anyResource bool) bool {
+	allowed := false
+	for _, st := range rs.statements {
+		if !slices.ContainsFunc(st.Action, func(pattern string) bool { return actionMatches(pattern, action) }) {
+			continue
+		}
+		allResources := slices.Contains(st.Resource, "*")
+		switch {
+		case strings.EqualFold(st.Effect, "Deny") && allResources:
+			return false
+		case strings.EqualFold(st.Effect, "Allow") && (allResources || anyResource && len(st.Resource) > 0):
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// actionMatches reports whether an IAM action pattern, which may contain
+// the * and ? wildcards, matches action. Actions are case-insensitive.
+func actionMatches(pattern, action string) bool {
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(action))
+	return err == nil && ok
+}
+
+// simulateIAM reports the effective permissions of each role in tf and the
+// least-privilege violations their combined policies create.
+func simulateIAM(tf *TerraformFile) IAMSimulateResponse {
+	resp := IAMSimulateResponse{Roles: []RolePermissions{}, Findings: []Finding{}}
+	roles, order := collectRoleStatements(tf)
+	for _, b := range order {
+		rs := roles[b.Name]
+		resp.Roles = append(resp.Roles, RolePermissions{
+			Role:        b.String(),
+			Policies:    append([]string{}, rs.policies...),
+			Permissions: rs.effectivePermissions(),
+		})
+
+		for _, combo := range permissionCombinations {
+			if !slices.ContainsFunc(combo.Actions, func(action string) bool { return !rs.allows(action, combo.AnyResource) }) {
+				scope := " on all resources"
+				if combo.AnyResource {
+					scope = ""
+				}
+				resp.Findings = append(resp.Findings, Finding{
+					Severity:     combo.Severity,
+					ResourceType: b.Type,
+					RuleID:       "IAM.COMBINATION.1",
+					Description:  fmt.Sprintf("%s combines %s%s across %s, which lets it %s; split the permissions across roles or scope them to specific resources.", b, strings.Join(combo.Actions, " and "), scope, strings.Join(rs.policies, ", "), combo.Risk),
+				})
+			}
+		}
+	}
+	return resp
+}
+
+// analyzeIAMSimulateHandler handles the /analyze/iam-simulate endpoint. It
+// combines the policies attached to each IAM role in the file and reports
+// permission combinations that violate least privilege. The analysis is
+// local and does not invoke the agent.
+func (api *BedrockConverseAPI) analyzeIAMSimulateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	var req AnalyzeRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	if req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query text is empty or not a string")
+		return
+	}
+
+	source, err := inputCode(req.Format, req.Code)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tf, ok := parseSource(r.Context(), w, "main.tf", source)
+	if !ok {
+		return
+	}
+
+	resp := simulateIAM(tf)
+	loggerFromContext(r.Context()).Info("Simulated IAM permissions", "roles", len(resp.Roles), "findings", len(resp.Findings))
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// errAnalysisAbandoned is the result shared when the leading request
+// returns without recording one.
+var errAnalysisAbandoned = errors.New("analysis abandoned")
+
+// inflightCall is an analysis in progress that identical requests wait on
+// instead of invoking the agent again.
+type inflightCall struct {
+	mu     sync.Mutex
+	chunks []string
+	// changed is closed and replaced whenever a chunk arrives or the call
+	// finishes, waking every waiter.
+	changed chan struct{}
+	done    bool
+	resp    AnalyzeResponse
+	err     error
+}
+
+// inflightGroup tracks the analyses in progress by cache key.
+type inflightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+// newInflightGroup returns an empty inflightGroup.
+func newInflightGroup() *inflightGroup {
+	return &inflightGroup{calls: make(map[string]*inflightCall)}
+}
+
+// join returns the call in progress for key. If there is none it starts
+// one and reports that the caller leads it: the caller must run the
+// analysis and pass its result to finish.
+func (g *inflightGroup) join(key string) (*inflightCall, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if call, ok := g.calls[key]; ok {
+		return call, false
+	}
+	call := &inflightCall{changed: make(chan struct{})}
+	g.calls[key] = call
+	return call, true
+}
+
+// finish records the result of the call led under key and releases its
+// waiters. Requests for key arriving afterwards start a new call.
+func (g *inflightGroup) finish(key string, call *inflightCall, resp AnalyzeResponse, err error) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	call.done, call.resp, call.err = true, resp, err
+	close(call.changed)
+}
+
+// publish relays a chunk of the agent response to the call's waiters.
+func (c *inflightCall) publish(chunk string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chunks = append(c.chunks, chunk)
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// wait blocks until the call finishes or ctx is done and returns its
+// result. If onChunk is non-nil it is called with every chunk of the agent
+// response, starting with those published before wait was called.
+func (c *inflightCall) wait(ctx context.Context, onChunk func(string)) (AnalyzeResponse, error) {
+	sent := 0
+	for {
+		c.mu.Lock()
+		chunks, changed, done := c.chunks[sent:], c.changed, c.done
+		resp, err := c.resp, c.err
+		c.mu.Unlock()
+
+		if onChunk != nil {
+			for _, chunk := range chunks {
+				onChunk(chunk)
+			}
+		}
+		sent += len(chunks)
+		if done {
+			return resp, err
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return AnalyzeResponse{}, ctx.Err()
+		}
+	}
+}
+
+// awaitAnalysis answers a request with the result of an identical analysis
+// led by another request, relaying the agent response as Server-Sent Events
+// when stream is set and as out asks otherwise. The leader's failures,
+// including its client disconnecting, are reported to the waiters too.
+func (api *BedrockConverseAPI) awaitAnalysis(w http.ResponseWriter, r *http.Request, logger *slog.Logger, call *inflightCall, stream bool, source string, tf *TerraformFile, fw Framework, out analysisOutput) {
+	if !stream {
+		resp, err := call.wait(r.Context(), nil)
+		if r.Context().Err() != nil {
+			return
+		}
+		if err != nil {
+			writeAnalysisError(w, err)
+			return
+		}
+		resp.Findings = sortFindings(resp.Findings, out.SortBy)
+		resp.AnalysisID = api.recordHistory(r, source, fw, resp.Findings)
+		w.Header().Set("ETag", analysisETag(resp))
+		api.writeAnalysis(w, out.Format, tf, fw, resp)
+		return
+	}
+
+	sse := newSSEWriter(w)
+	resp, err := call.wait(r.Context(), func(chunk string) {
+		if err := sse.send("", StreamChunk{Text: chunk}); err != nil {
+			logger.Warn("Failed to stream chunk to client", "error", err)
+		}
+	})
+	if r.Context().Err() != nil {
+		return
+	}
+	if err != nil {
+		if err := sse.send("error", ErrorResponse{Error: analysisErrorMessage(err)}); err != nil {
+			logger.Warn("Failed to stream error to client", "error", err)
+		}
+		return
+	}
+	resp.AnalysisID = api.recordHistory(r, source, fw, resp.Findings)
+	if err := sse.send("done", resp); err != nil {
+		logger.Warn("Failed to stream final event to client", "error", err)
+	}
+}
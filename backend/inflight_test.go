@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInflightJoinFinish(t *testing.T) {
+	g := newInflightGroup()
+	call, leader := g.join("key")
+	if !leader {
+		t.Fatal("first join does not lead")
+	}
+	if _, leader := g.join("other"); !leader {
+		t.Error("join for another key does not lead")
+	}
+	call.publish("early ")
+
+	const waiters = 3
+	var wg sync.WaitGroup
+	chunks := make([][]string, waiters)
+	results := make([]AnalyzeResponse, waiters)
+	errs := make([]error, waiters)
+	for i := range waiters {
+		joined, leader := g.join("key")
+		if leader || joined != call {
+			t.Fatalf("waiter %d did not join the call in progress", i)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = joined.wait(context.Background(), func(chunk string) {
+				chunks[i] = append(chunks[i], chunk)
+			})
+		}()
+	}
+
+	call.publish("late")
+	g.finish("key", call, AnalyzeResponse{Suggestion: "early late"}, nil)
+	wg.Wait()
+
+	for i := range waiters {
+		if errs[i] != nil {
+			t.Errorf("waiter %d: error %v", i, errs[i])
+		}
+		if results[i].Suggestion != "early late" {
+			t.Errorf("waiter %d: suggestion %q, want %q", i, results[i].Suggestion, "early late")
+		}
+		if !slices.Equal(chunks[i], []string{"early ", "late"}) {
+			t.Errorf("waiter %d: chunks %q, want both chunks in order", i, chunks[i])
+		}
+	}
+
+	if next, leader := g.join("key"); !leader || next == call {
+		t.Error("join after finish does not start a new call")
+	}
+}
+
+func TestInflightWaitErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cancel  bool
+		err     error
+		wantErr error
+	}{
+		{"leader failure is shared", false, errAnalysisAbandoned, errAnalysisAbandoned},
+		{"waiter gives up when its context is done", true, nil, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newInflightGroup()
+			call, _ := g.join("key")
+			waiter, _ := g.join("key")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			go func() {
+				_, err := waiter.wait(ctx, nil)
+				done <- err
+			}()
+
+			if tt.cancel {
+				cancel()
+			} else {
+				g.finish("key", call, AnalyzeResponse{}, tt.err)
+			}
+			select {
+			case err := <-done:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("wait error = %v, want %v", err, tt.wantErr)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("wait did not return")
+			}
+		})
+	}
+}
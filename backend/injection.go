@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// errPromptInjection is the error code of a request rejected as a prompt
+// injection attempt.
+const errPromptInjection = "prompt_injection_detected"
+
+// InjectionPattern is a named regular expression matching text that tries
+// to override the agent's instructions.
+type InjectionPattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+
+	re *regexp.Regexp
+}
+
+// defaultInjectionPatterns are used when PROMPT_INJECTION_PATTERNS_FILE is
+// not set.
+var defaultInjectionPatterns = []InjectionPattern{
+	{Name: "instruction override", Pattern: `(?i)\b(?:ignore|disregard|forget|override)\s+(?:all\s+|any\s+)?(?:of\s+)?(?:the\s+|your\s+)?(?:previous|prior|above|earlier|preceding|system)\s+(?:instructions|prompts?|rules|directions|guidelines)`},
+	{Name: "new instructions", Pattern: `(?i)\b(?:new|updated|real)\s+instructions\s*:`},
+	{Name: "system prompt leakage", Pattern: `(?i)\b(?:reveal|print|output|show|repeat|display|leak|tell\s+me)\s+(?:me\s+)?(?:your|the)\s+(?:system\s+prompt|(?:initial|hidden|original)\s+(?:prompt|instructions)|instructions)`},
+	{Name: "role-play jailbreak", Pattern: `(?i)\b(?:you\s+are\s+now|pretend\s+(?:to\s+be|you\s+are)|act\s+as)\s+(?:an?\s+)?(?:unrestricted|unfiltered|jailbroken|DAN\b|different\s+(?:AI|assistant|model))`},
+	{Name: "developer mode", Pattern: `(?i)\b(?:developer|jailbreak|DAN)\s+mode\b`},
+}
+
+// injectionDetector flags text matching any of its patterns.
+type injectionDetector struct {
+	patterns []InjectionPattern
+}
+
+// loadInjectionDetector returns a detector using the patterns in the JSON
+// file at path, or the defaults if path is empty.
+func loadInjectionDetector(path string) (*injectionDetector, error) {
+	patterns := defaultInjectionPatterns
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt injection patterns: %w", err)
+		}
+		patterns = nil
+		if err := json.Unmarshal(data, &patterns); err != nil {
+			return nil, fmt.Errorf("failed to decode prompt injection patterns: %w", err)
+		}
+	}
+
+	compiled := make([]InjectionPattern, len(patterns))
+	for i, p := range patterns {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prompt injection pattern %q: %w", p.Name, err)
+		}
+		p.re = re
+		compiled[i] = p
+	}
+	return &injectionDetector{patterns: compiled}, nil
+}
+
+// detect returns a description of the first injection pattern matched by
+// any of texts, and whether one matched.
+func (d *injectionDetector) detect(texts ...string) (string, bool) {
+	for _, text := range texts {
+		for _, p := range d.patterns {
+			if loc := p.re.FindStringIndex(text); loc != nil {
+				return fmt.Sprintf("Input matches the %s pattern on line %d", p.Name, strings.Count(text[:loc[0]], "\n")+1), true
+			}
+		}
+	}
+	return "", false
+}
+
+// checkPromptInjection reports whether any of texts looks like a prompt
+// injection attempt, counting and logging the attempt if so.
+func (api *BedrockConverseAPI) checkPromptInjection(logger *slog.Logger, texts ...string) (string, bool) {
+	detail, ok := api.Injection.detect(texts...)
+	if ok {
+		injectionAttempts.Inc()
+		logger.Warn("Rejected suspected prompt injection", "detail", detail)
+	}
+	return detail, ok
+}
+
+// rejectPromptInjection writes a 400 response and returns true if any of
+// texts looks like a prompt injection attempt. It must be called before
+// the texts are forwarded to the agent.
+func (api *BedrockConverseAPI) rejectPromptInjection(w http.ResponseWriter, logger *slog.Logger, texts ...string) bool {
+	detail, ok := api.checkPromptInjection(logger, texts...)
+	if ok {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: errPromptInjection, Detail: detail})
+	}
+	return ok
+}
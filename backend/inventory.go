@@ -0,0 +1,60 @@
+package main
+
+import "net/http"
+
+// InventoryResponse defines the structure of the /inventory JSON response.
+type InventoryResponse struct {
+	Resources   []TerraformBlock `json:"resources"`
+	DataSources []TerraformBlock `json:"data_sources"`
+	Modules     []TerraformBlock `json:"modules"`
+	Providers   []TerraformBlock `json:"providers"`
+	Variables   []TerraformBlock `json:"variables"`
+	Locals      []string         `json:"locals"`
+}
+
+// inventoryHandler handles the /inventory endpoint. It lists the blocks the
+// parser found in the submitted code without invoking Bedrock.
+func (api *BedrockConverseAPI) inventoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	var req AnalyzeRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	if req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query text is empty or not a string")
+		return
+	}
+
+	source, err := inputCode(req.Format, req.Code)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tf, ok := parseSource(r.Context(), w, "main.tf", source)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, InventoryResponse{
+		Resources:   nonNil(tf.Resources),
+		DataSources: nonNil(tf.DataSources),
+		Modules:     nonNil(tf.Modules),
+		Providers:   nonNil(tf.Providers),
+		Variables:   nonNil(tf.Variables),
+		Locals:      nonNil(tf.Locals),
+	})
+}
+
+// nonNil returns s, or an empty slice if s is nil, so it encodes as [] rather than null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
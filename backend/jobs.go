@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Job statuses reported by /jobs/{job_id}.
+const (
+	jobPending = "pending"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+const (
+	// jobWorkers is the number of background goroutines processing queued analyses.
+	jobWorkers = 2
+	// jobQueueSize bounds the number of analyses waiting for a worker.
+	jobQueueSize = 100
+)
+
+// JobAcceptedResponse is returned by /analyze/async when a job is queued.
+type JobAcceptedResponse struct {
+	JobID string `json:"job_id"`
+}
+
+// JobResponse defines the structure of the /jobs/{job_id} JSON response.
+type JobResponse struct {
+	JobID  string           `json:"job_id"`
+	Status string           `json:"status"`
+	Result *AnalyzeResponse `json:"result,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+type job struct {
+	JobResponse
+	expiresAt time.Time
+	// callbackURL receives the result when the job finishes; deliveries
+	// records each attempt to send it.
+	callbackURL string
+	deliveries  []WebhookDelivery
+}
+
+// jobStore holds asynchronous analysis jobs until they expire and feeds
+// queued work to a fixed pool of workers.
+type jobStore struct {
+	mu    sync.Mutex
+	jobs  map[string]*job
+	ttl   time.Duration
+	queue chan func()
+}
+
+// newJobStore returns an empty store whose jobs expire ttl after creation.
+func newJobStore(ttl time.Duration) *jobStore {
+	return &jobStore{
+		jobs:  make(map[string]*job),
+		ttl:   ttl,
+		queue: make(chan func(), jobQueueSize),
+	}
+}
+
+// startWorkers launches n goroutines that run queued jobs.
+func (s *jobStore) startWorkers(n int) {
+	for range n {
+		go func() {
+			for run := range s.queue {
+				run()
+			}
+		}()
+	}
+}
+
+// create registers a new job with the given status and optional
+// callback URL.
+func (s *jobStore) create(id, status, callbackURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[id] = &job{
+		JobResponse: JobResponse{JobID: id, Status: status},
+		expiresAt:   time.Now().Add(s.ttl),
+		callbackURL: callbackURL,
+	}
+}
+
+// update applies fn to the job with the given ID, if it has not expired.
+func (s *jobStore) update(id string, fn func(*JobResponse)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[id]; ok {
+		fn(&j.JobResponse)
+	}
+}
+
+// get returns a snapshot of the job with the given ID.
+func (s *jobStore) get(id string) (JobResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok || time.Now().After(j.expiresAt) {
+		return JobResponse{}, false
+	}
+	return j.JobResponse, true
+}
+
+// callback returns a snapshot of the job with the given ID and its
+// callback URL.
+func (s *jobStore) callback(id string) (JobResponse, string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return JobResponse{}, "", false
+	}
+	return j.JobResponse, j.callbackURL, true
+}
+
+// recordDelivery appends a webhook delivery attempt to the job's history.
+func (s *jobStore) recordDelivery(id string, d WebhookDelivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[id]; ok {
+		j.deliveries = append(j.deliveries, d)
+	}
+}
+
+// deliveries returns the callback URL and webhook delivery history of the
+// job with the given ID.
+func (s *jobStore) deliveries(id string) (string, []WebhookDelivery, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok || time.Now().After(j.expiresAt) {
+		return "", nil, false
+	}
+	return j.callbackURL, slices.Clone(j.deliveries), true
+}
+
+// remove deletes the job with the given ID.
+func (s *jobStore) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+}
+
+// enqueue schedules run on a worker, reporting false if the queue is full.
+func (s *jobStore) enqueue(run func()) bool {
+	select {
+	case s.queue <- run:
+		return true
+	default:
+		return false
+	}
+}
+
+// cleanupLoop periodically drops expired jobs. It never returns.
+func (s *jobStore) cleanupLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := time.Now()
+		s.mu.Lock()
+		for id, j := range s.jobs {
+			if now.After(j.expiresAt) {
+				delete(s.jobs, id)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// analyzeAsyncHandler handles the /analyze/async endpoint. The analysis is
+// queued and the client polls /jobs/{job_id} for the result, so slow agent
+// invocations never hold the HTTP connection open.
+func (api *BedrockConverseAPI) analyzeAsyncHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	logger := loggerFromContext(r.Context())
+
+	var req AnalyzeRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	if req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query text is empty or not a string")
+		return
+	}
+	if api.rejectPromptInjection(w, logger, req.Code) {
+		return
+	}
+
+	fw, err := lookupFramework(req.Framework)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	detectedFormat := req.resolveFormat()
+	source, err := inputCode(req.Format, req.Code)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := req.mergeTerragruntInputs(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateVariables(req.Variables); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	workspace, err := resolveWorkspace(req.Workspace)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	source = substituteWorkspace(source, workspace)
+	if err := validateMaxSuggestions(req.MaxSuggestions); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.CallbackURL != "" {
+		if api.Config().WebhookSecret == "" {
+			writeJSONError(w, http.StatusBadRequest, "callback_url is not supported: WEBHOOK_SECRET is not configured")
+			return
+		}
+		if err := validateCallbackURL(req.CallbackURL); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	tf, ok := parseSource(r.Context(), w, "main.tf", source)
+	if !ok {
+		return
+	}
+	if source, tf, ok = applyResourceAllowlist(r.Context(), w, logger, source, tf, req.AllowedResourceTypes); !ok {
+		return
+	}
+	if req.Framework == "" {
+		fw = detectFramework(tf)
+	}
+
+	sessionID, ok := api.requestSessionID(w, r)
+	if !ok {
+		return
+	}
+
+	jobID, err := newUUID()
+	if err != nil {
+		logger.Error("Error generating job ID", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create job")
+		return
+	}
+	logger = logger.With("agent_id", api.Config().agentFor(fw.ID).AgentID, "session_id", sessionID, "framework", fw.ID, "job_id", jobID)
+
+	variableWarnings := api.applyVariables(logger, tf, req.Variables)
+	api.applyWorkspace(tf, workspace)
+	tf.MaxSuggestions = req.MaxSuggestions
+
+	// A job whose allowlist filtered out every resource is done at once.
+	if len(req.AllowedResourceTypes) > 0 && len(tf.Resources) == 0 {
+		logger.Info("No resources match allowed_resource_types, skipping analysis")
+		resp := noMatchingResourcesResponse(tf)
+		resp.DetectedFormat = detectedFormat
+		api.Jobs.create(jobID, jobDone, req.CallbackURL)
+		api.Jobs.update(jobID, func(j *JobResponse) { j.Result = &resp })
+		api.notifyJob(context.WithValue(context.WithoutCancel(r.Context()), loggerKey{}, logger), jobID)
+		api.writeJobAccepted(w, jobID)
+		return
+	}
+
+	key := api.analysisCacheKey(source, tf, fw.ID)
+	if cached, ok := api.Cache.Get(key); ok {
+		cacheHits.Inc()
+		logger.Info("Serving analysis from cache")
+		w.Header().Set(cacheHeader, "HIT")
+		api.Jobs.create(jobID, jobDone, req.CallbackURL)
+		cached.Metrics = cached.Metrics.fromCache()
+		cached.Format, cached.FormatNote = translationNote(req.Format)
+		cached.DetectedFormat = detectedFormat
+		api.Jobs.update(jobID, func(j *JobResponse) { j.Result = &cached })
+		api.notifyJob(context.WithValue(context.WithoutCancel(r.Context()), loggerKey{}, logger), jobID)
+		api.writeJobAccepted(w, jobID)
+		return
+	}
+	cacheMisses.Inc()
+	w.Header().Set(cacheHeader, "MISS")
+
+	secretWarnings := api.redactSource(logger, tf)
+	base := AnalyzeResponse{SecretWarnings: secretWarnings, VariableWarnings: variableWarnings, RedactedCount: tf.RedactedCount, Metrics: tf.analysisMetrics()}
+	base.Format, base.FormatNote = translationNote(req.Format)
+	base.DetectedFormat = detectedFormat
+	base.RenamedResources = tf.renamedResources()
+
+	api.Jobs.create(jobID, jobPending, req.CallbackURL)
+	queued := api.Jobs.enqueue(func() {
+		ctx := context.WithValue(context.WithoutCancel(r.Context()), loggerKey{}, logger)
+		api.runAnalysisJob(ctx, jobID, key, tf, &analysisRequest{Framework: fw, SessionID: sessionID}, base)
+	})
+	if !queued {
+		api.Jobs.remove(jobID)
+		logger.Warn("Job queue is full, rejecting analysis")
+		writeJSONError(w, http.StatusServiceUnavailable, "Job queue is full, try again later")
+		return
+	}
+
+	logger.Info("Queued asynchronous analysis")
+	api.writeJobAccepted(w, jobID)
+}
+
+// writeJobAccepted writes the 202 response pointing the client at its job.
+func (api *BedrockConverseAPI) writeJobAccepted(w http.ResponseWriter, jobID string) {
+	w.Header().Set("Location", "/jobs/"+jobID)
+	writeJSON(w, http.StatusAccepted, JobAcceptedResponse{JobID: jobID})
+}
+
+// runAnalysisJob runs the analyzers for a queued job and records the
+// outcome, base completed with the findings, notifying the job's callback
+// URL. ctx must outlive the request that created the job.
+func (api *BedrockConverseAPI) runAnalysisJob(ctx context.Context, jobID, key string, tf *TerraformFile, req *analysisRequest, base AnalyzeResponse) {
+	api.Jobs.update(jobID, func(j *JobResponse) { j.Status = jobRunning })
+	defer api.notifyJob(ctx, jobID)
+
+	findings, err := runAnalyzers(withAnalysisRequest(ctx, req), *tf)
+	if err != nil {
+		api.Jobs.update(jobID, func(j *JobResponse) { j.Status, j.Error = jobFailed, analysisErrorMessage(err) })
+		return
+	}
+
+	resp := base
+	resp.Suggestion, resp.Findings, resp.Truncated = req.Suggestion, findings, req.Truncated
+	resp.Chunked, resp.ChunkCount = req.Chunks > 1, req.Chunks
+	resp.Metrics.recordAgent(req.PromptTokens, req.Latency)
+	resp.Metrics.DuplicatesRemoved = req.DuplicatesRemoved
+	api.Cache.Add(key, resp)
+	api.Jobs.update(jobID, func(j *JobResponse) { j.Status, j.Result = jobDone, &resp })
+	loggerFromContext(ctx).Info("Asynchronous analysis finished")
+}
+
+// jobHandler handles the /jobs/{job_id} endpoint.
+func (api *BedrockConverseAPI) jobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	j, ok := api.Jobs.get(r.PathValue("job_id"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Job not found or expired")
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
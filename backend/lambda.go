@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// LambdaFunction holds the security-relevant settings of an
+// aws_lambda_function.
+type LambdaFunction struct {
+	// Resource is the address of the function.
+	Resource string
+	// Runtime and Handler are empty when not literal strings.
+	Runtime string
+	Handler string
+	// EnvironmentKeys are the names of the environment variables, sorted.
+	EnvironmentKeys []string
+	// KMSKey, VPCConfig, DeadLetterConfig and CodeSigning report whether
+	// the function sets kms_key_arn, vpc_config, dead_letter_config and
+	// code_signing_config_arn.
+	KMSKey           bool
+	VPCConfig        bool
+	DeadLetterConfig bool
+	CodeSigning      bool
+	// TracingMode is tracing_config's mode, empty when it is not set.
+	TracingMode string
+	// ReservedConcurrency is reserved_concurrent_executions, with
+	// ReservedConcurrencyKnown false when it is unset or not a literal
+	// number. -1 means unreserved.
+	ReservedConcurrency      int
+	ReservedConcurrencyKnown bool
+	Line                     int
+}
+
+// sensitiveEnvironmentNames are the name fragments of environment variables
+// that likely hold credentials.
+var sensitiveEnvironmentNames = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "CREDENTIAL", "KEY"}
+
+// sensitiveEnvironmentKeys returns the function's environment variables
+// whose names suggest they hold credentials.
+func (f LambdaFunction) sensitiveEnvironmentKeys() []string {
+	var keys []string
+	for _, key := range f.EnvironmentKeys {
+		upper := strings.ToUpper(key)
+		if slices.ContainsFunc(sensitiveEnvironmentNames, func(name string) bool { return strings.Contains(upper, name) }) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// lambdaFunction extracts the settings of a resource block, reporting
+// false for resources other than aws_lambda_function.
+func lambdaFunction(b TerraformBlock) (LambdaFunction, bool) {
+	if b.Type != "aws_lambda_function" {
+		return LambdaFunction{}, false
+	}
+	f := LambdaFunction{
+		Resource:         b.Address(),
+		Runtime:          literalString(b, "runtime"),
+		Handler:          literalString(b, "handler"),
+		KMSKey:           hasAttribute(b.Body, "kms_key_arn"),
+		CodeSigning:      hasAttribute(b.Body, "code_signing_config_arn"),
+		VPCConfig:        hasNestedBlock(b, "vpc_config"),
+		DeadLetterConfig: hasNestedBlock(b, "dead_letter_config"),
+		Line:             b.Line,
+	}
+	f.ReservedConcurrency, f.ReservedConcurrencyKnown = literalInt(b, "reserved_concurrent_executions")
+	for _, nested := range b.Body.Blocks {
+		switch nested.Type {
+		case "environment":
+			f.EnvironmentKeys = objectKeys(nested.Body, "variables")
+		case "tracing_config":
+			f.TracingMode = literalString(TerraformBlock{Body: nested.Body}, "mode")
+		}
+	}
+	return f, true
+}
+
+// hasAttribute reports whether body sets attribute name.
+func hasAttribute(body *hclsyntax.Body, name string) bool {
+	_, ok := body.Attributes[name]
+	return ok
+}
+
+// objectKeys returns the literal keys of the object attribute name, sorted.
+// Values need not be literal, so variables referencing secrets are listed.
+func objectKeys(body *hclsyntax.Body, name string) []string {
+	attr, ok := body.Attributes[name]
+	if !ok {
+		return nil
+	}
+	object, ok := attr.Expr.(*hclsyntax.ObjectConsExpr)
+	if !ok {
+		return nil
+	}
+	var keys []string
+	for _, item := range object.Items {
+		if k, diags := item.KeyExpr.Value(nil); !diags.HasErrors() && k.Type() == cty.String && k.IsKnown() && !k.IsNull() {
+			keys = append(keys, k.AsString())
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// lambdaTable formats functions as a table for the analysis prompt.
+func lambdaTable(functions []LambdaFunction) string {
+	setting := func(set bool) string {
+		if set {
+			return "yes"
+		}
+		return "no"
+	}
+	orUnknown := func(s string) string {
+		if s == "" {
+			return "?"
+		}
+		return s
+	}
+
+	var sb strings.Builder
+	sb.WriteString("| resource | runtime | handler | environment_variables | kms_key_arn | vpc_config | dead_letter_config | code_signing_config_arn | tracing_mode | reserved_concurrency | line |\n")
+	sb.WriteString("|---|---|---|---|---|---|---|---|---|---|---|\n")
+	for _, f := range functions {
+		tracing := f.TracingMode
+		if tracing == "" {
+			tracing = "none"
+		}
+		concurrency := "?"
+		if f.ReservedConcurrencyKnown {
+			concurrency = strconv.Itoa(f.ReservedConcurrency)
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %d |\n",
+			f.Resource, orUnknown(f.Runtime), orUnknown(f.Handler), strings.Join(f.EnvironmentKeys, ", "),
+			setting(f.KMSKey), setting(f.VPCConfig), setting(f.DeadLetterConfig), setting(f.CodeSigning),
+			tracing, concurrency, f.Line)
+	}
+	return sb.String()
+}
+
+// lambdaAnalyzer flags Lambda functions whose environment variables appear
+// to hold credentials but are not encrypted with a customer managed KMS key.
+type lambdaAnalyzer struct{}
+
+// Analyze implements Analyzer.
+func (lambdaAnalyzer) Analyze(_ context.Context, tf TerraformFile) ([]Finding, error) {
+	var findings []Finding
+	for _, f := range tf.LambdaFunctions {
+		if f.KMSKey {
+			continue
+		}
+		keys := f.sensitiveEnvironmentKeys()
+		if len(keys) == 0 {
+			continue
+		}
+		findings = append(findings, Finding{
+			Severity:     SeverityHigh,
+			ResourceType: "aws_lambda_function",
+			RuleID:       "LAMBDA.ENV.1",
+			Description:  fmt.Sprintf("%s on line %d sets environment variables %s, which look like credentials, without kms_key_arn; encrypt them with a customer managed KMS key or read them from Secrets Manager", f.Resource, f.Line, strings.Join(keys, ", ")),
+		})
+	}
+	return findings, nil
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// localRule is a deterministic FSBP check evaluated without the agent.
+type localRule struct {
+	ID    string
+	Check func(tf TerraformFile) []Finding
+}
+
+// localRules are the built-in FSBP checks. IAM wildcards and security
+// groups opening administration ports are covered by the iam and
+// security-group analyzers. Encryption at rest of storage resources is
+// checked as described by encryptionAtRestSettings.
+var localRules = []localRule{
+	{"S3.2", s3PublicACL("public-read", "S3.2", SeverityCritical)},
+	{"S3.3", s3PublicACL("public-read-write", "S3.3", SeverityCritical)},
+	{"S3.4", checkEncryptionAtRest("aws_s3_bucket")},
+	{"S3.8", checkS3BlockPublicAccess},
+	{"RDS.2", requireBool("aws_db_instance", "publicly_accessible", false, false, "RDS.2", SeverityCritical, "should not be publicly accessible")},
+	{"RDS.3", checkEncryptionAtRest("aws_db_instance")},
+	{"RDS.7", requireBool("aws_rds_cluster", "deletion_protection", true, false, "RDS.7", SeverityLow, "should have deletion protection enabled")},
+	{"RDS.8", requireBool("aws_db_instance", "deletion_protection", true, false, "RDS.8", SeverityLow, "should have deletion protection enabled")},
+	{"RDS.11", checkRDSBackups},
+	{"RDS.13", requireBool("aws_db_instance", "auto_minor_version_upgrade", true, true, "RDS.13", SeverityHigh, "should have automatic minor version upgrades enabled")},
+	{"RDS.27", checkEncryptionAtRest("aws_rds_cluster")},
+	{"EC2.2", checkDefaultSecurityGroup},
+	{"EC2.3", checkEncryptionAtRest("aws_ebs_volume")},
+	{"EC2.6", checkVPCFlowLogs},
+	{"EC2.7", requireBool("aws_ebs_encryption_by_default", "enabled", true, true, "EC2.7", SeverityMedium, "should enable EBS default encryption")},
+	{"EC2.8", checkIMDSv2},
+	{"EC2.9", requireBool("aws_instance", "associate_public_ip_address", false, false, "EC2.9", SeverityHigh, "should not have a public IPv4 address")},
+	{"EC2.18", checkOpenAllTraffic},
+	{"CloudTrail.1", requireBool("aws_cloudtrail", "is_multi_region_trail", true, false, "CloudTrail.1", SeverityHigh, "should be a multi-Region trail")},
+	{"CloudTrail.2", requireAttribute("aws_cloudtrail", "kms_key_id", "CloudTrail.2", SeverityMedium, "should have encryption at rest enabled with a KMS key", `kms_key_id = aws_kms_key.cloudtrail.arn`)},
+	{"CloudTrail.4", requireBool("aws_cloudtrail", "enable_log_file_validation", true, false, "CloudTrail.4", SeverityLow, "should have log file validation enabled")},
+	{"EFS.1", checkEncryptionAtRest("aws_efs_file_system")},
+	{"ElastiCache.4", checkEncryptionAtRest("aws_elasticache_replication_group")},
+	{"DYNAMODB.SSE.1", checkEncryptionAtRest("aws_dynamodb_table")},
+	{"IAM.2", checkIAMUserPolicies},
+	{"IAM.7", checkPasswordPolicy},
+	{"KMS.4", requireBool("aws_kms_key", "enable_key_rotation", true, false, "KMS.4", SeverityMedium, "should have key rotation enabled")},
+	{"SQS.1", requireBool("aws_sqs_queue", "sqs_managed_sse_enabled", true, true, "SQS.1", SeverityMedium, "should be encrypted at rest")},
+}
+
+// localRulesAnalyzer runs the built-in FSBP checks when analyzing against
+// FSBP. They run alongside the agent, or alone in offline mode.
+type localRulesAnalyzer struct{}
+
+// Analyze implements Analyzer.
+func (localRulesAnalyzer) Analyze(ctx context.Context, tf TerraformFile) ([]Finding, error) {
+	if req, ok := analysisRequestFromContext(ctx); !ok || req.Framework.ID != "fsbp" {
+		return nil, nil
+	}
+	var findings []Finding
+	for _, rule := range localRules {
+		findings = append(findings, rule.Check(tf)...)
+	}
+	return findings, nil
+}
+
+// mergeFindings combines the agent's findings with the local analyzers',
+// dropping the agent's for a rule a local analyzer already reported on the
+// same block, since the local checks name the exact resource and setting,
+// and returns how many it dropped. Blocks are matched as findingBlock does,
+// so the agent's findings on blocks a local check left to it are kept.
+// Every finding is rated with its remediation effort and tagged with its
+// OWASP categories.
+func (tf *TerraformFile) mergeFindings(agent, local []Finding) ([]Finding, int) {
+	merged := []Finding{}
+	for _, f := range agent {
+		if f.RuleID == "" || !slices.ContainsFunc(local, func(l Finding) bool { return l.RuleID == f.RuleID && tf.sameBlock(f, l) }) {
+			merged = append(merged, f)
+		}
+	}
+	return withOWASPCategories(withRemediationEffort(append(merged, local...))), len(agent) - len(merged)
+}
+
+// sameBlock reports whether findings a and b refer to the same block of tf.
+func (tf *TerraformFile) sameBlock(a, b Finding) bool {
+	blockA, okA := findingBlock(tf, a)
+	blockB, okB := findingBlock(tf, b)
+	return okA && okB && blockA.Address() == blockB.Address()
+}
+
+// localFinding reports a local rule violation by resource b.
+func localFinding(b TerraformBlock, ruleID, severity, description, remediation string) Finding {
+	return Finding{
+		Severity:        severity,
+		ResourceType:    b.Type,
+		RuleID:          ruleID,
+		Description:     description,
+		RemediationCode: remediation,
+	}
+}
+
+// resourcesOfType returns the resources of tf with the given type that have
+// a body to inspect.
+func resourcesOfType(tf TerraformFile, resourceType string) []TerraformBlock {
+	var blocks []TerraformBlock
+	for _, b := range tf.Resources {
+		if b.Type == resourceType && b.Body != nil {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks
+}
+
+// requireBool reports resources of the given type whose boolean attribute
+// is not want. Unset attributes take the provider default, unsetDefault.
+// Attributes set to an expression are left to the agent.
+func requireBool(resourceType, attr string, want, unsetDefault bool, ruleID, severity, requirement string) func(tf TerraformFile) []Finding {
+	return func(tf TerraformFile) []Finding {
+		var findings []Finding
+		for _, b := range resourcesOfType(tf, resourceType) {
+			var problem string
+			if v, _, ok := literalValue(b, attr); ok && v.Type() == cty.Bool && v.True() != want {
+				problem = fmt.Sprintf("%s = %t", attr, !want)
+			} else if _, set := b.Body.Attributes[attr]; !set && unsetDefault != want {
+				problem = attr + " is not set"
+			} else {
+				continue
+			}
+			findings = append(findings, localFinding(b, ruleID, severity,
+				fmt.Sprintf("%s %s (%s)", b, requirement, problem),
+				fmt.Sprintf("%s = %t", attr, want)))
+		}
+		return findings
+	}
+}
+
+// requireAttribute reports resources of the given type that do not set attr.
+func requireAttribute(resourceType, attr, ruleID, severity, requirement, remediation string) func(tf TerraformFile) []Finding {
+	return func(tf TerraformFile) []Finding {
+		var findings []Finding
+		for _, b := range resourcesOfType(tf, resourceType) {
+			if _, set := b.Body.Attributes[attr]; set {
+				continue
+			}
+			findings = append(findings, localFinding(b, ruleID, severity,
+				fmt.Sprintf("%s %s (%s is not set)", b, requirement, attr), remediation))
+		}
+		return findings
+	}
+}
+
+// s3PublicACL reports buckets granted the canned ACL acl, inline or by an
+// aws_s3_bucket_acl resource.
+func s3PublicACL(acl, ruleID, severity string) func(tf TerraformFile) []Finding {
+	return func(tf TerraformFile) []Finding {
+		var findings []Finding
+		for _, resourceType := range []string{"aws_s3_bucket", "aws_s3_bucket_acl"} {
+			for _, b := range resourcesOfType(tf, resourceType) {
+				if literalString(b, "acl") != acl {
+					continue
+				}
+				findings = append(findings, localFinding(b, ruleID, severity,
+					fmt.Sprintf("%s grants the %s canned ACL; S3 buckets should prohibit public access", b, acl),
+					`acl = "private"`))
+			}
+		}
+		return findings
+	}
+}
+
+// checkS3BlockPublicAccess reports public access blocks that leave any of
+// the four settings disabled.
+func checkS3BlockPublicAccess(tf TerraformFile) []Finding {
+	var findings []Finding
+	for _, b := range resourcesOfType(tf, "aws_s3_bucket_public_access_block") {
+		if problems := disabledSettings(b, publicAccessBlockSettings); len(problems) > 0 {
+			findings = append(findings, localFinding(b, "S3.8", SeverityHigh,
+				fmt.Sprintf("%s does not block all public access (%s)", b, strings.Join(problems, ", ")),
+				strings.Join(publicAccessBlockSettings, " = true\n")+" = true"))
+		}
+	}
+	return findings
+}
+
+// checkRDSBackups reports DB instances with automated backups disabled.
+func checkRDSBackups(tf TerraformFile) []Finding {
+	var findings []Finding
+	for _, b := range resourcesOfType(tf, "aws_db_instance") {
+		v, _, ok := literalValue(b, "backup_retention_period")
+		if !ok || v.Type() != cty.Number || !v.Equals(cty.Zero).True() {
+			continue
+		}
+		findings = append(findings, localFinding(b, "RDS.11", SeverityMedium,
+			fmt.Sprintf("%s should have automatic backups enabled (backup_retention_period = 0)", b),
+			"backup_retention_period = 7"))
+	}
+	return findings
+}
+
+// checkDefaultSecurityGroup reports default security groups that allow any
+// traffic. The default security group of a VPC should have no rules.
+func checkDefaultSecurityGroup(tf TerraformFile) []Finding {
+	var findings []Finding
+	for _, b := range resourcesOfType(tf, "aws_default_security_group") {
+		_, ingress := b.Body.Attributes["ingress"]
+		_, egress := b.Body.Attributes["egress"]
+		if !ingress && !egress && !hasNestedBlock(b, "ingress") && !hasNestedBlock(b, "egress") {
+			continue
+		}
+		findings = append(findings, localFinding(b, "EC2.2", SeverityHigh,
+			fmt.Sprintf("%s allows traffic; VPC default security groups should not allow inbound or outbound traffic", b),
+			"# Remove all ingress and egress rules from this resource."))
+	}
+	return findings
+}
+
+// checkVPCFlowLogs reports VPCs without an aws_flow_log referencing them.
+func checkVPCFlowLogs(tf TerraformFile) []Finding {
+	logged := make(map[string]bool)
+	for _, b := range resourcesOfType(tf, "aws_flow_log") {
+		attr, ok := b.Body.Attributes["vpc_id"]
+		if !ok {
+			continue
+		}
+		for _, traversal := range attr.Expr.Variables() {
+			if len(traversal) < 2 || traversal.RootName() != "aws_vpc" {
+				continue
+			}
+			if name, ok := traversal[1].(hcl.TraverseAttr); ok {
+				logged["aws_vpc."+name.Name] = true
+			}
+		}
+	}
+
+	var findings []Finding
+	for _, b := range resourcesOfType(tf, "aws_vpc") {
+		if logged[b.Address()] {
+			continue
+		}
+		findings = append(findings, localFinding(b, "EC2.6", SeverityMedium,
+			fmt.Sprintf("%s has no aws_flow_log; VPC flow logging should be enabled in all VPCs", b),
+			fmt.Sprintf(`resource "aws_flow_log" %q {
+  vpc_id          = %s.id
+  traffic_type    = "REJECT"
+  log_destination = aws_cloudwatch_log_group.flow_logs.arn
+  iam_role_arn    = aws_iam_role.flow_logs.arn
+}`, b.Name, b.Address())))
+	}
+	return findings
+}
+
+// checkIMDSv2 reports instances that do not require IMDSv2 session tokens.
+func checkIMDSv2(tf TerraformFile) []Finding {
+	var findings []Finding
+	for _, b := range resourcesOfType(tf, "aws_instance") {
+		problem := "metadata_options is not set"
+		for _, nested := range b.Body.Blocks {
+			if nested.Type != "metadata_options" {
+				continue
+			}
+			options := TerraformBlock{Body: nested.Body}
+			if v, _, ok := literalValue(options, "http_tokens"); ok && v.Type() == cty.String && v.AsString() != "required" {
+				problem = fmt.Sprintf("http_tokens = %q", v.AsString())
+			} else if _, set := nested.Body.Attributes["http_tokens"]; !set {
+				problem = "http_tokens is not set"
+			} else {
+				problem = ""
+			}
+		}
+		if problem == "" {
+			continue
+		}
+		findings = append(findings, localFinding(b, "EC2.8", SeverityHigh,
+			fmt.Sprintf("%s should use Instance Metadata Service Version 2 (%s)", b, problem),
+			`metadata_options {
+  http_endpoint = "enabled"
+  http_tokens   = "required"
+}`))
+	}
+	return findings
+}
+
+// checkOpenAllTraffic reports ingress rules admitting all traffic from the
+// internet.
+func checkOpenAllTraffic(tf TerraformFile) []Finding {
+	var findings []Finding
+	for _, rule := range tf.SecurityGroupRules {
+		open := rule.openToInternet()
+		if rule.Direction != "ingress" || !rule.allTraffic() || len(open) == 0 {
+			continue
+		}
+		resourceType, _, _ := strings.Cut(rule.Resource, ".")
+		findings = append(findings, Finding{
+			Severity:     SeverityHigh,
+			ResourceType: resourceType,
+			RuleID:       "EC2.18",
+			Description:  fmt.Sprintf("%s ingress rule on line %d allows all traffic from %s; security groups should only allow unrestricted incoming traffic for authorized ports", rule.Resource, rule.Line, strings.Join(open, ", ")),
+		})
+	}
+	return findings
+}
+
+// checkIAMUserPolicies reports policies attached directly to IAM users
+// rather than through groups or roles.
+func checkIAMUserPolicies(tf TerraformFile) []Finding {
+	var findings []Finding
+	for _, resourceType := range []string{"aws_iam_user_policy", "aws_iam_user_policy_attachment"} {
+		for _, b := range resourcesOfType(tf, resourceType) {
+			findings = append(findings, localFinding(b, "IAM.2", SeverityLow,
+				fmt.Sprintf("%s attaches a policy directly to an IAM user; grant permissions through groups or roles", b), ""))
+		}
+	}
+	return findings
+}
+
+// checkPasswordPolicy reports account password policies weaker than the
+// FSBP minimums.
+func checkPasswordPolicy(tf TerraformFile) []Finding {
+	var findings []Finding
+	for _, b := range resourcesOfType(tf, "aws_iam_account_password_policy") {
+		var problems []string
+		if length, ok := literalInt(b, "minimum_password_length"); ok && length < 8 {
+			problems = append(problems, fmt.Sprintf("minimum_password_length = %d", length))
+		} else if _, set := b.Body.Attributes["minimum_password_length"]; !set {
+			problems = append(problems, "minimum_password_length is not set")
+		}
+		for _, attr := range []string{"require_uppercase_characters", "require_lowercase_characters", "require_symbols", "require_numbers"} {
+			problems = append(problems, disabledSettings(b, []string{attr})...)
+		}
+		if len(problems) == 0 {
+			continue
+		}
+		findings = append(findings, localFinding(b, "IAM.7", SeverityMedium,
+			fmt.Sprintf("%s should have a strong configuration (%s)", b, strings.Join(problems, ", ")),
+			`minimum_password_length      = 14
+require_uppercase_characters = true
+require_lowercase_characters = true
+require_symbols              = true
+require_numbers              = true`))
+	}
+	return findings
+}
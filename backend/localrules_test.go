@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestMergeFindings(t *testing.T) {
+	tf, diags := parseTerraform("main.tf", `
+resource "aws_s3_bucket" "a" {}
+resource "aws_s3_bucket" "b" {}
+`)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	local := []Finding{{Severity: SeverityMedium, ResourceType: "aws_s3_bucket", RuleID: "S3.4", Description: "aws_s3_bucket.a should be encrypted at rest"}}
+
+	tests := []struct {
+		name    string
+		agent   []Finding
+		want    []string
+		dropped int
+	}{
+		{
+			name:    "agent finding for a rule reported locally on the same block is dropped",
+			agent:   []Finding{{RuleID: "S3.4", ResourceType: "aws_s3_bucket", Description: "aws_s3_bucket.a is unencrypted"}},
+			want:    []string{"aws_s3_bucket.a should be encrypted at rest"},
+			dropped: 1,
+		},
+		{
+			name:  "agent finding for the same rule on another block is kept",
+			agent: []Finding{{RuleID: "S3.4", ResourceType: "aws_s3_bucket", Description: "aws_s3_bucket.b is unencrypted"}},
+			want:  []string{"aws_s3_bucket.b is unencrypted", "aws_s3_bucket.a should be encrypted at rest"},
+		},
+		{
+			name:  "agent finding for another rule is kept",
+			agent: []Finding{{RuleID: "S3.1", ResourceType: "aws_s3_bucket", Description: "aws_s3_bucket.a is public"}},
+			want:  []string{"aws_s3_bucket.a is public", "aws_s3_bucket.a should be encrypted at rest"},
+		},
+		{
+			name:  "agent finding without a rule is kept",
+			agent: []Finding{{ResourceType: "aws_s3_bucket", Description: "aws_s3_bucket.a has no tags"}},
+			want:  []string{"aws_s3_bucket.a has no tags", "aws_s3_bucket.a should be encrypted at rest"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dropped := tf.mergeFindings(tt.agent, local)
+			if dropped != tt.dropped {
+				t.Errorf("dropped = %d, want %d", dropped, tt.dropped)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d findings, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, f := range got {
+				if f.Description != tt.want[i] {
+					t.Errorf("finding %d = %q, want %q", i, f.Description, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// loggerKey is the context key under which the request-scoped logger is stored.
+type loggerKey struct{}
+
+// parseLogLevel converts a LOG_LEVEL value into an slog level.
+func parseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToUpper(s) {
+	case "DEBUG":
+		return slog.LevelDebug, nil
+	case "INFO":
+		return slog.LevelInfo, nil
+	case "WARN":
+		return slog.LevelWarn, nil
+	case "ERROR":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid log level %q: must be one of DEBUG, INFO, WARN, ERROR", s)
+	}
+}
+
+// newLogger creates a JSON logger writing to w at the given level.
+func newLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// loggerFromContext returns the request-scoped logger, falling back to the default logger.
+func loggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before delegating to the wrapped writer.
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Hijack lets WebSocket upgrades take over the wrapped connection.
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rec.status = http.StatusSwitchingProtocols
+	return http.NewResponseController(rec.ResponseWriter).Hijack()
+}
+
+// loggingMiddleware stores a logger carrying the request ID in the request
+// context and logs the start and end of the request.
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := slog.Default().With("request_id", requestIDFromContext(r.Context()))
+		r = r.WithContext(context.WithValue(r.Context(), loggerKey{}, logger))
+
+		logger.Info("Request started", "method", r.Method, "path", r.URL.Path)
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		logger.Info("Request completed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status_code", rec.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
@@ -2,56 +2,284 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"regexp"
-	"strings"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagent"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"terraform-complaince-backend/bedrocktest"
 )
 
 // AnalyzeRequest defines the structure of the incoming JSON request.
 type AnalyzeRequest struct {
-	Code string `json:"code"`
+	Code      string `json:"code"`
+	Framework string `json:"framework,omitempty"`
+	// Format is "hcl", "plan-json" for `terraform show -json` output,
+	// "terragrunt" for a terragrunt.hcl file, whose inputs are merged into
+	// Variables, or "pulumi-yaml" for a Pulumi YAML program. It is detected
+	// from the code when empty.
+	Format Format `json:"format,omitempty"`
+	// Variables supplies input variable values, like a .tfvars file, so the
+	// agent can evaluate the code with concrete values.
+	Variables map[string]string `json:"variables,omitempty"`
+	// Workspace is the Terraform workspace the code is deployed to,
+	// "default" when empty.
+	Workspace string `json:"workspace,omitempty"`
+	// MaxSuggestions is the most suggestions the agent may give, between 1
+	// and 20. It defaults to MAX_SUGGESTIONS, raised for files with many resources.
+	MaxSuggestions int `json:"max_suggestions,omitempty"`
+	// CallbackURL, for /analyze/async only, receives the result in a signed
+	// POST when the job finishes.
+	CallbackURL string `json:"callback_url,omitempty"`
+	// DeadlineSeconds, for /analyze and /analyze/stream, is how long the
+	// analysis may take before partial results are returned. It defaults to
+	// DEFAULT_DEADLINE_SECONDS.
+	DeadlineSeconds int `json:"deadline_seconds,omitempty"`
+	// SortBy, for /analyze only, orders the findings: "remediation_effort"
+	// lists the quickest fixes first.
+	SortBy string `json:"sort_by,omitempty"`
+	// GroupBy, for /score only, breaks the score down by "resource_type"
+	// (the default) or "owasp_category".
+	GroupBy string `json:"group_by,omitempty"`
+	// AllowedResourceTypes, when set, restricts the analysis to resources
+	// of these types; the others are removed before the prompt is built.
+	AllowedResourceTypes []string `json:"allowed_resource_types,omitempty"`
 }
 
 // AnalyzeResponse defines the structure of the JSON response.
 type AnalyzeResponse struct {
-	Suggestion string `json:"suggestion"`
+	Suggestion     string    `json:"suggestion"`
+	Findings       []Finding `json:"findings"`
+	SecretWarnings []string  `json:"secret_warnings,omitempty"`
+	// VariableWarnings names referenced variables that have no value.
+	VariableWarnings []string `json:"variable_warnings,omitempty"`
+	// AnalysisID is the history entry the analysis was recorded as, for
+	// reporting feedback on its findings.
+	AnalysisID int64 `json:"analysis_id,omitempty"`
+	// Truncated reports that the agent found more issues than
+	// max_suggestions allowed it to report, or, when Reason is
+	// "deadline_exceeded", that the deadline cut the analysis short before
+	// the agent reviewed RemainingResources.
+	Truncated          bool     `json:"truncated,omitempty"`
+	Reason             string   `json:"reason,omitempty"`
+	RemainingResources []string `json:"remaining_resources,omitempty"`
+	// RedactedCount is the number of values marked sensitive in Terraform
+	// that were withheld from the agent.
+	RedactedCount int `json:"redacted_count,omitempty"`
+	// Chunked reports that the code was too large for one prompt and was
+	// analyzed in ChunkCount parts.
+	Chunked    bool `json:"chunked,omitempty"`
+	ChunkCount int  `json:"chunk_count,omitempty"`
+	// Metrics describes the size of the code and the cost of analyzing it.
+	Metrics AnalysisMetrics `json:"metrics"`
+	// Format and FormatNote are set when the code was translated from
+	// another format for analysis, noting how faithful the translation is.
+	Format     Format `json:"format,omitempty"`
+	FormatNote string `json:"format_note,omitempty"`
+	// DetectedFormat is the format detected for code submitted without one.
+	DetectedFormat Format `json:"detected_format,omitempty"`
+	// RenamedResources lists the old and new addresses of resources moved
+	// by moved blocks, with chained moves resolved.
+	RenamedResources []ResourceMove `json:"renamed_resources,omitempty"`
+	// Filtered reports that allowed_resource_types left no resources to
+	// analyze, for the reason given in FilterReason.
+	Filtered     bool   `json:"filtered,omitempty"`
+	FilterReason string `json:"filter_reason,omitempty"`
 }
 
-// BedrockConverseAPI encapsulates the Bedrock agent client.
+// BedrockConverseAPI encapsulates the Bedrock agent clients.
 type BedrockConverseAPI struct {
-	Client *bedrockagentruntime.Client
+	Regions     *regionPool
+	AgentClient *bedrockagent.Client
+	Cache       *expirable.LRU[string, AnalyzeResponse]
+	Rules       map[string][]Rule
+	Minimums    map[string]ProviderMinimum
+	Examples    []Example
+	OpenAPI     []byte
+	Secrets     *secretScanner
+	Injection   *injectionDetector
+	History     *historyStore
+	Jobs        *jobStore
+	Batch       *WorkerPool
+	Modules     *moduleFetcher
+	Breaker     *CircuitBreaker
+	Inflight    *inflightGroup
+	Sessions    *workspaceSessions
+	Admin       *apiKeyAuth
+	Limiter     *ipRateLimiter
+
+	// cfg and prompt are replaced when the configuration is reloaded.
+	mu     sync.RWMutex
+	cfg    *ServerConfig
+	prompt *PromptTemplate
 }
 
-// NewBedrockConverseAPI creates a new Bedrock agent API client.
-func NewBedrockConverseAPI(ctx context.Context, region string) (*BedrockConverseAPI, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+// NewBedrockConverseAPI creates Bedrock agent runtime clients for every
+// configured region, or a mock shared by all of them with MOCK_BEDROCK, and
+// a control plane client for the primary one.
+func NewBedrockConverseAPI(ctx context.Context, serverCfg *ServerConfig) (*BedrockConverseAPI, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(serverCfg.BedrockRegions[0]))
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
 	}
 
+	rules, err := loadRuleManifests()
+	if err != nil {
+		return nil, err
+	}
+
+	minimums, err := loadProviderMinimums()
+	if err != nil {
+		return nil, err
+	}
+
+	examples, err := loadExamples()
+	if err != nil {
+		return nil, err
+	}
+
+	openAPI, err := loadOpenAPISpec()
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := newSecretScanner(serverCfg.SecretPatternsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	injection, err := loadInjectionDetector(serverCfg.PromptInjectionPatternsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, err := loadPromptTemplate(serverCfg.PromptTemplateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var modules *moduleFetcher
+	if serverCfg.AllowModuleFetch {
+		modules = newModuleFetcher()
+	}
+
+	newClient := func(region string) BedrockInvoker { return newAgentRuntime(cfg, region) }
+	if serverCfg.MockBedrock {
+		mock, err := bedrocktest.NewFromFile(serverCfg.MockResponseFile, serverCfg.MockLatency)
+		if err != nil {
+			return nil, err
+		}
+		newClient = func(string) BedrockInvoker { return mock }
+	}
+
+	var history *historyStore
+	if serverCfg.DatabasePath != "" {
+		if history, err = openHistoryStore(serverCfg.DatabasePath); err != nil {
+			return nil, err
+		}
+	}
+
 	return &BedrockConverseAPI{
-		Client: bedrockagentruntime.NewFromConfig(cfg),
+		Regions:     newRegionPool(serverCfg.BedrockRegions, newClient),
+		AgentClient: bedrockagent.NewFromConfig(cfg),
+		Cache:       expirable.NewLRU[string, AnalyzeResponse](serverCfg.CacheSize, nil, serverCfg.CacheTTL),
+		Rules:       rules,
+		Minimums:    minimums,
+		Examples:    examples,
+		OpenAPI:     openAPI,
+		Secrets:     secrets,
+		Injection:   injection,
+		History:     history,
+		Modules:     modules,
+		Breaker:     newCircuitBreaker(serverCfg.CircuitFailureThreshold, serverCfg.CircuitResetTimeout, serverCfg.CircuitHalfOpenProbes),
+		Jobs:        newJobStore(serverCfg.JobTTL),
+		Batch:       newWorkerPool(serverCfg.BatchConcurrency),
+		Inflight:    newInflightGroup(),
+		Sessions:    newWorkspaceSessions(serverCfg.SessionTTL),
+		Admin:       newAPIKeyAuth(serverCfg.AdminAPIKeys),
+		cfg:         serverCfg,
+		prompt:      prompt,
 	}, nil
 }
 
-// analyzeHandler handles the /analyze endpoint.
+// setupAnalysis creates the Bedrock client and registers the built-in
+// analyzers, then any plugins, for both the server and the check command.
+func setupAnalysis(serverCfg *ServerConfig) (*BedrockConverseAPI, error) {
+	api, err := NewBedrockConverseAPI(context.Background(), serverCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Bedrock client: %w", err)
+	}
+
+	RegisterAnalyzer(bedrockAnalyzerName, &bedrockAnalyzer{api: api})
+	RegisterAnalyzer("regex", newRegexAnalyzer())
+	RegisterAnalyzer("iam", iamAnalyzer{})
+	RegisterAnalyzer("security-group", securityGroupAnalyzer{})
+	RegisterAnalyzer("lambda", lambdaAnalyzer{})
+	RegisterAnalyzer("s3-policy", s3BucketPolicyAnalyzer{})
+	RegisterAnalyzer("pci-dss", pciAnalyzer{})
+	RegisterAnalyzer("hipaa", hipaaAnalyzer{})
+	RegisterAnalyzer("cis-aws", cisAWSAnalyzer{})
+	RegisterAnalyzer("local-rules", localRulesAnalyzer{})
+	deprecations, err := newDeprecationAnalyzer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load deprecated resources: %w", err)
+	}
+	RegisterAnalyzer("deprecation", deprecations)
+	if remediationEfforts, err = loadRemediationEfforts(); err != nil {
+		return nil, err
+	}
+	if owaspMapping, err = loadOWASPMapping(); err != nil {
+		return nil, err
+	}
+	if err := loadAnalyzerPlugins(serverCfg.PluginDir); err != nil {
+		return nil, fmt.Errorf("failed to load analyzer plugins: %w", err)
+	}
+	return api, nil
+}
+
+// analyzeHandler handles the /analyze endpoint. Clients that accept
+// text/event-stream receive the agent response as Server-Sent Events.
 func (api *BedrockConverseAPI) analyzeHandler(w http.ResponseWriter, r *http.Request) {
+	api.handleAnalyze(w, r, acceptsEventStream(r))
+}
+
+// analyzeStreamHandler handles the /analyze/stream endpoint, which always
+// streams the agent response as Server-Sent Events.
+func (api *BedrockConverseAPI) analyzeStreamHandler(w http.ResponseWriter, r *http.Request) {
+	api.handleAnalyze(w, r, true)
+}
+
+// handleAnalyze implements /analyze and /analyze/stream.
+func (api *BedrockConverseAPI) handleAnalyze(w http.ResponseWriter, r *http.Request, stream bool) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	logger := loggerFromContext(r.Context())
+
 	var req AnalyzeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if isMultipartUpload(r) {
+		if !api.decodeZipUpload(w, r, &req) {
+			return
+		}
+	} else if !decodeJSONBody(w, r, &req) {
 		return
 	}
 
@@ -59,94 +287,484 @@ func (api *BedrockConverseAPI) analyzeHandler(w http.ResponseWriter, r *http.Req
 		http.Error(w, "Query text is empty or not a string", http.StatusInternalServerError)
 		return
 	}
+	if api.rejectPromptInjection(w, logger, req.Code) {
+		return
+	}
+
+	fw, err := lookupFramework(req.Framework)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	detectedFormat := req.resolveFormat()
+	source, err := inputCode(req.Format, req.Code)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := req.mergeTerragruntInputs(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateVariables(req.Variables); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	workspace, err := resolveWorkspace(req.Workspace)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	source = substituteWorkspace(source, workspace)
+	if err := validateMaxSuggestions(req.MaxSuggestions); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateDeadline(req.DeadlineSeconds); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateSortBy(req.SortBy); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Streamed analyses are always Server-Sent Events.
+	format := jsonContentType
+	if !stream {
+		var acceptable bool
+		if format, acceptable = negotiateFormat(r); !acceptable {
+			writeJSONError(w, http.StatusNotAcceptable, fmt.Sprintf("Accept must allow %s, %s or %s", jsonContentType, textContentType, sarifContentType))
+			return
+		}
+	}
+	deadline := api.analysisDeadline(req.DeadlineSeconds)
 
-	// Clean the input code
-	cleanedCode := strings.ReplaceAll(req.Code, "\n", " ")
+	sessionID, ok := api.requestSessionID(w, r)
+	if !ok {
+		return
+	}
+	logger = logger.With("session_id", sessionID)
 
-	// --- Start of new logic to filter context data ---
+	ctx, span := tracer.Start(r.Context(), "analyze", trace.WithAttributes(
+		attribute.String("bedrock.session_id", sessionID),
+	))
+	defer span.End()
 
-	// 1. Extract resource types from the input code using regex.
-	re := regexp.MustCompile(`resource\s+"([^"]+)"`)
-	matches := re.FindAllStringSubmatch(cleanedCode, -1)
-	resourceTypes := ""
-	for _, match := range matches {
-		if len(match) > 1 {
-			resourceTypes += match[1] + ", "
+	tf, ok := parseSource(ctx, w, "main.tf", source)
+	if !ok {
+		return
+	}
+	if source, tf, ok = applyResourceAllowlist(ctx, w, logger, source, tf, req.AllowedResourceTypes); !ok {
+		return
+	}
+	if len(req.AllowedResourceTypes) > 0 && len(tf.Resources) == 0 {
+		logger.Info("No resources match allowed_resource_types, skipping analysis")
+		resp := noMatchingResourcesResponse(tf)
+		resp.DetectedFormat = detectedFormat
+		if stream {
+			if err := newSSEWriter(w).send("done", resp); err != nil {
+				logger.Warn("Failed to stream final event to client", "error", err)
+			}
+			return
 		}
+		api.writeAnalysis(w, format, tf, fw, resp)
+		return
 	}
+	if req.Framework == "" {
+		fw = detectFramework(tf)
+	}
+	agentID := api.Config().agentFor(fw.ID).AgentID
+	span.SetAttributes(
+		attribute.String("bedrock.agent_id", agentID),
+		attribute.StringSlice("terraform.frameworks", []string{fw.ID}),
+	)
+	logger = logger.With("agent_id", agentID, "framework", fw.ID)
+	ctx = context.WithValue(ctx, loggerKey{}, logger)
+	r = r.WithContext(ctx)
 
-	// Construct the prompt for the model
-	promptTemplate := `
-Your task is to analyze the provided Terraform code, identify non-compliant patterns based on the FSBP sentinel policies in the knowledge base, and generate a JSON object containing specific code modifications to fix them.
+	variableWarnings := api.applyVariables(logger, tf, req.Variables)
+	api.applyWorkspace(tf, workspace)
+	tf.MaxSuggestions = req.MaxSuggestions
+	tf.Deadline = deadline
 
-Terraform Code to Analyze:
-{code}
+	key := api.analysisCacheKey(source, tf, fw.ID)
+	if cached, ok := api.Cache.Get(key); ok {
+		cacheHits.Inc()
+		logger.Info("Serving analysis from cache")
+		w.Header().Set(cacheHeader, "HIT")
+		cached.Metrics = cached.Metrics.fromCache()
+		cached.Format, cached.FormatNote = translationNote(req.Format)
+		cached.DetectedFormat = detectedFormat
+		if stream {
+			cached.AnalysisID = api.recordHistory(r, source, fw, cached.Findings)
+			if err := newSSEWriter(w).send("done", cached); err != nil {
+				logger.Warn("Failed to stream final event to client", "error", err)
+			}
+			return
+		}
 
-Resource Types to Consider: {resourceTypes}
+		// The client already holds this analysis if the tag it sent still
+		// matches the cached one.
+		cached.Findings = sortFindings(cached.Findings, req.SortBy)
+		etag := analysisETag(cached)
+		w.Header().Set("ETag", etag)
+		if etagMatches(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		cached.AnalysisID = api.recordHistory(r, source, fw, cached.Findings)
+		api.writeAnalysis(w, format, tf, fw, cached)
+		return
+	}
+	cacheMisses.Inc()
+	w.Header().Set(cacheHeader, "MISS")
+
+	secretWarnings := api.redactSource(logger, tf)
+	base := AnalyzeResponse{SecretWarnings: secretWarnings, VariableWarnings: variableWarnings, RedactedCount: tf.RedactedCount, Metrics: tf.analysisMetrics()}
+	base.Format, base.FormatNote = translationNote(req.Format)
+	base.DetectedFormat = detectedFormat
+	base.RenamedResources = tf.renamedResources()
+
+	// An identical analysis already in progress, typically from a client
+	// resending code after a keystroke, is shared rather than repeated.
+	call, leader := api.Inflight.join(key)
+	if !leader {
+		inflightJoins.Inc()
+		logger.Info("Waiting on identical analysis in progress")
+		api.awaitAnalysis(w, r, logger, call, stream, source, tf, fw, analysisOutput{SortBy: req.SortBy, Format: format})
+		return
+	}
+	var shared AnalyzeResponse
+	sharedErr := errAnalysisAbandoned
+	defer func() { api.Inflight.finish(key, call, shared, sharedErr) }()
+
+	if stream {
+		shared, sharedErr = api.streamAnalysis(w, r, logger, sessionID, key, tf, fw, base, call)
+		return
+	}
+
+	areq := &analysisRequest{Framework: fw, SessionID: sessionID}
+	findings, err := runAnalyzers(withAnalysisRequest(ctx, areq), *tf)
+	w.Header().Set(retryCountHeader, strconv.Itoa(areq.Retries))
+	if areq.Region != "" {
+		w.Header().Set(bedrockRegionHeader, areq.Region)
+	}
+	if err != nil {
+		sharedErr = err
+		writeAnalysisError(w, err)
+		return
+	}
+
+	resp := base
+	resp.Suggestion, resp.Findings, resp.Truncated = areq.Suggestion, findings, areq.Truncated
+	resp.Chunked, resp.ChunkCount = areq.Chunks > 1, areq.Chunks
+	resp.Metrics.recordAgent(areq.PromptTokens, areq.Latency)
+	resp.Metrics.DuplicatesRemoved = areq.DuplicatesRemoved
+	if areq.DeadlineExceeded {
+		// Partial results are not cached, so the next request completes them.
+		resp.Truncated, resp.Reason, resp.RemainingResources = true, truncatedDeadline, areq.Remaining
+	} else {
+		api.Cache.Add(key, resp)
+	}
+	shared, sharedErr = resp, nil
+	resp.Findings = sortFindings(resp.Findings, req.SortBy)
+	resp.AnalysisID = api.recordHistory(r, source, fw, findings)
+	w.Header().Set("ETag", analysisETag(resp))
+
+	// Send the response
+	api.writeAnalysis(w, format, tf, fw, resp)
+}
+
+// redactSource replaces sensitive values and hardcoded secrets in
+// tf.Source so they are never forwarded to Bedrock or an analyzer, and
+// records the data source arguments for the prompt with secrets masked. It
+// counts the sensitive values in tf.RedactedCount and returns a warning for
+// each secret.
+func (api *BedrockConverseAPI) redactSource(logger *slog.Logger, tf *TerraformFile) []string {
+	tf.DataSourceValues, _ = api.Secrets.redact(tf.dataSourceValues(), &TerraformFile{})
+
+	if n := tf.redactSensitive(); n > 0 {
+		tf.RedactedCount += n
+		logger.Info("Redacted sensitive values from submitted code", "count", n)
+	}
 
-Exclusions: Do NOT include explanations, markdown formatting, or any text outside of the final JSON array.
+	var secretWarnings []string
+	tf.Source, secretWarnings = api.Secrets.redact(tf.Source, tf)
+	if len(secretWarnings) > 0 {
+		logger.Warn("Redacted potential secrets from submitted code", "count", len(secretWarnings))
+	}
+	return secretWarnings
+}
 
-Give utmost two suggestion per query. Don't give same suggestion twice.
-`
+// errUnparseableResponse reports that the agent answered but its findings could not be read.
+var errUnparseableResponse = errors.New("agent response could not be parsed")
 
-	finalPrompt := strings.Replace(promptTemplate, "{code}", cleanedCode, 1)
-	finalPrompt = strings.Replace(finalPrompt, "{resourceTypes}", resourceTypes, 1)
+// analyzeSource returns the findings for source, parsed as tf, serving them
+// from the cache when possible. Each call uses its own agent session so it
+// can run concurrently with others.
+func (api *BedrockConverseAPI) analyzeSource(ctx context.Context, logger *slog.Logger, source string, tf *TerraformFile, fw Framework) ([]Finding, error) {
+	key := api.analysisCacheKey(source, tf, fw.ID)
+	if cached, ok := api.Cache.Get(key); ok {
+		cacheHits.Inc()
+		return cached.Findings, nil
+	}
+	cacheMisses.Inc()
 
-	// Define the model and parameters
-	agentID := "CJUKDDIFLZ"
-	agentAliasID := "SLBMZALQD4"
+	sessionID, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	logger = logger.With("agent_id", api.Config().agentFor(fw.ID).AgentID, "session_id", sessionID, "framework", fw.ID)
+	ctx = context.WithValue(ctx, loggerKey{}, logger)
 
+	secretWarnings := api.redactSource(logger, tf)
+	areq := &analysisRequest{Framework: fw, SessionID: sessionID}
+	findings, err := runAnalyzers(withAnalysisRequest(ctx, areq), *tf)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := tf.analysisMetrics()
+	metrics.recordAgent(areq.PromptTokens, areq.Latency)
+	metrics.DuplicatesRemoved = areq.DuplicatesRemoved
+	api.Cache.Add(key, AnalyzeResponse{Suggestion: areq.Suggestion, Findings: findings, SecretWarnings: secretWarnings, Metrics: metrics})
+	return findings, nil
+}
+
+// invokeAgent sends prompt to the Bedrock agent in region in the given session and
+// returns the concatenated response chunks. If onChunk is non-nil it is
+// called with each chunk as it arrives.
+func (api *BedrockConverseAPI) invokeAgent(ctx context.Context, logger *slog.Logger, region *bedrockRegion, agent AgentConfig, sessionID, prompt string, onChunk func([]byte)) (string, error) {
 	// Create the input for the Bedrock Agent API
 	input := &bedrockagentruntime.InvokeAgentInput{
-		AgentId:      aws.String(agentID),
-		AgentAliasId: aws.String(agentAliasID),
-		InputText:    aws.String(finalPrompt),
-		SessionId:    aws.String("default-session"), // You can generate a unique session ID if needed
+		AgentId:      aws.String(agent.AgentID),
+		AgentAliasId: aws.String(agent.AgentAliasID),
+		InputText:    aws.String(prompt),
+		SessionId:    aws.String(sessionID),
 	}
+	if agent.MaxTokens > 0 {
+		input.SessionState = &types.SessionState{
+			PromptSessionAttributes: map[string]string{"max_tokens": strconv.Itoa(agent.MaxTokens)},
+		}
+	}
+
+	ctx, span := tracer.Start(ctx, "bedrock.invoke_agent", trace.WithAttributes(
+		attribute.String("bedrock.agent_id", agent.AgentID),
+		attribute.String("bedrock.session_id", sessionID),
+		attribute.String("bedrock.region", region.Name),
+	))
+	defer span.End()
+
+	logger.Info("Invoking Bedrock agent with filtered context")
+	defer observeBedrockDuration(time.Now())
 
-	log.Println("Invoking Bedrock agent with filtered context...")
 	// Invoke the agent
-	output, err := api.Client.InvokeAgent(context.Background(), input)
+	suggestion, err := region.Client.InvokeAgent(context.WithValue(ctx, loggerKey{}, logger), input, onChunk)
 	if err != nil {
-		http.Error(w, "Agent invocation failed.", http.StatusInternalServerError)
-		log.Printf("Error invoking Bedrock agent: %v", err)
-		return
+		logger.Error("Error invoking Bedrock agent", "error", err)
+		recordSpanError(span, err)
+		// What was read is kept for an analysis cut off by its deadline.
+		return suggestion, err
 	}
-	log.Println("Agent invocation successful, processing response...")
-	// Extract and parse the response from agent
-	var suggestion strings.Builder
-	for event := range output.GetStream().Events() {
-		switch v := event.(type) {
-		case *types.ResponseStreamMemberChunk:
-			if v.Value.Bytes != nil {
-				suggestion.Write(v.Value.Bytes)
-			}
-		case *types.ResponseStreamMemberTrace:
-			// Handle trace events if needed
-			log.Printf("Trace event: %+v", v.Value)
-		}
+	logger.Info("Agent invocation successful")
+	return suggestion, nil
+}
+
+// agentErrorStatus maps a failed agent invocation to an HTTP status code and
+// message, reporting a timeout as 504 rather than a generic failure.
+func agentErrorStatus(err error) (int, string) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout, "Agent invocation timed out."
+	}
+	if errors.Is(err, errCircuitOpen) {
+		return http.StatusServiceUnavailable, "Agent is temporarily unavailable."
+	}
+	if errors.Is(err, errOfflineMode) {
+		return http.StatusServiceUnavailable, "Agent is disabled in offline mode."
 	}
+	return http.StatusInternalServerError, "Agent invocation failed."
+}
 
-	// Send the response
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(AnalyzeResponse{Suggestion: suggestion.String()}); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+// writeAgentError writes the JSON error response for a failed agent invocation.
+func writeAgentError(w http.ResponseWriter, err error) {
+	status, message := agentErrorStatus(err)
+	writeJSONError(w, status, message)
+}
+
+// analysisErrorMessage describes a failed analysis for the client.
+func analysisErrorMessage(err error) string {
+	if errors.Is(err, errUnparseableResponse) {
+		return "Agent response could not be parsed"
 	}
+	_, message := agentErrorStatus(err)
+	return message
+}
+
+// writeAnalysisError writes the JSON error response for a failed analysis,
+// reporting an unparseable agent response as 422.
+func writeAnalysisError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUnparseableResponse) {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: analysisErrorMessage(err), Detail: err.Error()})
+		return
+	}
+	writeAgentError(w, err)
 }
 
 func main() {
-	// Initialize the Bedrock client
-	api, err := NewBedrockConverseAPI(context.Background(), "us-east-1")
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(runCheck(os.Args[2:]))
+	}
+
+	// Load configuration from the environment
+	serverCfg, err := loadConfig()
 	if err != nil {
-		log.Fatalf("Failed to create Bedrock client: %v", err)
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(newLogger(os.Stdout, serverCfg.LogLevel))
+
+	shutdownTracing, err := setupTracing(context.Background(), serverCfg.OTLPEndpoint)
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	api, err := setupAnalysis(serverCfg)
+	if err != nil {
+		slog.Error("Failed to set up analysis", "error", err)
+		os.Exit(1)
 	}
 
 	// Set up the HTTP server
-	http.HandleFunc("/analyze", api.analyzeHandler)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/analyze", api.analyzeHandler)
+	mux.HandleFunc("/analyze/stream", api.analyzeStreamHandler)
+	mux.HandleFunc("/analyze/async", api.analyzeAsyncHandler)
+	mux.HandleFunc("/analyze/multi", api.analyzeMultiHandler)
+	mux.HandleFunc("/analyze/tags", api.analyzeTagsHandler)
+	mux.HandleFunc("/analyze/naming", api.analyzeNamingHandler)
+	mux.HandleFunc("/analyze/providers", api.analyzeProvidersHandler)
+	mux.HandleFunc("/analyze/sarif", api.analyzeSarifHandler)
+	mux.HandleFunc("/analyze/drift", api.analyzeDriftHandler)
+	mux.HandleFunc("/analyze/iam-simulate", api.analyzeIAMSimulateHandler)
+	mux.HandleFunc("/analyze/examples", api.analyzeExamplesHandler)
+	mux.HandleFunc("/jobs/{job_id}", api.jobHandler)
+	mux.HandleFunc("/jobs/{job_id}/deliveries", api.deliveriesHandler)
+	mux.HandleFunc("/batch", api.batchHandler)
+	mux.HandleFunc("/diff", api.diffHandler)
+	mux.HandleFunc("/graph", api.graphHandler)
+	mux.HandleFunc("/score", api.scoreHandler)
+	mux.HandleFunc("/inventory", api.inventoryHandler)
+	mux.HandleFunc("/ws", api.wsHandler)
+	mux.HandleFunc("/cache", api.cacheHandler)
+	mux.HandleFunc("/frameworks", api.frameworksHandler)
+	mux.HandleFunc("/explain", api.explainHandler)
+	mux.HandleFunc("/estimate", api.estimateHandler)
+	mux.HandleFunc("/autofix", api.autofixHandler)
+	mux.HandleFunc("/validate-prompt", api.validatePromptHandler)
+	mux.HandleFunc("/rules", api.rulesHandler)
+	mux.HandleFunc("/history", api.historyHandler)
+	mux.HandleFunc("/history/{id}", api.historyEntryHandler)
+	mux.HandleFunc("/history/diff", api.historyDiffHandler)
+	mux.HandleFunc("/baseline/save", api.baselineSaveHandler)
+	mux.HandleFunc("/baseline/compare", api.baselineCompareHandler)
+	mux.HandleFunc("/feedback", api.feedbackHandler)
+	mux.HandleFunc("/feedback/summary", api.feedbackSummaryHandler)
+	mux.HandleFunc("/health", api.healthHandler)
+	mux.HandleFunc("/health/live", api.livenessHandler)
+	mux.HandleFunc("/version", api.versionHandler)
+	mux.HandleFunc("/admin/reload", api.adminReloadHandler)
+	mux.HandleFunc("/openapi.json", api.openAPIHandler)
+	mux.HandleFunc("/docs", api.docsHandler)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	limiter := newIPRateLimiter(serverCfg.RateLimitRPS, serverCfg.RateLimitBurst)
+	go limiter.cleanupLoop()
+	api.Limiter = limiter
+
+	api.Jobs.startWorkers(jobWorkers)
+	go api.Jobs.cleanupLoop()
+
+	auth := newAPIKeyAuth(serverCfg.APIKeys)
+	if len(serverCfg.APIKeys) == 0 {
+		slog.Warn("API_KEYS is not set, authentication is disabled")
+	}
+
+	var handler http.Handler = mux
+	if serverCfg.LogRequestBodies {
+		scanner, err := loadSecretScanner(serverCfg.LogRedactPatternsFile, defaultLogRedactPatterns)
+		if err != nil {
+			slog.Error("Failed to load log redaction patterns", "error", err)
+			os.Exit(1)
+		}
+		handler = newBodyLogger(scanner, serverCfg.LogMaxBodyBytes).middleware(mux)
+		slog.Warn("LOG_REQUEST_BODIES is enabled, request and response bodies are logged and may contain secrets the redaction patterns miss")
+	}
+
+	port := serverCfg.ListenPort
+	srv := newDrainingServer(":"+port, requestIDMiddleware(loggingMiddleware(tracingMiddleware(metricsMiddleware(corsMiddleware(serverCfg.AllowedOrigins, limiter.middleware(auth.middleware(gzipMiddleware(maxBytesMiddleware(serverCfg.MaxRequestBytes, jsonContentTypeMiddleware(handler)))))))))))
 
-	port := "3000"
-	log.Printf("Server is listening at port %s", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// SIGHUP reloads the configuration without a restart.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			slog.Info("Reload signal received")
+			if _, err := api.reloadConfig(context.Background()); err != nil {
+				slog.Error("Failed to reload configuration", "error", err)
+			}
+		}
+	}()
+
+	var redirectSrv *http.Server
+	if serverCfg.tlsEnabled() {
+		redirectSrv = &http.Server{Addr: ":" + serverCfg.HTTPPort, Handler: setupTLS(srv.Server, serverCfg)}
+		go func() {
+			slog.Info("Redirecting plaintext HTTP to HTTPS", "port", serverCfg.HTTPPort)
+			if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Failed to start HTTP redirect server", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	go func() {
+		slog.Info("Server is listening", "port", port, "tls", serverCfg.tlsEnabled(), "agent_id", serverCfg.AgentID)
+		if err := srv.serve(serverCfg); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Failed to start server", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	slog.Info("Shutdown signal received, draining connections", "grace_period", serverCfg.ShutdownGrace.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverCfg.ShutdownGrace)
+	defer cancel()
+
+	if redirectSrv != nil {
+		if err := redirectSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("HTTP redirect server did not shut down cleanly", "error", err)
+		}
+	}
+
+	drained, err := srv.drain(shutdownCtx)
+	if err != nil {
+		slog.Error("Server did not shut down cleanly", "connections", drained, "error", err)
+		os.Exit(1)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
+	if api.History != nil {
+		if err := api.History.Close(); err != nil {
+			slog.Warn("Failed to close history database", "error", err)
+		}
 	}
+	slog.Info("Server stopped", "connections_drained", drained)
 }
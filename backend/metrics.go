@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics exposed at /metrics.
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "terraform_compliance_requests_total",
+		Help: "HTTP requests handled, by method and status code.",
+	}, []string{"method", "status"})
+
+	activeRequests = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "terraform_compliance_active_requests",
+		Help: "HTTP requests currently being handled.",
+	})
+
+	bedrockDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "terraform_compliance_bedrock_duration_seconds",
+		Help:    "Duration of Bedrock agent invocations, including reading the response stream.",
+		Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60},
+	})
+
+	bedrockRegionErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "terraform_compliance_bedrock_region_errors_total",
+		Help: "Bedrock agent invocations that failed with a regional service error, by region.",
+	}, []string{"region"})
+
+	workerJobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "terraform_compliance_batch_worker_jobs_total",
+		Help: "Batch files analyzed, by worker.",
+	}, []string{"worker"})
+
+	workerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "terraform_compliance_batch_worker_errors_total",
+		Help: "Batch files whose analysis failed, by worker.",
+	}, []string{"worker"})
+
+	workerIdleSeconds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "terraform_compliance_batch_worker_idle_seconds_total",
+		Help: "Time batch workers spent waiting for work, by worker.",
+	}, []string{"worker"})
+
+	cacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "terraform_compliance_cache_hits_total",
+		Help: "Analyses served from the cache.",
+	})
+
+	cacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "terraform_compliance_cache_misses_total",
+		Help: "Analyses that were not found in the cache.",
+	})
+
+	inflightJoins = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "terraform_compliance_inflight_joins_total",
+		Help: "Analyses that waited on an identical analysis already in progress.",
+	})
+
+	injectionAttempts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "terraform_compliance_injection_attempts_total",
+		Help: "Requests rejected as suspected prompt injection attempts.",
+	})
+)
+
+// metricsMiddleware counts requests by method and status code and tracks
+// how many are in flight.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		activeRequests.Inc()
+		defer activeRequests.Dec()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		requestsTotal.WithLabelValues(r.Method, strconv.Itoa(rec.status)).Inc()
+	})
+}
+
+// observeBedrockDuration records the time elapsed since start as a Bedrock
+// invocation duration.
+func observeBedrockDuration(start time.Time) {
+	bedrockDuration.Observe(time.Since(start).Seconds())
+}
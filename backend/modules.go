@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+// Terraform Registry access used when ALLOW_MODULE_FETCH is enabled.
+const (
+	registryURL          = "https://registry.terraform.io"
+	registryHost         = "registry.terraform.io"
+	moduleFetchTimeout   = 10 * time.Second
+	moduleCacheSize      = 128
+	maxModuleSourceBytes = 1 << 20
+)
+
+// Limits on the registry fetches a single analysis makes, so code calling
+// many modules, or unreachable ones, cannot stall the request.
+const (
+	// maxModuleFetches is how many module calls are fetched per request;
+	// the rest are described by their explicit inputs only.
+	maxModuleFetches = 5
+	// moduleFetchDeadline bounds all of a request's fetches together.
+	moduleFetchDeadline = 15 * time.Second
+	// moduleFailureTTL is how long a failed lookup is remembered and
+	// returned without contacting the registry again.
+	moduleFailureTTL = 5 * time.Minute
+)
+
+// registrySourcePattern matches a public registry module source such as
+// terraform-aws-modules/vpc/aws, optionally with a //subdirectory.
+var registrySourcePattern = regexp.MustCompile(`^(?:` + regexp.QuoteMeta(registryHost) + `/)?([0-9A-Za-z_-]+)/([0-9A-Za-z_-]+)/([0-9a-z]+)(?://(.+))?$`)
+
+// githubSourcePattern matches the GitHub download locations the registry
+// returns, such as git::https://github.com/owner/repo?ref=v1.0.0.
+var githubSourcePattern = regexp.MustCompile(`^(?:git::)?(?:https://)?github\.com/([^/]+)/([^/?]+?)(?:\.git)?(?://([^?]+))?\?ref=(.+)$`)
+
+// registryModule identifies a module published to the public registry.
+type registryModule struct {
+	Namespace, Name, Provider, Subdir string
+}
+
+// parseRegistrySource parses a module source argument, reporting false for
+// local paths, Git URLs and private registries.
+func parseRegistrySource(source string) (registryModule, bool) {
+	m := registrySourcePattern.FindStringSubmatch(source)
+	if m == nil {
+		return registryModule{}, false
+	}
+	return registryModule{Namespace: m[1], Name: m[2], Provider: m[3], Subdir: m[4]}, true
+}
+
+// path returns the module's path under the registry's /v1/modules API.
+func (m registryModule) path() string {
+	return "/v1/modules/" + m.Namespace + "/" + m.Name + "/" + m.Provider
+}
+
+// fetchedModule is the part of a registry module's source used in prompts.
+type fetchedModule struct {
+	Version   string
+	Resources []TerraformBlock
+	Variables []TerraformBlock
+}
+
+// moduleFetcher downloads registry modules, keeping the ones it has
+// fetched since published versions do not change, and briefly remembering
+// the ones it failed to.
+type moduleFetcher struct {
+	client   *http.Client
+	baseURL  string
+	cache    *lru.Cache[string, *fetchedModule]
+	failures *expirable.LRU[string, error]
+}
+
+// newModuleFetcher returns a fetcher for the public Terraform Registry.
+func newModuleFetcher() *moduleFetcher {
+	cache, _ := lru.New[string, *fetchedModule](moduleCacheSize)
+	return &moduleFetcher{
+		client:   &http.Client{Timeout: moduleFetchTimeout},
+		baseURL:  registryURL,
+		cache:    cache,
+		failures: expirable.NewLRU[string, error](moduleCacheSize, nil, moduleFailureTTL),
+	}
+}
+
+// fetch resolves the module's version constraint and downloads its main.tf
+// and variables.tf. A lookup that failed within moduleFailureTTL fails again
+// with the same error, unless it failed because ctx was done.
+func (f *moduleFetcher) fetch(ctx context.Context, m registryModule, constraint string) (*fetchedModule, error) {
+	key := m.path() + "@" + constraint + "//" + m.Subdir
+	if err, ok := f.failures.Get(key); ok {
+		return nil, err
+	}
+	fetched, err := f.download(ctx, m, constraint)
+	if err != nil && ctx.Err() == nil {
+		f.failures.Add(key, err)
+	}
+	return fetched, err
+}
+
+// download resolves the module's version constraint and downloads its
+// main.tf and variables.tf, or returns them from the cache.
+func (f *moduleFetcher) download(ctx context.Context, m registryModule, constraint string) (*fetchedModule, error) {
+	version := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(constraint), "="))
+	if !isExactVersion(version) {
+		var err error
+		if version, err = f.latestVersion(ctx, m, constraint); err != nil {
+			return nil, err
+		}
+	}
+
+	key := m.path() + "/" + version + "//" + m.Subdir
+	if cached, ok := f.cache.Get(key); ok {
+		return cached, nil
+	}
+
+	base, err := f.sourceBase(ctx, m, version)
+	if err != nil {
+		return nil, err
+	}
+	mainTF, err := f.get(ctx, base+"main.tf")
+	if err != nil {
+		return nil, err
+	}
+	// Not every module declares inputs in a separate variables.tf.
+	variablesTF, err := f.get(ctx, base+"variables.tf")
+	if err != nil && !errors.Is(err, errModuleFileNotFound) {
+		return nil, err
+	}
+
+	main, _ := parseTerraform("main.tf", string(mainTF))
+	variables, _ := parseTerraform("variables.tf", string(variablesTF))
+	fetched := &fetchedModule{
+		Version:   version,
+		Resources: append(main.Resources, main.DataSources...),
+		Variables: append(main.Variables, variables.Variables...),
+	}
+	f.cache.Add(key, fetched)
+	return fetched, nil
+}
+
+// isExactVersion reports whether v is a plain version number rather than a constraint.
+func isExactVersion(v string) bool {
+	if v == "" {
+		return false
+	}
+	for _, part := range strings.Split(v, ".") {
+		if _, err := strconv.Atoi(part); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// latestVersion returns the highest published version of the module that
+// satisfies constraint.
+func (f *moduleFetcher) latestVersion(ctx context.Context, m registryModule, constraint string) (string, error) {
+	body, err := f.get(ctx, f.baseURL+m.path()+"/versions")
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		Modules []struct {
+			Versions []struct {
+				Version string `json:"version"`
+			} `json:"versions"`
+		} `json:"modules"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to decode module versions: %w", err)
+	}
+
+	var latest string
+	for _, mod := range resp.Modules {
+		for _, v := range mod.Versions {
+			if isExactVersion(v.Version) && versionMatches(v.Version, constraint) && (latest == "" || compareVersions(v.Version, latest) > 0) {
+				latest = v.Version
+			}
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("module %s has no published version matching %q", m.path(), constraint)
+	}
+	return latest, nil
+}
+
+// versionMatches reports whether the exact version v satisfies a Terraform
+// version constraint such as ">= 4.0, < 6.0" or "~> 5.1". An empty
+// constraint matches every version.
+func versionMatches(v, constraint string) bool {
+	for _, c := range strings.Split(constraint, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		op := "="
+		for _, candidate := range []string{"~>", ">=", "<=", "!=", ">", "<", "="} {
+			if strings.HasPrefix(c, candidate) {
+				op, c = candidate, strings.TrimSpace(c[len(candidate):])
+				break
+			}
+		}
+		if !isExactVersion(c) {
+			return false
+		}
+
+		cmp := compareVersions(v, c)
+		var ok bool
+		switch op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case "~>":
+			// Only the rightmost part of the constraint may increase.
+			parts := strings.Split(c, ".")
+			prefix := strings.Join(parts[:len(parts)-1], ".")
+			ok = cmp >= 0 && (prefix == "" || v == prefix || strings.HasPrefix(v, prefix+"."))
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compareVersions compares two exact versions numerically, part by part.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range max(len(as), len(bs)) {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// sourceBase asks the registry where the module version is hosted and
+// returns the URL its raw files can be read from. Only modules hosted on
+// GitHub, which covers the public registry, are supported.
+func (f *moduleFetcher) sourceBase(ctx context.Context, m registryModule, version string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.baseURL+m.path()+"/"+url.PathEscape(version)+"/download", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve module download location: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry returned %s resolving %s %s", resp.Status, m.path(), version)
+	}
+
+	location := resp.Header.Get("X-Terraform-Get")
+	g := githubSourcePattern.FindStringSubmatch(location)
+	if g == nil {
+		return "", fmt.Errorf("unsupported module download location %q", location)
+	}
+	base := "https://raw.githubusercontent.com/" + g[1] + "/" + g[2] + "/" + g[4] + "/"
+	for _, dir := range []string{g[3], m.Subdir} {
+		if dir = strings.Trim(dir, "/"); dir != "" {
+			base += dir + "/"
+		}
+	}
+	return base, nil
+}
+
+// errModuleFileNotFound reports that a module does not contain a file.
+var errModuleFileNotFound = errors.New("module file not found")
+
+// get downloads rawURL, reading at most maxModuleSourceBytes.
+func (f *moduleFetcher) get(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", errModuleFileNotFound, rawURL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned %s", rawURL, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxModuleSourceBytes))
+}
+
+// moduleContext describes the module calls in tf for the analysis prompt:
+// each call's source, version and the inputs it sets explicitly, and, when
+// module fetching is enabled, the resources a registry module creates. Up
+// to maxModuleFetches modules are fetched, all within moduleFetchDeadline.
+// It also returns the resource types those modules add to the analysis.
+func (api *BedrockConverseAPI) moduleContext(ctx context.Context, tf *TerraformFile) (string, []string) {
+	if len(tf.Modules) == 0 {
+		return "", nil
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, moduleFetchDeadline)
+	defer cancel()
+	fetches, skipped := 0, 0
+
+	var sb strings.Builder
+	var resourceTypes []string
+	sb.WriteString("Module Calls:\n")
+	for _, mod := range tf.Modules {
+		fmt.Fprintf(&sb, "- module.%s", mod.Name)
+		if mod.Source != "" {
+			fmt.Fprintf(&sb, " (source %s", mod.Source)
+			if mod.Version != "" {
+				fmt.Fprintf(&sb, ", version %s", mod.Version)
+			}
+			sb.WriteString(")")
+		}
+		if inputs := moduleInputs(mod); len(inputs) > 0 {
+			fmt.Fprintf(&sb, " sets inputs: %s", strings.Join(inputs, ", "))
+		}
+		sb.WriteString("\n")
+
+		src, ok := parseRegistrySource(mod.Source)
+		if api.Modules == nil || !ok {
+			continue
+		}
+		if fetches == maxModuleFetches {
+			skipped++
+			continue
+		}
+		fetches++
+		fetched, err := api.Modules.fetch(fetchCtx, src, mod.Version)
+		if err != nil {
+			loggerFromContext(ctx).Warn("Failed to fetch module source, analyzing explicit inputs only", "module", mod.Name, "source", mod.Source, "error", err)
+			continue
+		}
+		if len(fetched.Resources) > 0 {
+			addresses := make([]string, len(fetched.Resources))
+			for i, b := range fetched.Resources {
+				addresses[i] = b.String()
+				resourceTypes = append(resourceTypes, b.Type)
+			}
+			fmt.Fprintf(&sb, "  Version %s creates: %s\n", fetched.Version, strings.Join(addresses, ", "))
+		}
+		if unset := unsetModuleInputs(mod, fetched.Variables); len(unset) > 0 {
+			fmt.Fprintf(&sb, "  Inputs left at their defaults: %s\n", strings.Join(unset, ", "))
+		}
+	}
+	if skipped > 0 {
+		loggerFromContext(ctx).Warn("Too many module calls to fetch, analyzing explicit inputs only", "skipped", skipped, "max_module_fetches", maxModuleFetches)
+	}
+	return sb.String(), resourceTypes
+}
+
+// moduleArguments are module block meta-arguments rather than module inputs.
+var moduleArguments = []string{"source", "version", "count", "for_each", "providers", "depends_on"}
+
+// moduleInputs returns the names of the inputs a module call sets, sorted.
+func moduleInputs(mod TerraformBlock) []string {
+	var inputs []string
+	for name := range mod.Body.Attributes {
+		if !slices.Contains(moduleArguments, name) {
+			inputs = append(inputs, name)
+		}
+	}
+	slices.Sort(inputs)
+	return inputs
+}
+
+// unsetModuleInputs returns the module's declared variables the call does not set.
+func unsetModuleInputs(mod TerraformBlock, variables []TerraformBlock) []string {
+	var unset []string
+	for _, v := range variables {
+		if _, ok := mod.Body.Attributes[v.Name]; !ok {
+			unset = append(unset, v.Name)
+		}
+	}
+	return unset
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestVersionMatches(t *testing.T) {
+	tests := []struct {
+		version, constraint string
+		want                bool
+	}{
+		{"5.1.0", "", true},
+		{"5.1.0", "5.1.0", true},
+		{"5.1.1", "= 5.1.0", false},
+		{"5.1.0", "!= 5.1.0", false},
+		{"4.2.0", ">= 4.0, < 6.0", true},
+		{"6.0.0", ">= 4.0, < 6.0", false},
+		{"3.9.9", ">= 4.0, < 6.0", false},
+		{"5.1.0", "~> 5.1", true},
+		{"5.9.3", "~> 5.1", true},
+		{"6.0.0", "~> 5.1", false},
+		{"5.0.9", "~> 5.1", false},
+		{"5.1.7", "~> 5.1.0", true},
+		{"5.2.0", "~> 5.1.0", false},
+		{"5.10.0", "~> 5.1.0", false},
+		{"7.0.0", "~> 5", true},
+		{"4.9.0", "~> 5", false},
+		{"5.1.0", "~> latest", false},
+	}
+	for _, tt := range tests {
+		if got := versionMatches(tt.version, tt.constraint); got != tt.want {
+			t.Errorf("versionMatches(%q, %q) = %t, want %t", tt.version, tt.constraint, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// ResourceMove is a moved block: the resource, or module call, at From is
+// now declared at To without being recreated.
+type ResourceMove struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// parseMove extracts the from and to addresses of a moved block. It reports
+// false if either is missing or not a plain address.
+func parseMove(body *hclsyntax.Body) (ResourceMove, bool) {
+	address := func(name string) string {
+		attr, ok := body.Attributes[name]
+		if !ok {
+			return ""
+		}
+		traversal, diags := hcl.AbsTraversalForExpr(attr.Expr)
+		if diags.HasErrors() {
+			return ""
+		}
+		return traversalString(traversal)
+	}
+	move := ResourceMove{From: address("from"), To: address("to")}
+	return move, move.From != "" && move.To != ""
+}
+
+// renamedResources resolves the moved blocks of tf into the address each
+// old address ends up at once every move is applied, so a chain such as
+// a to b and b to c renames both a and b to c. Moves back to where they
+// started are dropped.
+func (tf *TerraformFile) renamedResources() []ResourceMove {
+	next := make(map[string]string, len(tf.Moves))
+	for _, m := range tf.Moves {
+		next[m.From] = m.To
+	}
+
+	var renamed []ResourceMove
+	for _, m := range tf.Moves {
+		to := m.To
+		// A cycle has no final address; stop once every move was followed.
+		for range len(tf.Moves) {
+			after, ok := next[to]
+			if !ok {
+				break
+			}
+			to = after
+		}
+		if to != m.From {
+			renamed = append(renamed, ResourceMove{From: m.From, To: to})
+		}
+	}
+	return renamed
+}
+
+// movesPromptContext describes the renamed resources for the agent, so
+// issues known under an old address are attributed to the new one.
+func (tf *TerraformFile) movesPromptContext() string {
+	renamed := tf.renamedResources()
+	if len(renamed) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("Renamed Resources (moved blocks; the same infrastructure, now declared under the new address, was not recreated):\n")
+	for _, m := range renamed {
+		fmt.Fprintf(&sb, "- %s is now %s\n", m.From, m.To)
+	}
+	return sb.String()
+}
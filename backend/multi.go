@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// MultiRequest defines the structure of the incoming /analyze/multi JSON request.
+type MultiRequest struct {
+	Code       string   `json:"code"`
+	Format     Format   `json:"format,omitempty"`
+	Frameworks []string `json:"frameworks"`
+}
+
+// MultiFrameworkResult holds the outcome of analyzing against one framework.
+// Status is "done" or "failed"; a failed framework carries Error instead of findings.
+type MultiFrameworkResult struct {
+	Framework string    `json:"framework"`
+	Status    string    `json:"status"`
+	Findings  []Finding `json:"findings,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// MultiResponse defines the structure of the /analyze/multi JSON response.
+type MultiResponse struct {
+	Results []MultiFrameworkResult `json:"results"`
+}
+
+// analyzeMultiHandler handles the /analyze/multi endpoint. The code is
+// analyzed against every requested framework concurrently, each by its own
+// agent, and a framework that fails does not fail the others.
+func (api *BedrockConverseAPI) analyzeMultiHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	logger := loggerFromContext(r.Context())
+
+	var req MultiRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	if req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query text is empty or not a string")
+		return
+	}
+	if api.rejectPromptInjection(w, logger, req.Code) {
+		return
+	}
+	if len(req.Frameworks) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "At least one framework is required")
+		return
+	}
+	var fws []Framework
+	for _, id := range req.Frameworks {
+		if id == "" {
+			writeJSONError(w, http.StatusBadRequest, "Framework IDs must not be empty")
+			return
+		}
+		fw, err := lookupFramework(id)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !slices.ContainsFunc(fws, func(f Framework) bool { return f.ID == fw.ID }) {
+			fws = append(fws, fw)
+		}
+	}
+
+	source, err := inputCode(req.Format, req.Code)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tf, ok := parseSource(r.Context(), w, "main.tf", source)
+	if !ok {
+		return
+	}
+
+	results := make([]MultiFrameworkResult, len(fws))
+	var wg sync.WaitGroup
+	for i, fw := range fws {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// analyzeSource redacts the file in place, so each framework gets its own copy.
+			fwFile := *tf
+			findings, err := api.analyzeSource(r.Context(), logger, source, &fwFile, fw)
+			if err != nil {
+				logger.Warn("Framework analysis failed", "framework", fw.ID, "error", err)
+				results[i] = MultiFrameworkResult{Framework: fw.ID, Status: jobFailed, Error: analysisErrorMessage(err)}
+				return
+			}
+			results[i] = MultiFrameworkResult{Framework: fw.ID, Status: jobDone, Findings: nonNil(findings)}
+		}()
+	}
+	wg.Wait()
+
+	writeJSON(w, http.StatusOK, MultiResponse{Results: results})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// namingRuleID is the rule ID of findings for resources that break the
+// naming conventions.
+const namingRuleID = "NAMING.1"
+
+// nameAttributes names the argument holding the cloud resource name for
+// resource types that do not use name. Instances are named by their Name tag.
+var nameAttributes = map[string]string{
+	"aws_s3_bucket":                     "bucket",
+	"aws_db_instance":                   "identifier",
+	"aws_rds_cluster":                   "cluster_identifier",
+	"aws_elasticache_cluster":           "cluster_id",
+	"aws_elasticache_replication_group": "replication_group_id",
+	"aws_lambda_function":               "function_name",
+	"aws_instance":                      "tags",
+}
+
+// NamingConventions describes the names resources must have.
+type NamingConventions struct {
+	Prefix string `json:"prefix,omitempty"`
+	// Suffix is enforced only when SuffixRequired is set.
+	Suffix         string `json:"suffix,omitempty"`
+	SuffixRequired bool   `json:"suffix_required,omitempty"`
+	Pattern        string `json:"pattern,omitempty"`
+	// ResourceTypes limits the check to these types; empty checks every resource.
+	ResourceTypes []string `json:"resource_types,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// NamingRequest defines the structure of the incoming /analyze/naming JSON request.
+type NamingRequest struct {
+	Code        string            `json:"code"`
+	Format      Format            `json:"format,omitempty"`
+	Conventions NamingConventions `json:"conventions"`
+}
+
+// NamingResponse defines the structure of the /analyze/naming JSON response.
+// Skipped lists resources whose name is computed or left for the provider
+// to generate, and so cannot be checked without running Terraform.
+type NamingResponse struct {
+	Findings []Finding `json:"findings"`
+	Skipped  []string  `json:"skipped,omitempty"`
+}
+
+// analyzeNamingHandler handles the /analyze/naming endpoint. It checks
+// every resource's name against the naming conventions locally, without
+// invoking Bedrock.
+func (api *BedrockConverseAPI) analyzeNamingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	var req NamingRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	if req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query text is empty or not a string")
+		return
+	}
+	conv := req.Conventions
+	if conv.Prefix == "" && !conv.SuffixRequired && conv.Pattern == "" {
+		writeJSONError(w, http.StatusBadRequest, "conventions must set at least one of prefix, suffix_required or pattern")
+		return
+	}
+	if conv.SuffixRequired && conv.Suffix == "" {
+		writeJSONError(w, http.StatusBadRequest, "conventions.suffix is required when suffix_required is set")
+		return
+	}
+	if conv.Pattern != "" {
+		var err error
+		if conv.re, err = regexp.Compile(conv.Pattern); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid conventions.pattern: %v", err))
+			return
+		}
+	}
+
+	source, err := inputCode(req.Format, req.Code)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tf, ok := parseSource(r.Context(), w, "main.tf", source)
+	if !ok {
+		return
+	}
+
+	findings, skipped := tf.namingViolations(conv)
+	writeJSON(w, http.StatusOK, NamingResponse{Findings: nonNil(findings), Skipped: skipped})
+}
+
+// namingViolations returns a finding for each resource whose name breaks
+// conv, and the addresses of resources whose name is not literal.
+func (tf *TerraformFile) namingViolations(conv NamingConventions) ([]Finding, []string) {
+	var findings []Finding
+	var skipped []string
+	for _, res := range tf.Resources {
+		if len(conv.ResourceTypes) > 0 && !slices.Contains(conv.ResourceTypes, res.Type) {
+			continue
+		}
+		name, ok := resourceName(res)
+		if !ok {
+			skipped = append(skipped, res.Address())
+			continue
+		}
+
+		var problems []string
+		if conv.Prefix != "" && !strings.HasPrefix(name, conv.Prefix) {
+			problems = append(problems, fmt.Sprintf("does not start with %q", conv.Prefix))
+		}
+		if conv.SuffixRequired && !strings.HasSuffix(name, conv.Suffix) {
+			problems = append(problems, fmt.Sprintf("does not end with %q", conv.Suffix))
+		}
+		if conv.re != nil && !conv.re.MatchString(name) {
+			problems = append(problems, fmt.Sprintf("does not match %s", conv.Pattern))
+		}
+		if len(problems) == 0 {
+			continue
+		}
+		findings = append(findings, Finding{
+			Severity:     SeverityLow,
+			ResourceType: res.Type,
+			RuleID:       namingRuleID,
+			Description:  fmt.Sprintf("%s name %q %s", res.Address(), name, strings.Join(problems, ", ")),
+		})
+	}
+	return findings, skipped
+}
+
+// resourceName returns the literal cloud name of a resource. It reports
+// false if the name is computed or not set.
+func resourceName(b TerraformBlock) (string, bool) {
+	attr, ok := nameAttributes[b.Type]
+	if !ok {
+		attr = "name"
+	}
+	if attr != "tags" {
+		if _, set := b.Body.Attributes[attr]; set || ok {
+			name := literalString(b, attr)
+			return name, name != ""
+		}
+	}
+
+	tags, _, ok := literalValue(b, "tags")
+	if !ok || !tags.Type().IsObjectType() || !tags.Type().HasAttribute("Name") {
+		return "", false
+	}
+	name := tags.GetAttr("Name")
+	if name.IsNull() || name.Type() != cty.String {
+		return "", false
+	}
+	return name.AsString(), name.AsString() != ""
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Response formats of /analyze, chosen by the Accept header.
+const (
+	textContentType  = "text/plain"
+	sarifContentType = "application/sarif+json"
+)
+
+// findingCountHeader reports how many findings an analysis response holds,
+// whatever its format.
+const findingCountHeader = "X-Finding-Count"
+
+// analysisOutput is how a non-streamed analysis is returned: the sort_by
+// order of its findings and the negotiated format.
+type analysisOutput struct {
+	SortBy string
+	Format string
+}
+
+// negotiateFormat returns the media type to answer an /analyze request
+// with: JSON without an Accept header or for */*, else the first of JSON,
+// plain text or SARIF the client lists. It reports false if the client
+// accepts none of them.
+func negotiateFormat(r *http.Request) (string, bool) {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return jsonContentType, true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch mediaType {
+		case jsonContentType, "*/*", "application/*":
+			return jsonContentType, true
+		case textContentType, "text/*":
+			return textContentType, true
+		case sarifContentType:
+			return sarifContentType, true
+		}
+	}
+	return "", false
+}
+
+// writeAnalysis writes resp as the format negotiated for the request: JSON,
+// numbered findings in plain text, or a SARIF log of the findings in tf.
+func (api *BedrockConverseAPI) writeAnalysis(w http.ResponseWriter, format string, tf *TerraformFile, fw Framework, resp AnalyzeResponse) {
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set(findingCountHeader, strconv.Itoa(len(resp.Findings)))
+	switch format {
+	case textContentType:
+		w.Header().Set("Content-Type", textContentType+"; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, analysisText(resp))
+	case sarifContentType:
+		w.Header().Set("Content-Type", sarifContentType)
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(api.sarifLog(tf, tf.Filename, fw, resp.Findings)); err != nil {
+			slog.Error("Failed to encode response", "error", err)
+		}
+	default:
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// analysisText formats resp for reading in a terminal, one numbered
+// finding per paragraph.
+func analysisText(resp AnalyzeResponse) string {
+	var sb strings.Builder
+	switch len(resp.Findings) {
+	case 0:
+		sb.WriteString("No findings.\n")
+	case 1:
+		sb.WriteString("1 finding:\n")
+	default:
+		fmt.Fprintf(&sb, "%d findings:\n", len(resp.Findings))
+	}
+	for i, f := range resp.Findings {
+		fmt.Fprintf(&sb, "\n%d.", i+1)
+		for _, s := range []string{bracketed(f.Severity), f.RuleID, bracketed(f.ResourceType)} {
+			if s != "" {
+				sb.WriteString(" " + s)
+			}
+		}
+		fmt.Fprintf(&sb, "\n   %s\n", f.Description)
+		if f.RemediationCode != "" {
+			sb.WriteString("   Remediation:\n")
+			for _, line := range strings.Split(strings.TrimRight(f.RemediationCode, "\n"), "\n") {
+				sb.WriteString("     " + line + "\n")
+			}
+		}
+	}
+	for _, warning := range resp.SecretWarnings {
+		fmt.Fprintf(&sb, "\nWarning: %s\n", warning)
+	}
+	if len(resp.VariableWarnings) > 0 {
+		fmt.Fprintf(&sb, "\nWarning: variables without a value: %s\n", strings.Join(resp.VariableWarnings, ", "))
+	}
+	if resp.Truncated {
+		sb.WriteString("\nThe code has more issues than were reported.\n")
+	}
+	return sb.String()
+}
+
+// bracketed wraps s in square brackets, leaving "" empty.
+func bracketed(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "[" + s + "]"
+}
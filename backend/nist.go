@@ -0,0 +1,51 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// nistControlFamilies names the NIST SP 800-53 Rev. 5 control families by
+// their identifier.
+var nistControlFamilies = map[string]string{
+	"AC": "Access Control",
+	"AT": "Awareness and Training",
+	"AU": "Audit and Accountability",
+	"CA": "Assessment, Authorization, and Monitoring",
+	"CM": "Configuration Management",
+	"CP": "Contingency Planning",
+	"IA": "Identification and Authentication",
+	"IR": "Incident Response",
+	"MA": "Maintenance",
+	"MP": "Media Protection",
+	"PE": "Physical and Environmental Protection",
+	"PL": "Planning",
+	"PM": "Program Management",
+	"PS": "Personnel Security",
+	"PT": "PII Processing and Transparency",
+	"RA": "Risk Assessment",
+	"SA": "System and Services Acquisition",
+	"SC": "System and Communications Protection",
+	"SI": "System and Information Integrity",
+	"SR": "Supply Chain Risk Management",
+}
+
+// nistControlPattern matches a NIST SP 800-53 control identifier with an
+// optional enhancement, such as SC-28 or SC-28(1).
+var nistControlPattern = regexp.MustCompile(`\b([A-Z]{2})-(\d{1,2})(\(\d{1,2}\))?`)
+
+// tagNISTControl sets the NIST control and family of f from the control
+// the agent gave or, failing that, the one cited in its rule ID, such as
+// NIST.SC-28(1). Identifiers outside the known families are dropped.
+func tagNISTControl(f *Finding) {
+	source := f.NISTControlID
+	if source == "" && strings.HasPrefix(f.RuleID, "NIST.") {
+		source = f.RuleID
+	}
+	m := nistControlPattern.FindStringSubmatch(strings.ToUpper(source))
+	if m == nil || nistControlFamilies[m[1]] == "" {
+		f.NISTControlID, f.ControlFamily = "", ""
+		return
+	}
+	f.NISTControlID, f.ControlFamily = m[0], m[1]
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+// openAPISpec is the OpenAPI 3.1 description of the API, kept as YAML for
+// editing and served as JSON.
+//
+//go:embed api/openapi.yaml
+var openAPISpec []byte
+
+// swaggerUIPage renders the embedded spec with Swagger UI, loaded from a
+// CDN.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Terraform Compliance Backend API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// loadOpenAPISpec converts the embedded OpenAPI spec to JSON.
+func loadOpenAPISpec() ([]byte, error) {
+	var spec map[string]any
+	if err := yaml.Unmarshal(openAPISpec, &spec); err != nil {
+		return nil, fmt.Errorf("failed to decode OpenAPI spec: %w", err)
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OpenAPI spec: %w", err)
+	}
+	return data, nil
+}
+
+// openAPIHandler handles the /openapi.json endpoint.
+func (api *BedrockConverseAPI) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(api.OpenAPI)
+}
+
+// docsHandler handles the /docs endpoint, browsing the API description in
+// Swagger UI.
+func (api *BedrockConverseAPI) docsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, swaggerUIPage)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// owaspManifest lists the OWASP IaC Security Top 10 categories and the
+// categories each rule falls under.
+//
+//go:embed owasp/iac_top10.json
+var owaspManifest []byte
+
+// OWASPMapping maps rule IDs to OWASP IaC Security Top 10 categories, such
+// as IaC-SEC-01, named in Categories.
+type OWASPMapping struct {
+	Categories map[string]string   `json:"categories"`
+	Rules      map[string][]string `json:"rules"`
+}
+
+// owaspMapping holds the decoded OWASP manifest. It is loaded at startup,
+// before any analysis runs.
+var owaspMapping OWASPMapping
+
+// loadOWASPMapping decodes the embedded OWASP manifest.
+func loadOWASPMapping() (OWASPMapping, error) {
+	var m OWASPMapping
+	if err := json.Unmarshal(owaspManifest, &m); err != nil {
+		return OWASPMapping{}, fmt.Errorf("failed to decode OWASP categories: %w", err)
+	}
+	for id, categories := range m.Rules {
+		for _, c := range categories {
+			if _, ok := m.Categories[c]; !ok {
+				return OWASPMapping{}, fmt.Errorf("unknown OWASP category %q for rule %s", c, id)
+			}
+		}
+	}
+	return m, nil
+}
+
+// validateOWASPCategory checks a category given to filter or group
+// findings by.
+func validateOWASPCategory(category string) error {
+	if _, ok := owaspMapping.Categories[category]; !ok {
+		return fmt.Errorf("owasp_category must be one of %v, got %q", sortedKeys(owaspMapping.Categories), category)
+	}
+	return nil
+}
+
+// withOWASPCategories tags each finding with the OWASP categories of its
+// rule. Findings for rules the manifest does not list are left untagged.
+func withOWASPCategories(findings []Finding) []Finding {
+	for i, f := range findings {
+		findings[i].OWASPCategories = owaspMapping.Rules[f.RuleID]
+	}
+	return findings
+}
+
+// filterByOWASPCategory returns the findings in the given OWASP category.
+// Findings stored before categories were tagged are tagged first.
+func filterByOWASPCategory(findings []Finding, category string) []Finding {
+	return slices.DeleteFunc(withOWASPCategories(findings), func(f Finding) bool {
+		return !slices.Contains(f.OWASPCategories, category)
+	})
+}
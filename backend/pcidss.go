@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// pciEncryptionAttributes names the attribute that enables encryption at
+// rest for each resource type the PCI DSS pre-pass checks. Both default to
+// false, so leaving them unset stores data unencrypted.
+var pciEncryptionAttributes = map[string]string{
+	"aws_db_instance":                   "storage_encrypted",
+	"aws_elasticache_replication_group": "at_rest_encryption_enabled",
+}
+
+// pciAnalyzer reports cardholder data stores without encryption at rest
+// (PCI DSS Req 3.5.1) when analyzing against PCI DSS, without waiting for
+// the agent.
+type pciAnalyzer struct{}
+
+// Analyze implements Analyzer.
+func (pciAnalyzer) Analyze(ctx context.Context, tf TerraformFile) ([]Finding, error) {
+	if req, ok := analysisRequestFromContext(ctx); !ok || req.Framework.ID != "pci-dss" {
+		return nil, nil
+	}
+
+	var findings []Finding
+	for _, b := range tf.Resources {
+		attr, ok := pciEncryptionAttributes[b.Type]
+		if !ok {
+			continue
+		}
+		var problem string
+		if v, _, ok := literalValue(b, attr); ok && v.Type() == cty.Bool && v.False() {
+			problem = attr + " = false"
+		} else if _, set := b.Body.Attributes[attr]; !set {
+			problem = attr + " is not set"
+		} else {
+			continue
+		}
+		findings = append(findings, Finding{
+			Severity:        SeverityCritical,
+			ResourceType:    b.Type,
+			RuleID:          "PCI.3.5.1",
+			Description:     fmt.Sprintf("%s stores data unencrypted (%s); PCI DSS Req 3.5.1 requires stored cardholder data to be rendered unreadable", b, problem),
+			RemediationCode: attr + " = true",
+		})
+	}
+	return findings, nil
+}
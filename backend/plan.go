@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Format is the format of submitted code, one of the format constants.
+type Format string
+
+// Input formats accepted by /analyze.
+const (
+	formatHCL        Format = "hcl"
+	formatPlanJSON   Format = "plan-json"
+	formatTerragrunt Format = "terragrunt"
+	formatPulumiYAML Format = "pulumi-yaml"
+)
+
+// planDocument is the subset of `terraform show -json` plan output used for analysis.
+type planDocument struct {
+	FormatVersion   string               `json:"format_version"`
+	ResourceChanges []planResourceChange `json:"resource_changes"`
+	// ResourceDrift lists the changes made outside Terraform since the
+	// last apply, with Before the recorded state and After the actual one.
+	ResourceDrift []planResourceChange `json:"resource_drift"`
+}
+
+// planResourceChange describes the planned change to one resource instance.
+type planResourceChange struct {
+	Address string `json:"address"`
+	Mode    string `json:"mode"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Change  struct {
+		Actions      []string       `json:"actions"`
+		Before       map[string]any `json:"before"`
+		After        map[string]any `json:"after"`
+		AfterUnknown any            `json:"after_unknown"`
+	} `json:"change"`
+}
+
+// inputCode returns the Terraform source to analyze for the requested format,
+// detected by DetectFormat when empty. Plan JSON and Pulumi YAML are rendered
+// as pseudo-HCL, and terragrunt.hcl as the module it deploys, so the rest of
+// the pipeline can treat every format the same way.
+func inputCode(format Format, code string) (string, error) {
+	switch format {
+	case "":
+		return inputCode(DetectFormat(code), code)
+	case formatHCL:
+		return code, nil
+	case formatPlanJSON:
+		return planToHCL(code)
+	case formatTerragrunt:
+		return terragruntToHCL(code)
+	case formatPulumiYAML:
+		return pulumiToHCL(code)
+	default:
+		return "", fmt.Errorf("unknown format %q: must be one of %s, %s, %s, %s", format, formatHCL, formatPlanJSON, formatTerragrunt, formatPulumiYAML)
+	}
+}
+
+// planToHCL reconstructs pseudo-HCL from the resource_changes of a Terraform
+// plan, using the planned (after) values of each resource. The result exposes
+// resolved variables and provider defaults that are invisible in source code.
+func planToHCL(plan string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(plan))
+	dec.UseNumber()
+
+	var doc planDocument
+	if err := dec.Decode(&doc); err != nil {
+		return "", fmt.Errorf("invalid Terraform plan JSON: %w", err)
+	}
+	if doc.FormatVersion == "" {
+		return "", errors.New("invalid Terraform plan JSON: missing format_version, expected `terraform show -json` output")
+	}
+
+	var sb strings.Builder
+	for _, rc := range doc.ResourceChanges {
+		actions := strings.Join(rc.Change.Actions, ", ")
+		if rc.Change.After == nil {
+			fmt.Fprintf(&sb, "# %s will be destroyed (%s)\n\n", rc.Address, actions)
+			continue
+		}
+
+		keyword := "resource"
+		if rc.Mode == "data" {
+			keyword = "data"
+		}
+		fmt.Fprintf(&sb, "# %s (%s)\n", rc.Address, actions)
+		fmt.Fprintf(&sb, "%s %q %q {\n", keyword, rc.Type, rc.Name)
+		if unknown, ok := rc.Change.AfterUnknown.(map[string]any); ok {
+			for _, key := range sortedKeys(unknown) {
+				if unknown[key] == true {
+					fmt.Fprintf(&sb, "  # %s: known after apply\n", key)
+				}
+			}
+		}
+		writePlanBody(&sb, rc.Change.After, 1)
+		sb.WriteString("}\n\n")
+	}
+
+	return sb.String(), nil
+}
+
+// writePlanBody writes the attributes of obj at the given indent level. Lists
+// of objects are written as repeated nested blocks, matching how they appear
+// in Terraform source; null values and empty lists are omitted.
+func writePlanBody(sb *strings.Builder, obj map[string]any, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, key := range sortedKeys(obj) {
+		switch v := obj[key].(type) {
+		case nil:
+			continue
+		case []any:
+			if len(v) == 0 {
+				continue
+			}
+			if blocks, ok := objectList(v); ok {
+				for _, block := range blocks {
+					fmt.Fprintf(sb, "%s%s {\n", indent, key)
+					writePlanBody(sb, block, depth+1)
+					fmt.Fprintf(sb, "%s}\n", indent)
+				}
+				continue
+			}
+		}
+		fmt.Fprintf(sb, "%s%s = %s\n", indent, key, planValue(obj[key], depth))
+	}
+}
+
+// planValue renders a decoded JSON value as an HCL expression.
+func planValue(v any, depth int) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return hclString(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = planValue(item, depth)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]any:
+		if len(v) == 0 {
+			return "{}"
+		}
+		var buf bytes.Buffer
+		indent := strings.Repeat("  ", depth)
+		buf.WriteString("{\n")
+		for _, key := range sortedKeys(v) {
+			fmt.Fprintf(&buf, "%s  %s = %s\n", indent, hclString(key), planValue(v[key], depth+1))
+		}
+		buf.WriteString(indent + "}")
+		return buf.String()
+	default:
+		return hclString(fmt.Sprint(v))
+	}
+}
+
+// hclString quotes s as an HCL string literal, escaping template sequences so
+// plan values are never interpreted as interpolations.
+func hclString(s string) string {
+	s = strings.NewReplacer("${", "$${", "%{", "%%{").Replace(s)
+	return strconv.Quote(s)
+}
+
+// objectList reports whether every element of list is an object, returning them.
+func objectList(list []any) ([]map[string]any, bool) {
+	objects := make([]map[string]any, len(list))
+	for i, item := range list {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		objects[i] = obj
+	}
+	return objects, true
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
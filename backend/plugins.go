@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"plugin"
+	"slices"
+	"strings"
+)
+
+// pluginSymbol is the function each analyzer plugin must export. Plugins
+// cannot import package main, so the contract uses plain types: the file
+// name and redacted source go in, and a JSON array of findings in the
+// /analyze schema comes out. For example:
+//
+//	func Analyze(ctx context.Context, filename, source string) ([]byte, error)
+const pluginSymbol = "Analyze"
+
+// pluginAnalyzeFunc is the type of the exported pluginSymbol.
+type pluginAnalyzeFunc = func(ctx context.Context, filename, source string) ([]byte, error)
+
+// pluginAnalyzer adapts a plugin's Analyze function to the Analyzer interface.
+type pluginAnalyzer struct {
+	analyze pluginAnalyzeFunc
+}
+
+// Analyze implements Analyzer.
+func (a pluginAnalyzer) Analyze(ctx context.Context, tf TerraformFile) ([]Finding, error) {
+	out, err := a.analyze(ctx, tf.Filename, tf.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	if err := json.Unmarshal(out, &findings); err != nil {
+		return nil, fmt.Errorf("plugin returned invalid findings: %w", err)
+	}
+	for i := range findings {
+		findings[i].Severity = normalizeSeverity(findings[i].Severity)
+	}
+	return findings, nil
+}
+
+// loadAnalyzerPlugins registers every Go plugin (*.so, built with
+// -buildmode=plugin) in dir as an analyzer named after its file.
+func loadAnalyzerPlugins(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return fmt.Errorf("failed to list plugins in %s: %w", dir, err)
+	}
+
+	registered := analyzerNames()
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".so")
+		if slices.Contains(registered, name) {
+			return fmt.Errorf("plugin %s: analyzer %q is already registered", path, name)
+		}
+
+		p, err := plugin.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open plugin %s: %w", path, err)
+		}
+		sym, err := p.Lookup(pluginSymbol)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", path, err)
+		}
+		fn, ok := sym.(pluginAnalyzeFunc)
+		if !ok {
+			return fmt.Errorf("plugin %s: %s has type %T, want %T", path, pluginSymbol, sym, pluginAnalyzeFunc(nil))
+		}
+
+		RegisterAnalyzer(name, pluginAnalyzer{analyze: fn})
+		slog.Info("Loaded analyzer plugin", "analyzer", name, "path", path)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import "strings"
+
+// explainPromptTemplate is the instruction sent to the agent for /explain.
+const explainPromptTemplate = `
+Your task is to explain the compliance control {ruleID}{resourceClause} using the policies in the knowledge base.
+
+Describe which AWS service the control protects, the attack surface it mitigates, and the blast radius of non-compliance. Show a compliant Terraform example and contrast it with a non-compliant one, and list the relevant AWS documentation links.
+
+Respond with a single JSON object with the fields rule_id, title, explanation, compliant_example, non_compliant_example, and references (an array of URLs).
+
+Exclusions: Do NOT include markdown formatting or any text outside of the JSON object.
+`
+
+// buildExplainPrompt fills the explain prompt template for a rule, optionally
+// focused on a single resource type.
+func buildExplainPrompt(ruleID, resourceType string) string {
+	resourceClause := ""
+	if resourceType != "" {
+		resourceClause = " as it applies to the Terraform resource type " + resourceType
+	}
+
+	return strings.NewReplacer(
+		"{ruleID}", ruleID,
+		"{resourceClause}", resourceClause,
+	).Replace(explainPromptTemplate)
+}
+
+// autofixPromptTemplate is the instruction sent to the agent for /autofix.
+const autofixPromptTemplate = `
+Your task is to remediate the following compliance findings in the Terraform code below using the policies in the knowledge base: {findingIDs}
+
+Terraform Code:
+{code}
+
+Change only what is needed to resolve those findings and leave all other code, comments and formatting unchanged. Placeholders of the form <REDACTED_N> stand for secrets; keep them exactly as they are unless removing the secret is the remediation.
+
+Respond with a single JSON object with the fields fixed (the complete modified Terraform code) and applied_fixes (an array of the finding IDs you remediated).
+
+Exclusions: Do NOT include markdown formatting or any text outside of the JSON object.
+`
+
+// buildAutofixPrompt fills the autofix prompt template. Unlike analysis
+// prompts, the code keeps its line breaks so the fixed version can be diffed.
+func buildAutofixPrompt(code string, findingIDs []string) string {
+	return strings.NewReplacer(
+		"{findingIDs}", strings.Join(findingIDs, ", "),
+		"{code}", code,
+	).Replace(autofixPromptTemplate)
+}
+
+// cleanCode flattens the code onto one line for the agent.
+func cleanCode(code string) string {
+	return strings.ReplaceAll(code, "\n", " ")
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// defaultAnalysisTemplate is the built-in analysis prompt, used unless
+// PROMPT_TEMPLATE_FILE names a replacement.
+//
+//go:embed prompts/analysis.tmpl
+var defaultAnalysisTemplate string
+
+// PromptData holds the values available to an analysis prompt template.
+type PromptData struct {
+	// Code is the redacted Terraform source, flattened onto one line.
+	Code string
+	// ResourceTypes is the comma-separated list of resource types declared in the code.
+	ResourceTypes string
+	// Framework describes the policies to check against; FrameworkName is its display name.
+	Framework     string
+	FrameworkName string
+	// Blocks summarizes the blocks declared alongside the code.
+	Blocks         string
+	MaxSuggestions int
+	OrgName        string
+}
+
+// PromptTemplate renders analysis prompts from a text/template.
+type PromptTemplate struct {
+	tmpl *template.Template
+	// text is the template source, compared on reload.
+	text string
+}
+
+// parsePromptTemplate parses and validates an analysis prompt template.
+func parsePromptTemplate(name, text string) (*PromptTemplate, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	pt := &PromptTemplate{tmpl: tmpl, text: text}
+
+	// Referencing an unknown field only fails at execution time, so render
+	// once with sample data to catch it now.
+	const marker = "\x00code\x00"
+	out, err := pt.Render(PromptData{Code: marker, ResourceTypes: "aws_s3_bucket", Framework: "policies", FrameworkName: "Framework", Blocks: "Resources: aws_s3_bucket.example\n", MaxSuggestions: 1, OrgName: "Example"})
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(out, marker) {
+		return nil, errors.New("template must include {{.Code}}")
+	}
+	return pt, nil
+}
+
+// loadPromptTemplate reads the analysis prompt template from path, or
+// returns the built-in template when path is empty.
+func loadPromptTemplate(path string) (*PromptTemplate, error) {
+	if path == "" {
+		return parsePromptTemplate("analysis", defaultAnalysisTemplate)
+	}
+
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt template: %w", err)
+	}
+	pt, err := parsePromptTemplate(path, string(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template %s: %w", path, err)
+	}
+	return pt, nil
+}
+
+// Render executes the template with data.
+func (pt *PromptTemplate) Render(data PromptData) (string, error) {
+	var sb strings.Builder
+	if err := pt.tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// buildAnalysisPrompt renders the analysis prompt for the code, the resource
+// types to focus on, the blocks declared alongside it, and the framework to
+// check against. For a single file blocks is the file itself; for a batch it
+// is the whole module.
+func (api *BedrockConverseAPI) buildAnalysisPrompt(ctx context.Context, code string, resourceTypes []string, blocks *TerraformFile, fw Framework) (string, error) {
+	modules, moduleTypes := api.moduleContext(ctx, blocks)
+	resourceTypes = append(slices.Clone(resourceTypes), moduleTypes...)
+	slices.Sort(resourceTypes)
+
+	prompt, err := api.Prompt().Render(PromptData{
+		Code:           cleanCode(code),
+		ResourceTypes:  strings.Join(slices.Compact(resourceTypes), ", "),
+		Framework:      fw.Guidance,
+		FrameworkName:  fw.Name,
+		Blocks:         blocks.promptContext() + modules,
+		MaxSuggestions: api.maxSuggestions(blocks),
+		OrgName:        api.Config().OrgName,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render analysis prompt: %w", err)
+	}
+	return prompt, nil
+}
+
+// maxSuggestionsLimit bounds the suggestions a request may ask for.
+const maxSuggestionsLimit = 20
+
+// validateMaxSuggestions checks a requested suggestion count, where 0
+// selects the configured default.
+func validateMaxSuggestions(n int) error {
+	if n < 0 || n > maxSuggestionsLimit {
+		return fmt.Errorf("max_suggestions must be between 1 and %d, got %d; omit it to use the default", maxSuggestionsLimit, n)
+	}
+	return nil
+}
+
+// resourcesPerExtraSuggestion is how many resources earn the agent one
+// suggestion beyond the configured default.
+const resourcesPerExtraSuggestion = 10
+
+// maxSuggestions returns the number of suggestions the agent may give for
+// tf: the count its request asked for or, by default, MAX_SUGGESTIONS plus
+// one for every resourcesPerExtraSuggestion resources, up to
+// maxSuggestionsLimit.
+func (api *BedrockConverseAPI) maxSuggestions(tf *TerraformFile) int {
+	if tf.MaxSuggestions > 0 {
+		return tf.MaxSuggestions
+	}
+	return max(api.Config().MaxSuggestions, min(api.Config().MaxSuggestions+len(tf.Resources)/resourcesPerExtraSuggestion, maxSuggestionsLimit))
+}
+
+// ValidatePromptRequest defines the structure of the incoming /validate-prompt JSON request.
+type ValidatePromptRequest struct {
+	Template string `json:"template"`
+}
+
+// ValidatePromptResponse defines the structure of the /validate-prompt JSON response.
+type ValidatePromptResponse struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// validatePromptHandler handles the /validate-prompt endpoint, checking a
+// custom analysis template without invoking Bedrock.
+func (api *BedrockConverseAPI) validatePromptHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	var req ValidatePromptRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Template == "" {
+		writeJSONError(w, http.StatusBadRequest, "Template is empty")
+		return
+	}
+
+	if _, err := parsePromptTemplate("template", req.Template); err != nil {
+		writeJSON(w, http.StatusOK, ValidatePromptResponse{Valid: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, ValidatePromptResponse{Valid: true})
+}
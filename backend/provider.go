@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ProviderIssue is a credential security problem found in a provider block.
+type ProviderIssue struct {
+	RuleID    string
+	Provider  string
+	Attribute string
+	Line      int
+	Message   string
+}
+
+// String formats the issue for the analysis prompt.
+func (i ProviderIssue) String() string {
+	return fmt.Sprintf("%s provider %s line %d: %s", i.RuleID, i.Provider, i.Line, i.Message)
+}
+
+// providerCheck inspects one provider block and reports any issues.
+type providerCheck func(b TerraformBlock) []ProviderIssue
+
+// providerChecks are the credential rules applied to each provider, keyed by
+// provider name.
+var providerChecks = map[string][]providerCheck{
+	"aws": {
+		hardcodedAttributes("PROVIDER.AWS.1", "AWS provider should not have hardcoded credentials", "access_key", "secret_key", "token"),
+		enabledAttribute("PROVIDER.AWS.2", "skip_credentials_validation", "AWS provider should not skip credentials validation"),
+	},
+	"azurerm": {
+		hardcodedAttributes("PROVIDER.AZURE.1", "Azure provider should not have hardcoded credentials", "client_secret", "client_certificate_password"),
+		requireManagedIdentity,
+	},
+	"google": {
+		embeddedServiceAccount,
+		hardcodedAttributes("PROVIDER.GCP.2", "GCP provider should not have a hardcoded access token", "access_token"),
+	},
+}
+
+func init() {
+	providerChecks["google-beta"] = providerChecks["google"]
+}
+
+// literalValue returns the value of attribute name in b when it is a constant
+// in the source rather than a variable, local or function call.
+func literalValue(b TerraformBlock, name string) (cty.Value, int, bool) {
+	if b.Body == nil {
+		return cty.NilVal, 0, false
+	}
+	attr, ok := b.Body.Attributes[name]
+	if !ok || len(attr.Expr.Variables()) > 0 {
+		return cty.NilVal, 0, false
+	}
+	v, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || !v.IsWhollyKnown() || v.IsNull() {
+		return cty.NilVal, 0, false
+	}
+	return v, attr.SrcRange.Start.Line, true
+}
+
+// literalString returns the value of attribute name in b when it is a
+// constant string, or "" otherwise.
+func literalString(b TerraformBlock, name string) string {
+	if v, _, ok := literalValue(b, name); ok && v.Type() == cty.String {
+		return v.AsString()
+	}
+	return ""
+}
+
+// literalInt returns the value of attribute name in b and true when it is a
+// constant whole number.
+func literalInt(b TerraformBlock, name string) (int, bool) {
+	v, _, ok := literalValue(b, name)
+	if !ok || v.Type() != cty.Number {
+		return 0, false
+	}
+	n, accuracy := v.AsBigFloat().Int64()
+	if accuracy != 0 {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// hardcodedAttributes reports any of attrs set to a literal string.
+func hardcodedAttributes(ruleID, message string, attrs ...string) providerCheck {
+	return func(b TerraformBlock) []ProviderIssue {
+		var issues []ProviderIssue
+		for _, name := range attrs {
+			if v, line, ok := literalValue(b, name); ok && v.Type() == cty.String && v.AsString() != "" {
+				issues = append(issues, ProviderIssue{ruleID, b.Type, name, line, message + ": " + name + " is hardcoded"})
+			}
+		}
+		return issues
+	}
+}
+
+// enabledAttribute reports attr when it is set to true.
+func enabledAttribute(ruleID, attr, message string) providerCheck {
+	return func(b TerraformBlock) []ProviderIssue {
+		if v, line, ok := literalValue(b, attr); ok && v.Type() == cty.Bool && v.True() {
+			return []ProviderIssue{{ruleID, b.Type, attr, line, message + ": " + attr + " = true"}}
+		}
+		return nil
+	}
+}
+
+// requireManagedIdentity reports an azurerm provider not using managed identity.
+func requireManagedIdentity(b TerraformBlock) []ProviderIssue {
+	if v, _, ok := literalValue(b, "use_msi"); ok && v.Type() == cty.Bool && v.True() {
+		return nil
+	}
+	if _, ok := b.Body.Attributes["use_msi"]; ok {
+		// Set from a variable; its value is decided at plan time.
+		return nil
+	}
+	return []ProviderIssue{{"PROVIDER.AZURE.2", b.Type, "use_msi", b.Line, "Azure provider should use managed identity: use_msi is not enabled"}}
+}
+
+// embeddedServiceAccount reports a google provider whose credentials are an
+// inline service account key rather than a path or environment credentials.
+func embeddedServiceAccount(b TerraformBlock) []ProviderIssue {
+	v, line, ok := literalValue(b, "credentials")
+	if !ok || v.Type() != cty.String {
+		return nil
+	}
+	s := strings.TrimSpace(v.AsString())
+	if !strings.HasPrefix(s, "{") && !strings.Contains(s, "private_key") {
+		return nil
+	}
+	return []ProviderIssue{{"PROVIDER.GCP.1", b.Type, "credentials", line, "GCP provider should not embed service account JSON in credentials"}}
+}
+
+// providerIssues applies the credential rules to every provider block in tf.
+func (tf *TerraformFile) providerIssues() []ProviderIssue {
+	var issues []ProviderIssue
+	for _, b := range tf.Providers {
+		if b.Body == nil {
+			continue
+		}
+		for _, check := range providerChecks[b.Type] {
+			issues = append(issues, check(b)...)
+		}
+	}
+	return issues
+}
+
+// providerAttributes returns the names of the attributes set in a provider
+// block, sorted. Values are left out since they may be credentials.
+func providerAttributes(body *hclsyntax.Body) []string {
+	if body == nil {
+		return nil
+	}
+	names := make([]string, 0, len(body.Attributes))
+	for name := range body.Attributes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
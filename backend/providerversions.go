@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Rule IDs of provider version findings.
+const (
+	providerBelowMinimumRuleID = "PROVIDER.VERSION.1"
+	providerOutdatedRuleID     = "PROVIDER.VERSION.2"
+)
+
+// providerMajorVersionsBehind is how many major versions behind the latest
+// release a provider may be before it is flagged as outdated.
+const providerMajorVersionsBehind = 2
+
+// minimumVersionsManifest maps provider addresses to the oldest version
+// considered compliant.
+//
+//go:embed providers/minimum_versions.json
+var minimumVersionsManifest []byte
+
+// ProviderMinimum is the oldest compliant version of a provider.
+type ProviderMinimum struct {
+	MinimumVersion string `json:"minimum_version"`
+	Severity       string `json:"severity"`
+	Reason         string `json:"reason"`
+}
+
+// loadProviderMinimums decodes the embedded minimum version manifest, keyed
+// by provider address.
+func loadProviderMinimums() (map[string]ProviderMinimum, error) {
+	var minimums map[string]ProviderMinimum
+	if err := json.Unmarshal(minimumVersionsManifest, &minimums); err != nil {
+		return nil, fmt.Errorf("failed to decode provider minimum versions: %w", err)
+	}
+	for address, m := range minimums {
+		if !isExactVersion(m.MinimumVersion) {
+			return nil, fmt.Errorf("invalid minimum version %q for provider %s", m.MinimumVersion, address)
+		}
+	}
+	return minimums, nil
+}
+
+// ProvidersRequest defines the structure of the incoming /analyze/providers
+// JSON request. CheckLatest compares each provider against its latest
+// release on the Terraform Registry, and is ignored unless
+// ALLOW_MODULE_FETCH permits registry access.
+type ProvidersRequest struct {
+	LockFile    string `json:"lock_file"`
+	CheckLatest bool   `json:"check_latest,omitempty"`
+}
+
+// LockedProvider is a provider selection recorded in .terraform.lock.hcl.
+type LockedProvider struct {
+	Address        string `json:"address"`
+	Version        string `json:"version"`
+	Constraints    string `json:"constraints,omitempty"`
+	MinimumVersion string `json:"minimum_version,omitempty"`
+	LatestVersion  string `json:"latest_version,omitempty"`
+}
+
+// ProvidersResponse defines the structure of the /analyze/providers JSON response.
+type ProvidersResponse struct {
+	Providers []LockedProvider `json:"providers"`
+	Findings  []Finding        `json:"findings"`
+}
+
+// analyzeProvidersHandler handles the /analyze/providers endpoint. It flags
+// locked provider versions older than the minimum version manifest allows.
+func (api *BedrockConverseAPI) analyzeProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	var req ProvidersRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.LockFile == "" {
+		writeJSONError(w, http.StatusBadRequest, "lock_file is required")
+		return
+	}
+
+	lock, ok := parseSource(r.Context(), w, ".terraform.lock.hcl", req.LockFile)
+	if !ok {
+		return
+	}
+
+	resp := ProvidersResponse{Providers: []LockedProvider{}, Findings: []Finding{}}
+	for _, b := range lock.Providers {
+		p := LockedProvider{
+			Address:     b.Type,
+			Version:     literalString(b, "version"),
+			Constraints: literalString(b, "constraints"),
+		}
+		if p.Version == "" {
+			continue
+		}
+		// Pre-release suffixes are ignored when comparing versions.
+		release, _, _ := strings.Cut(p.Version, "-")
+
+		if m, ok := api.Minimums[p.Address]; ok {
+			p.MinimumVersion = m.MinimumVersion
+			if compareVersions(release, m.MinimumVersion) < 0 {
+				resp.Findings = append(resp.Findings, Finding{
+					Severity:    normalizeSeverity(m.Severity),
+					RuleID:      providerBelowMinimumRuleID,
+					Description: fmt.Sprintf("Provider %s %s is older than the minimum compliant version %s: %s", p.Address, p.Version, m.MinimumVersion, m.Reason),
+				})
+			}
+		}
+
+		if req.CheckLatest && api.Modules != nil {
+			latest, err := api.Modules.latestProviderVersion(r.Context(), p.Address)
+			if err != nil {
+				loggerFromContext(r.Context()).Warn("Failed to look up latest provider version", "provider", p.Address, "error", err)
+			} else if latest != "" {
+				p.LatestVersion = latest
+				if majorVersion(latest)-majorVersion(release) >= providerMajorVersionsBehind {
+					resp.Findings = append(resp.Findings, Finding{
+						Severity:    SeverityLow,
+						RuleID:      providerOutdatedRuleID,
+						Description: fmt.Sprintf("Provider %s %s is %d major versions behind the latest release %s", p.Address, p.Version, majorVersion(latest)-majorVersion(release), latest),
+					})
+				}
+			}
+		}
+		resp.Providers = append(resp.Providers, p)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// majorVersion returns the first part of a version number.
+func majorVersion(v string) int {
+	major, _, _ := strings.Cut(v, ".")
+	n, _ := strconv.Atoi(major)
+	return n
+}
+
+// latestProviderVersion returns the highest published release of a public
+// registry provider, or "" for providers hosted elsewhere.
+func (f *moduleFetcher) latestProviderVersion(ctx context.Context, address string) (string, error) {
+	namespace, name, ok := strings.Cut(strings.TrimPrefix(address, registryHost+"/"), "/")
+	if !ok || strings.Contains(name, "/") || strings.Contains(namespace, ".") {
+		return "", nil
+	}
+
+	body, err := f.get(ctx, f.baseURL+"/v1/providers/"+namespace+"/"+name+"/versions")
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Versions []struct {
+			Version string `json:"version"`
+		} `json:"versions"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to decode provider versions: %w", err)
+	}
+
+	var latest string
+	for _, v := range resp.Versions {
+		if isExactVersion(v.Version) && (latest == "" || compareVersions(v.Version, latest) > 0) {
+			latest = v.Version
+		}
+	}
+	return latest, nil
+}
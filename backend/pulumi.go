@@ -0,0 +1,326 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pulumiTranslationNote is returned with analyses of Pulumi YAML programs.
+const pulumiTranslationNote = "Analysis is based on an approximate translation of the Pulumi program to Terraform; resource types without a known Terraform equivalent were left out."
+
+// pulumiResourceTypes maps Pulumi AWS resource types, as module:Type, to
+// their Terraform equivalents.
+var pulumiResourceTypes = map[string]string{
+	"apigateway:RestApi":           "aws_api_gateway_rest_api",
+	"apigateway:Stage":             "aws_api_gateway_stage",
+	"cloudfront:Distribution":      "aws_cloudfront_distribution",
+	"cloudtrail:Trail":             "aws_cloudtrail",
+	"cloudwatch:LogGroup":          "aws_cloudwatch_log_group",
+	"dynamodb:Table":               "aws_dynamodb_table",
+	"ebs:EncryptionByDefault":      "aws_ebs_encryption_by_default",
+	"ebs:Volume":                   "aws_ebs_volume",
+	"ec2:FlowLog":                  "aws_flow_log",
+	"ec2:Instance":                 "aws_instance",
+	"ec2:LaunchTemplate":           "aws_launch_template",
+	"ec2:SecurityGroup":            "aws_security_group",
+	"ec2:SecurityGroupRule":        "aws_security_group_rule",
+	"ec2:Subnet":                   "aws_subnet",
+	"ec2:Vpc":                      "aws_vpc",
+	"ecr:Repository":               "aws_ecr_repository",
+	"efs:FileSystem":               "aws_efs_file_system",
+	"eks:Cluster":                  "aws_eks_cluster",
+	"elasticache:ReplicationGroup": "aws_elasticache_replication_group",
+	"iam:AccessKey":                "aws_iam_access_key",
+	"iam:Policy":                   "aws_iam_policy",
+	"iam:Role":                     "aws_iam_role",
+	"iam:RolePolicy":               "aws_iam_role_policy",
+	"iam:RolePolicyAttachment":     "aws_iam_role_policy_attachment",
+	"iam:User":                     "aws_iam_user",
+	"iam:UserPolicy":               "aws_iam_user_policy",
+	"kms:Key":                      "aws_kms_key",
+	"lambda:Function":              "aws_lambda_function",
+	"lb:Listener":                  "aws_lb_listener",
+	"lb:LoadBalancer":              "aws_lb",
+	"opensearch:Domain":            "aws_opensearch_domain",
+	"rds:Cluster":                  "aws_rds_cluster",
+	"rds:Instance":                 "aws_db_instance",
+	"redshift:Cluster":             "aws_redshift_cluster",
+	"s3:Bucket":                    "aws_s3_bucket",
+	"s3:BucketAclV2":               "aws_s3_bucket_acl",
+	"s3:BucketLoggingV2":           "aws_s3_bucket_logging",
+	"s3:BucketPolicy":              "aws_s3_bucket_policy",
+	"s3:BucketPublicAccessBlock":   "aws_s3_bucket_public_access_block",
+	"s3:BucketServerSideEncryptionConfigurationV2": "aws_s3_bucket_server_side_encryption_configuration",
+	"s3:BucketV2":           "aws_s3_bucket",
+	"s3:BucketVersioningV2": "aws_s3_bucket_versioning",
+	"secretsmanager:Secret": "aws_secretsmanager_secret",
+	"sns:Topic":             "aws_sns_topic",
+	"sqs:Queue":             "aws_sqs_queue",
+}
+
+// translationNote returns the format and note reported with an analysis of
+// code submitted in format, both empty unless the code was translated
+// approximately.
+func translationNote(format Format) (Format, string) {
+	if format == formatPulumiYAML {
+		return formatPulumiYAML, pulumiTranslationNote
+	}
+	return "", ""
+}
+
+// pulumiObjectProperties are properties whose object values are attribute
+// maps in Terraform rather than nested blocks, so their keys are kept.
+var pulumiObjectProperties = []string{"tags", "tags_all", "variables", "environment_variables", "policy", "assume_role_policy"}
+
+// pulumiInterpolation matches a ${resource.property} reference in a Pulumi
+// YAML string.
+var pulumiInterpolation = regexp.MustCompile(`\$\{([A-Za-z0-9_-]+)(?:\.([A-Za-z0-9_.]+))?\}`)
+
+// pulumiProgram is the part of a Pulumi YAML program that declares resources.
+type pulumiProgram struct {
+	Resources map[string]pulumiResource `yaml:"resources"`
+}
+
+// pulumiResource is one entry of a Pulumi YAML program's resources map.
+type pulumiResource struct {
+	Type       string         `yaml:"type"`
+	Properties map[string]any `yaml:"properties"`
+}
+
+// pulumiTypeKey returns the module:Type key of a Pulumi AWS resource type,
+// written either as aws:s3/bucket:Bucket or aws:s3:Bucket, and whether it
+// is an AWS type.
+func pulumiTypeKey(token string) (string, bool) {
+	parts := strings.Split(token, ":")
+	if len(parts) != 3 || parts[0] != "aws" {
+		return "", false
+	}
+	module, _, _ := strings.Cut(parts[1], "/")
+	return module + ":" + parts[2], true
+}
+
+// snakeCase converts a Pulumi camelCase property name to Terraform's
+// snake_case.
+func snakeCase(name string) string {
+	var sb strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Acronyms such as "ARN" in "roleARN" become one word.
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// terraformName converts a Pulumi logical resource name to a valid
+// Terraform block label.
+func terraformName(name string) string {
+	label := strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	if label == "" || !unicode.IsLetter(rune(label[0])) && label[0] != '_' {
+		label = "r_" + label
+	}
+	return label
+}
+
+// pulumiTranslator renders a Pulumi YAML program as Terraform HCL.
+type pulumiTranslator struct {
+	// addresses maps logical resource names to the Terraform address their
+	// translation is declared at.
+	addresses map[string]string
+}
+
+// pulumiToHCL translates a Pulumi YAML program into approximately
+// equivalent Terraform HCL. Properties are renamed to snake_case, objects
+// become nested blocks and ${resource.property} references become
+// Terraform references. Resources of types without a known Terraform
+// equivalent are listed in a comment and left out.
+func pulumiToHCL(code string) (string, error) {
+	var program pulumiProgram
+	if err := yaml.Unmarshal([]byte(code), &program); err != nil {
+		return "", fmt.Errorf("invalid Pulumi YAML: %w", err)
+	}
+	if len(program.Resources) == 0 {
+		return "", errors.New("invalid Pulumi YAML: no resources declared")
+	}
+
+	t := pulumiTranslator{addresses: make(map[string]string)}
+	names := sortedKeys(program.Resources)
+	var skipped []string
+	for _, name := range names {
+		key, _ := pulumiTypeKey(program.Resources[name].Type)
+		if tfType, ok := pulumiResourceTypes[key]; ok {
+			t.addresses[name] = tfType + "." + terraformName(name)
+		} else {
+			skipped = append(skipped, fmt.Sprintf("%s (%s)", name, program.Resources[name].Type))
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Approximate translation of a Pulumi YAML program\n")
+	if len(skipped) > 0 {
+		fmt.Fprintf(&sb, "# Not translated: %s\n", strings.Join(skipped, ", "))
+	}
+	for _, name := range names {
+		address, ok := t.addresses[name]
+		if !ok {
+			continue
+		}
+		tfType, label, _ := strings.Cut(address, ".")
+		fmt.Fprintf(&sb, "\n# %s (%s)\n", name, program.Resources[name].Type)
+		fmt.Fprintf(&sb, "resource %q %q {\n", tfType, label)
+		t.writeBody(&sb, program.Resources[name].Properties, "  ")
+		sb.WriteString("}\n")
+	}
+	return sb.String(), nil
+}
+
+// writeBody writes properties as the arguments and nested blocks of a
+// block body.
+func (t pulumiTranslator) writeBody(sb *strings.Builder, properties map[string]any, indent string) {
+	for _, key := range sortedKeys(properties) {
+		name := snakeCase(key)
+		switch v := properties[key].(type) {
+		case map[string]any:
+			if expr, ok := t.function(v); ok {
+				fmt.Fprintf(sb, "%s%s = %s\n", indent, name, expr)
+			} else if slices.Contains(pulumiObjectProperties, name) {
+				fmt.Fprintf(sb, "%s%s = %s\n", indent, name, t.expression(v))
+			} else {
+				fmt.Fprintf(sb, "%s%s {\n", indent, name)
+				t.writeBody(sb, v, indent+"  ")
+				fmt.Fprintf(sb, "%s}\n", indent)
+			}
+		case []any:
+			if blocks, ok := objectList(v); ok && len(blocks) > 0 && !slices.Contains(pulumiObjectProperties, name) {
+				for _, block := range blocks {
+					fmt.Fprintf(sb, "%s%s {\n", indent, name)
+					t.writeBody(sb, block, indent+"  ")
+					fmt.Fprintf(sb, "%s}\n", indent)
+				}
+			} else {
+				fmt.Fprintf(sb, "%s%s = %s\n", indent, name, t.expression(v))
+			}
+		default:
+			fmt.Fprintf(sb, "%s%s = %s\n", indent, name, t.expression(v))
+		}
+	}
+}
+
+// function renders a Pulumi built-in function call such as fn::toJSON. It
+// reports false if v is not one.
+func (t pulumiTranslator) function(v map[string]any) (string, bool) {
+	if len(v) != 1 {
+		return "", false
+	}
+	for key, arg := range v {
+		switch {
+		case key == "fn::toJSON":
+			return "jsonencode(" + t.expression(arg) + ")", true
+		case strings.HasPrefix(key, "fn::"):
+			// Other functions, such as invokes, have no static value.
+			return fmt.Sprintf("null # %s", key), true
+		}
+	}
+	return "", false
+}
+
+// expression renders a Pulumi value as an HCL expression. Object keys are
+// kept as written.
+func (t pulumiTranslator) expression(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return t.stringExpression(v)
+	case bool, int, float64:
+		return fmt.Sprint(v)
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = t.expression(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]any:
+		if expr, ok := t.function(v); ok && !strings.HasPrefix(expr, "null") {
+			return expr
+		}
+		items := make([]string, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			items = append(items, strconv.Quote(key)+" = "+t.expression(v[key]))
+		}
+		return "{ " + strings.Join(items, ", ") + " }"
+	default:
+		return strconv.Quote(fmt.Sprint(v))
+	}
+}
+
+// stringExpression renders a Pulumi string, turning references to other
+// resources into Terraform references. A string that is only a reference
+// becomes a bare traversal.
+func (t pulumiTranslator) stringExpression(s string) string {
+	if m := pulumiInterpolation.FindStringSubmatch(s); m != nil && m[0] == s {
+		if ref, ok := t.reference(m[1], m[2]); ok {
+			return ref
+		}
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, loc := range pulumiInterpolation.FindAllStringSubmatchIndex(s, -1) {
+		sb.WriteString(templateLiteral(s[last:loc[0]]))
+		var property string
+		if loc[4] >= 0 {
+			property = s[loc[4]:loc[5]]
+		}
+		if ref, ok := t.reference(s[loc[2]:loc[3]], property); ok {
+			sb.WriteString("${" + ref + "}")
+		} else {
+			sb.WriteString(templateLiteral(s[loc[0]:loc[1]]))
+		}
+		last = loc[1]
+	}
+	sb.WriteString(templateLiteral(s[last:]))
+	return `"` + sb.String() + `"`
+}
+
+// templateLiteral escapes s for use inside an HCL quoted template,
+// including its interpolation and directive sequences.
+func templateLiteral(s string) string {
+	quoted := strconv.Quote(s)
+	quoted = strings.ReplaceAll(quoted[1:len(quoted)-1], "${", "$${")
+	return strings.ReplaceAll(quoted, "%{", "%%{")
+}
+
+// reference returns the Terraform reference for property of the named
+// resource, its id when property is empty.
+func (t pulumiTranslator) reference(name, property string) (string, bool) {
+	address, ok := t.addresses[name]
+	if !ok {
+		return "", false
+	}
+	if property == "" {
+		property = "id"
+	}
+	parts := strings.Split(property, ".")
+	for i, part := range parts {
+		parts[i] = snakeCase(part)
+	}
+	return address + "." + strings.Join(parts, "."), true
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Idle limiters are dropped so the map does not grow with every client ever seen.
+const (
+	rateLimitCleanupInterval = 5 * time.Minute
+	rateLimitIdleTimeout     = 10 * time.Minute
+)
+
+// ipLimiter is the token bucket for one client IP.
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// ipRateLimiter keeps a token bucket per client IP address.
+type ipRateLimiter struct {
+	mu       sync.Mutex
+	limiters map[string]*ipLimiter
+	limit    rate.Limit
+	burst    int
+}
+
+// newIPRateLimiter creates a rate limiter allowing rps requests per second
+// per IP, with bursts of up to burst requests.
+func newIPRateLimiter(rps float64, burst int) *ipRateLimiter {
+	return &ipRateLimiter{
+		limiters: make(map[string]*ipLimiter),
+		limit:    rate.Limit(rps),
+		burst:    burst,
+	}
+}
+
+// get returns the limiter for ip, creating it on first use.
+func (l *ipRateLimiter) get(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.limiters[ip]
+	if !ok {
+		entry = &ipLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[ip] = entry
+	}
+	entry.lastSeen = time.Now()
+	return entry.limiter
+}
+
+// setLimit changes the rate and burst allowed per IP, including for the
+// clients already seen.
+func (l *ipRateLimiter) setLimit(rps float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = rate.Limit(rps)
+	l.burst = burst
+	for _, entry := range l.limiters {
+		entry.limiter.SetLimit(l.limit)
+		entry.limiter.SetBurst(l.burst)
+	}
+}
+
+// allow reports whether ip may make another request now, taking a token
+// from its bucket if so. It is for traffic the middleware does not see, such
+// as each message on a WebSocket connection.
+func (l *ipRateLimiter) allow(ip string) bool {
+	return l.get(ip).Allow()
+}
+
+// cleanupLoop periodically removes limiters that have been idle for longer
+// than rateLimitIdleTimeout. It never returns.
+func (l *ipRateLimiter) cleanupLoop() {
+	ticker := time.NewTicker(rateLimitCleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		l.mu.Lock()
+		for ip, entry := range l.limiters {
+			if time.Since(entry.lastSeen) > rateLimitIdleTimeout {
+				delete(l.limiters, ip)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// middleware rejects requests with 429 once the client's bucket is empty.
+// Health checks are exempt so probes never fail because of client traffic.
+func (l *ipRateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/health") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		reservation := l.get(clientIP(r)).Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			writeJSONError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the IP address of the client that sent r.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// regexRule is a local check that flags source lines matching a pattern.
+type regexRule struct {
+	RuleID      string
+	Severity    string
+	Description string
+	re          *regexp.Regexp
+}
+
+// defaultRegexRules catch common misconfigurations without a Bedrock call.
+var defaultRegexRules = []regexRule{
+	{"LOCAL.1", SeverityHigh, "S3 bucket ACL grants public access", regexp.MustCompile(`(?m)^[ \t]*acl[ \t]*=[ \t]*"(public-read|public-read-write|authenticated-read)"`)},
+	{"LOCAL.2", SeverityMedium, "CIDR block is open to the whole internet", regexp.MustCompile(`(?m)^[ \t]*(cidr_blocks|ipv6_cidr_blocks)[ \t]*=[ \t]*\[[^\]]*"(0\.0\.0\.0/0|::/0)"`)},
+	{"LOCAL.3", SeverityCritical, "Database is publicly accessible", regexp.MustCompile(`(?m)^[ \t]*publicly_accessible[ \t]*=[ \t]*true`)},
+	{"LOCAL.4", SeverityHigh, "Encryption at rest is explicitly disabled", regexp.MustCompile(`(?m)^[ \t]*(encrypted|storage_encrypted)[ \t]*=[ \t]*false`)},
+	{"LOCAL.5", SeverityMedium, "Listener accepts plaintext HTTP", regexp.MustCompile(`(?m)^[ \t]*protocol[ \t]*=[ \t]*"HTTP"`)},
+}
+
+// regexAnalyzer runs regexRules against the file source locally.
+type regexAnalyzer struct {
+	rules []regexRule
+}
+
+// newRegexAnalyzer returns an analyzer using the built-in regex rules.
+func newRegexAnalyzer() *regexAnalyzer {
+	return &regexAnalyzer{rules: defaultRegexRules}
+}
+
+// Analyze implements Analyzer.
+func (a *regexAnalyzer) Analyze(_ context.Context, tf TerraformFile) ([]Finding, error) {
+	var findings []Finding
+	for _, rule := range a.rules {
+		for _, loc := range rule.re.FindAllStringIndex(tf.Source, -1) {
+			line := 1 + strings.Count(tf.Source[:loc[0]], "\n")
+
+			f := Finding{Severity: rule.Severity, RuleID: rule.RuleID, Description: rule.Description}
+			if block, ok := tf.blockAt(line); ok {
+				if block.Kind == "resource" {
+					f.ResourceType = block.Type
+				}
+				f.Description += " in " + block.String()
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings, nil
+}
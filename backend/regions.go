@@ -0,0 +1,198 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	"github.com/aws/smithy-go"
+)
+
+// bedrockRegionHeader reports the region whose Bedrock agent served the analysis.
+const bedrockRegionHeader = "X-Bedrock-Region"
+
+// Region health scoring. Each invocation moves a region's error rate
+// regionErrorWeight of the way towards 0 or 1, and the rate decays by half
+// every regionHealthHalfLife so a degraded region is retried eventually.
+const (
+	regionErrorWeight     = 0.3
+	regionHealthHalfLife  = time.Minute
+	regionDegradedAtScore = 0.5
+)
+
+// BedrockInvoker invokes a Bedrock agent and returns its response, calling
+// onChunk, if non-nil, with each chunk as it arrives. The agent runtime
+// client implements it, and bedrocktest.Mock stands in for it in tests.
+type BedrockInvoker interface {
+	InvokeAgent(ctx context.Context, input *bedrockagentruntime.InvokeAgentInput, onChunk func([]byte)) (string, error)
+}
+
+// bedrockRegion is the agent runtime client for one region and its health.
+type bedrockRegion struct {
+	Name   string
+	Client BedrockInvoker
+
+	mu        sync.Mutex
+	errorRate float64
+	updated   time.Time
+}
+
+// score returns the region's current error rate, between 0 (healthy) and 1.
+func (r *bedrockRegion) score(now time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.decayedLocked(now)
+}
+
+func (r *bedrockRegion) decayedLocked(now time.Time) float64 {
+	if r.updated.IsZero() {
+		return r.errorRate
+	}
+	return r.errorRate * math.Exp2(-now.Sub(r.updated).Seconds()/regionHealthHalfLife.Seconds())
+}
+
+// record updates the region's error rate with the outcome of an invocation.
+func (r *bedrockRegion) record(failed bool) {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	outcome := 0.0
+	if failed {
+		outcome = 1
+	}
+	r.errorRate = r.decayedLocked(now)*(1-regionErrorWeight) + outcome*regionErrorWeight
+	r.updated = now
+}
+
+// regionPool holds the Bedrock regions in their configured order of preference.
+type regionPool struct {
+	regions []*bedrockRegion
+}
+
+// newRegionPool creates a client for each region with newClient.
+func newRegionPool(regions []string, newClient func(region string) BedrockInvoker) *regionPool {
+	p := &regionPool{}
+	for _, name := range regions {
+		p.regions = append(p.regions, &bedrockRegion{Name: name, Client: newClient(name)})
+	}
+	return p
+}
+
+// agentRuntime is the BedrockInvoker calling an agent runtime client.
+type agentRuntime struct {
+	client *bedrockagentruntime.Client
+}
+
+// newAgentRuntime creates an agent runtime client for region from cfg.
+func newAgentRuntime(cfg aws.Config, region string) BedrockInvoker {
+	return agentRuntime{client: bedrockagentruntime.NewFromConfig(cfg, func(o *bedrockagentruntime.Options) {
+		o.Region = region
+	})}
+}
+
+// InvokeAgent implements BedrockInvoker. On a stream error it returns what
+// was read along with the error.
+func (r agentRuntime) InvokeAgent(ctx context.Context, input *bedrockagentruntime.InvokeAgentInput, onChunk func([]byte)) (string, error) {
+	output, err := r.client.InvokeAgent(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	stream := output.GetStream()
+	defer stream.Close()
+
+	var suggestion strings.Builder
+	for event := range stream.Events() {
+		switch v := event.(type) {
+		case *types.ResponseStreamMemberChunk:
+			if v.Value.Bytes != nil {
+				suggestion.Write(v.Value.Bytes)
+				if onChunk != nil {
+					onChunk(v.Value.Bytes)
+				}
+			}
+		case *types.ResponseStreamMemberTrace:
+			// Handle trace events if needed
+			loggerFromContext(ctx).Debug("Trace event", "trace", fmt.Sprintf("%+v", v.Value))
+		}
+	}
+	return suggestion.String(), stream.Err()
+}
+
+// ordered returns the regions to try, healthy ones first in configured
+// order followed by degraded ones from least to most failing.
+func (p *regionPool) ordered() []*bedrockRegion {
+	now := time.Now()
+	scores := make(map[*bedrockRegion]float64, len(p.regions))
+	for _, r := range p.regions {
+		scores[r] = r.score(now)
+	}
+
+	regions := slices.Clone(p.regions)
+	slices.SortStableFunc(regions, func(a, b *bedrockRegion) int {
+		aDegraded, bDegraded := scores[a] >= regionDegradedAtScore, scores[b] >= regionDegradedAtScore
+		switch {
+		case aDegraded && bDegraded:
+			return cmp.Compare(scores[a], scores[b])
+		case aDegraded:
+			return 1
+		case bDegraded:
+			return -1
+		}
+		return 0
+	})
+	return regions
+}
+
+// isRegionalFailure reports whether err indicates a problem with the Bedrock
+// service in a region, as opposed to the request itself, so that the next
+// region may succeed.
+func isRegionalFailure(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ThrottlingException", "ServiceUnavailableException":
+			return true
+		}
+		return apiErr.ErrorFault() == smithy.FaultServer
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// invokeAgentFailover calls invokeAgent in each region in health order until
+// one succeeds or fails for a reason another region would not fix. Once any
+// chunk has been streamed the call is not repeated elsewhere.
+func (api *BedrockConverseAPI) invokeAgentFailover(ctx context.Context, logger *slog.Logger, agent AgentConfig, sessionID, prompt string, onChunk func([]byte), streamed func() bool) (string, string, error) {
+	var errs []error
+	for _, region := range api.Regions.ordered() {
+		suggestion, err := api.invokeAgent(ctx, logger.With("region", region.Name), region, agent, sessionID, prompt, onChunk)
+		failed := err != nil && isRegionalFailure(err)
+		region.record(failed)
+		if failed {
+			bedrockRegionErrors.WithLabelValues(region.Name).Inc()
+		}
+		if !failed || streamed() {
+			return suggestion, region.Name, err
+		}
+
+		errs = append(errs, err)
+		logger.Warn("Bedrock region failed, trying next region", "region", region.Name, "error", err)
+	}
+	return "", "", errors.Join(errs...)
+}
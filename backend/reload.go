@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// reloadableSettings names the ServerConfig fields read as requests are
+// handled, which a reload applies. The others set the server up at startup
+// and only change on restart.
+var reloadableSettings = map[string]bool{
+	"AgentID":                true,
+	"AgentAliasID":           true,
+	"Agents":                 true,
+	"WorkspaceRules":         true,
+	"MaxSuggestions":         true,
+	"AnalysisTimeout":        true,
+	"MaxRetries":             true,
+	"StreamRetryCount":       true,
+	"DefaultDeadlineSeconds": true,
+	"MaxPromptTokens":        true,
+	"ModelID":                true,
+	"InputPricePer1K":        true,
+	"OutputPricePer1K":       true,
+	"BatchMaxFiles":          true,
+	"BatchMaxBytes":          true,
+	"MaxZipSize":             true,
+	"MaxFilesInZip":          true,
+	"RateLimitRPS":           true,
+	"RateLimitBurst":         true,
+	"PromptTemplateFile":     true,
+	"OrgName":                true,
+}
+
+// analysisSettings names the reloadable settings that shape the prompt or
+// the agent answering it but are not part of analysisCacheKey, so changing
+// them invalidates the cached analyses.
+var analysisSettings = map[string]bool{
+	"AgentID":            true,
+	"AgentAliasID":       true,
+	"Agents":             true,
+	"WorkspaceRules":     true,
+	"MaxPromptTokens":    true,
+	"ModelID":            true,
+	"PromptTemplateFile": true,
+	"OrgName":            true,
+}
+
+// ReloadResponse defines the structure of the /admin/reload JSON response.
+type ReloadResponse struct {
+	// Changed lists the settings the reload applied.
+	Changed []string `json:"changed"`
+	// RestartRequired lists the settings that changed but only take effect
+	// on restart.
+	RestartRequired []string `json:"restart_required,omitempty"`
+	// CachePurged is the number of cached analyses dropped because the
+	// prompt or agent changed.
+	CachePurged int `json:"cache_purged,omitempty"`
+}
+
+// Config returns the current server configuration. It must not be modified.
+func (api *BedrockConverseAPI) Config() *ServerConfig {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.cfg
+}
+
+// Prompt returns the current analysis prompt template.
+func (api *BedrockConverseAPI) Prompt() *PromptTemplate {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.prompt
+}
+
+// reloadConfig re-reads the configuration from the environment and the
+// prompt template file, then applies the reloadable settings that changed.
+// A configuration that fails to load leaves the current one in place.
+func (api *BedrockConverseAPI) reloadConfig(ctx context.Context) (ReloadResponse, error) {
+	next, err := loadConfig()
+	if err != nil {
+		return ReloadResponse{}, err
+	}
+	prompt, err := loadPromptTemplate(next.PromptTemplateFile)
+	if err != nil {
+		return ReloadResponse{}, err
+	}
+
+	logger := loggerFromContext(ctx)
+	resp := ReloadResponse{Changed: []string{}}
+
+	api.mu.Lock()
+	stale := api.prompt.text != prompt.text
+	applied := *api.cfg
+	current := reflect.ValueOf(&applied).Elem()
+	loaded := reflect.ValueOf(next).Elem()
+	for i := range current.NumField() {
+		key := current.Type().Field(i).Name
+		old, value := current.Field(i), loaded.Field(i)
+		if reflect.DeepEqual(old.Interface(), value.Interface()) {
+			continue
+		}
+		// Startup settings include secrets, so their values are not logged.
+		if !reloadableSettings[key] {
+			logger.Warn("Configuration change needs a restart", "key", key)
+			resp.RestartRequired = append(resp.RestartRequired, key)
+			continue
+		}
+		logger.Info("Configuration changed", "key", key, "old", old.Interface(), "new", value.Interface())
+		old.Set(value)
+		resp.Changed = append(resp.Changed, key)
+		stale = stale || analysisSettings[key]
+	}
+	api.cfg = &applied
+	api.prompt = prompt
+	api.mu.Unlock()
+
+	if stale {
+		resp.CachePurged = api.Cache.Len()
+		api.Cache.Purge()
+		logger.Info("Purged analysis cache after the prompt or agent changed", "entries", resp.CachePurged)
+	}
+
+	if api.Limiter != nil {
+		api.Limiter.setLimit(applied.RateLimitRPS, applied.RateLimitBurst)
+	}
+	logger.Info("Configuration reloaded", "changed", len(resp.Changed), "restart_required", len(resp.RestartRequired))
+	return resp, nil
+}
+
+// adminReloadHandler handles the /admin/reload endpoint, which reloads the
+// configuration like SIGHUP does. It requires an admin API key.
+func (api *BedrockConverseAPI) adminReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	if len(api.Admin.digests) == 0 {
+		writeJSONError(w, http.StatusForbidden, "Admin endpoints are disabled: ADMIN_API_KEYS is not set")
+		return
+	}
+	id, ok := api.Admin.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSONError(w, http.StatusUnauthorized, "Missing or invalid admin API key")
+		return
+	}
+
+	logger := loggerFromContext(r.Context()).With("key_id", id)
+	resp, err := api.reloadConfig(context.WithValue(r.Context(), loggerKey{}, logger))
+	if err != nil {
+		logger.Error("Failed to reload configuration", "error", err)
+		writeJSONError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Failed to reload configuration: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Remediation efforts, from the quickest fix to the most involved.
+const (
+	// EffortTrivial is a one-line change, such as adding a tag or flipping
+	// a flag.
+	EffortTrivial = "trivial"
+	// EffortModerate changes a resource's configuration or adds related
+	// resources, such as a KMS key or a log bucket.
+	EffortModerate = "moderate"
+	// EffortSignificant needs architectural changes, such as moving
+	// resources into a VPC or across availability zones.
+	EffortSignificant = "significant"
+)
+
+// sortByRemediationEffort is the sort_by value that orders findings from
+// the quickest fix to the most involved.
+const sortByRemediationEffort = "remediation_effort"
+
+// remediationEffortManifest maps rule IDs to the effort of fixing them.
+// Rules whose fix depends on the code are left out and rated by the agent.
+//
+//go:embed remediation/effort.json
+var remediationEffortManifest []byte
+
+// remediationEfforts holds the decoded remediation effort manifest. It is
+// loaded at startup, before any analysis runs.
+var remediationEfforts map[string]string
+
+// loadRemediationEfforts decodes the embedded remediation effort manifest,
+// keyed by rule ID.
+func loadRemediationEfforts() (map[string]string, error) {
+	var efforts map[string]string
+	if err := json.Unmarshal(remediationEffortManifest, &efforts); err != nil {
+		return nil, fmt.Errorf("failed to decode remediation efforts: %w", err)
+	}
+	for id, effort := range efforts {
+		if normalizeEffort(effort) != effort {
+			return nil, fmt.Errorf("invalid remediation effort %q for rule %s", effort, id)
+		}
+	}
+	return efforts, nil
+}
+
+// normalizeEffort lower-cases a remediation effort and drops values outside
+// the known levels.
+func normalizeEffort(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case EffortTrivial, EffortModerate, EffortSignificant:
+		return s
+	}
+	return ""
+}
+
+// validateRemediationEffort checks an effort given to filter findings by.
+func validateRemediationEffort(s string) error {
+	if normalizeEffort(s) != s {
+		return fmt.Errorf("remediation_effort must be %s, %s or %s, got %q", EffortTrivial, EffortModerate, EffortSignificant, s)
+	}
+	return nil
+}
+
+// withRemediationEffort rates each finding's remediation effort: by its
+// rule in the manifest, else as the agent rated it, else as moderate.
+func withRemediationEffort(findings []Finding) []Finding {
+	for i, f := range findings {
+		if effort, ok := remediationEfforts[f.RuleID]; ok {
+			findings[i].RemediationEffort = effort
+		} else if findings[i].RemediationEffort = normalizeEffort(f.RemediationEffort); findings[i].RemediationEffort == "" {
+			findings[i].RemediationEffort = EffortModerate
+		}
+	}
+	return findings
+}
+
+// effortRank orders remediation efforts from the quickest fix.
+func effortRank(effort string) int {
+	switch effort {
+	case EffortTrivial:
+		return 0
+	case EffortModerate:
+		return 1
+	}
+	return 2
+}
+
+// validateSortBy checks a requested sort_by, where "" keeps the order the
+// findings were reported in.
+func validateSortBy(sortBy string) error {
+	if sortBy != "" && sortBy != sortByRemediationEffort {
+		return fmt.Errorf("sort_by must be %q, got %q", sortByRemediationEffort, sortBy)
+	}
+	return nil
+}
+
+// sortFindings returns findings ordered by sortBy. The slice is copied, as
+// cached responses share it.
+func sortFindings(findings []Finding, sortBy string) []Finding {
+	if sortBy != sortByRemediationEffort {
+		return findings
+	}
+	sorted := slices.Clone(findings)
+	slices.SortStableFunc(sorted, func(a, b Finding) int {
+		return effortRank(a.RemediationEffort) - effortRank(b.RemediationEffort)
+	})
+	return sorted
+}
+
+// filterByEffort returns the findings with the given remediation effort.
+// Findings stored before efforts were rated are rated first.
+func filterByEffort(findings []Finding, effort string) []Finding {
+	return slices.DeleteFunc(withRemediationEffort(findings), func(f Finding) bool {
+		return f.RemediationEffort != effort
+	})
+}
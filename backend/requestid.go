@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+)
+
+// requestIDHeader carries the correlation ID shared with the VS Code extension's telemetry.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern limits client-supplied request IDs to characters that are
+// safe to write to logs and echo in a header.
+var requestIDPattern = regexp.MustCompile(`^[0-9a-zA-Z._:-]{1,128}$`)
+
+// requestIDKey is the context key under which the request ID is stored.
+type requestIDKey struct{}
+
+// requestIDFromContext returns the ID assigned to the current request.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDMiddleware adopts the client's X-Request-ID, or generates one when
+// it is missing or malformed, stores it in the request context and echoes it
+// in the response.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			generated, err := newUUID()
+			if err != nil {
+				slog.Error("Failed to generate request ID", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			id = generated
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// retryCountHeader reports how many times the agent invocation was retried.
+const retryCountHeader = "Retry-Count"
+
+// Backoff bounds for retried agent invocations.
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
+
+// isRetryableAgentError reports whether err is a transient failure worth
+// retrying: throttling, service unavailability, or a network timeout.
+// Client errors and our own analysis timeout are never retried.
+func isRetryableAgentError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ThrottlingException", "ServiceUnavailableException":
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// backoffDelay returns a full-jitter exponential backoff delay for the given
+// zero-based retry attempt.
+func backoffDelay(attempt int) time.Duration {
+	ceiling := retryBaseDelay << attempt
+	if ceiling <= 0 || ceiling > retryMaxDelay {
+		ceiling = retryMaxDelay
+	}
+	return rand.N(ceiling)
+}
+
+// agentResult is the outcome of a Bedrock agent invocation.
+type agentResult struct {
+	Suggestion string
+	// Retries is the number of retries performed.
+	Retries int
+	// Region is the Bedrock region that produced the response.
+	Region string
+}
+
+// errOfflineMode is returned instead of invoking Bedrock in offline mode.
+var errOfflineMode = errors.New("bedrock is disabled in offline mode")
+
+// analysisTimeoutKey marks a context already bounded by ANALYSIS_TIMEOUT_SECONDS.
+type analysisTimeoutKey struct{}
+
+// withAnalysisTimeout bounds ctx by ANALYSIS_TIMEOUT_SECONDS, unless an
+// enclosing call already has, so the retries, failovers and continuations of
+// one analysis share its deadline rather than each getting the full timeout.
+func (api *BedrockConverseAPI) withAnalysisTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx.Value(analysisTimeoutKey{}) != nil {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithTimeout(ctx, api.Config().AnalysisTimeout)
+	return context.WithValue(ctx, analysisTimeoutKey{}, true), cancel
+}
+
+// invokeAgentWithRetry invokes the agent, failing over between regions and
+// retrying transient failures up to the configured number of times, all
+// within one ANALYSIS_TIMEOUT_SECONDS deadline. Once any chunk has been
+// passed to onChunk the call is not retried, since the client has already
+// seen partial output. While the circuit breaker is open it fails
+// immediately with errCircuitOpen, and in offline mode with errOfflineMode.
+func (api *BedrockConverseAPI) invokeAgentWithRetry(ctx context.Context, logger *slog.Logger, agent AgentConfig, sessionID, prompt string, onChunk func([]byte)) (result agentResult, err error) {
+	if api.Config().OfflineMode {
+		return agentResult{}, errOfflineMode
+	}
+	if !api.Breaker.allow() {
+		logger.Warn("Bedrock circuit breaker is open, rejecting agent invocation")
+		return agentResult{}, errCircuitOpen
+	}
+	ctx, cancel := api.withAnalysisTimeout(ctx)
+	defer cancel()
+	defer func() {
+		// The agent was cut off by the analysis deadline, not by a failure.
+		if err != nil && errors.Is(context.Cause(ctx), errAnalysisDeadline) {
+			err = errAnalysisDeadline
+		}
+		api.Breaker.record(err)
+	}()
+
+	streamed := false
+	relay := onChunk
+	if onChunk != nil {
+		relay = func(chunk []byte) {
+			streamed = true
+			onChunk(chunk)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		suggestion, region, err := api.invokeAgentFailover(ctx, logger, agent, sessionID, prompt, relay, func() bool { return streamed })
+		result := agentResult{Suggestion: suggestion, Retries: attempt, Region: region}
+		if err == nil || streamed || attempt >= api.Config().MaxRetries || !isRetryableAgentError(err) {
+			return result, err
+		}
+
+		delay := backoffDelay(attempt)
+		logger.Warn("Retrying Bedrock agent invocation",
+			"attempt", attempt+1,
+			"max_retries", api.Config().MaxRetries,
+			"delay_ms", delay.Milliseconds(),
+			"error", err,
+		)
+
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// continuationTailLength is how much of a cut-off response is quoted back
+// to the agent when asking it to continue.
+const continuationTailLength = 500
+
+// completeFindingsJSON reports whether suggestion holds a complete JSON
+// array, as an analysis response that was not cut off does.
+func completeFindingsJSON(suggestion string) bool {
+	raw, ok := extractJSON(suggestion, '[', ']')
+	return ok && json.Valid(raw)
+}
+
+// unclosedFindingsJSON reports whether suggestion opens a JSON array that
+// it never completes, as a response cut off mid-answer does.
+func unclosedFindingsJSON(suggestion string) bool {
+	return strings.Contains(suggestion, "[") && !completeFindingsJSON(suggestion)
+}
+
+// buildContinuationPrompt asks the agent to finish a response that ended
+// with partial.
+func buildContinuationPrompt(partial string) string {
+	tail := partial
+	if len(tail) > continuationTailLength {
+		tail = tail[len(tail)-continuationTailLength:]
+	}
+	return "Your previous response was cut off before the JSON array was complete. It ended with:\n" +
+		tail + "\n\nContinue from where you left off. Write only the rest of the JSON array, without repeating what you already wrote."
+}
+
+// invokeAnalysisAgent invokes the agent for an analysis prompt like
+// invokeAgentWithRetry. When the response stream fails, or ends inside the
+// JSON array of findings, before the array is complete, the agent is asked
+// in the same session to continue from where it left off, up to
+// STREAM_RETRY_COUNT times. A response that becomes complete is returned
+// without the error of the stream that cut it off.
+func (api *BedrockConverseAPI) invokeAnalysisAgent(ctx context.Context, logger *slog.Logger, agent AgentConfig, sessionID, prompt string, onChunk func([]byte)) (agentResult, error) {
+	result, err := api.invokeAgentWithRetry(ctx, logger, agent, sessionID, prompt, onChunk)
+	for attempt := 1; attempt <= api.Config().StreamRetryCount; attempt++ {
+		if result.Suggestion == "" || ctx.Err() != nil || completeFindingsJSON(result.Suggestion) {
+			break
+		}
+		if err == nil && !unclosedFindingsJSON(result.Suggestion) {
+			// The agent answered without findings JSON; continuing would not add any.
+			break
+		}
+		logger.Warn("Agent response stream ended before the JSON was complete, asking the agent to continue",
+			"stream_retry", attempt,
+			"stream_retry_count", api.Config().StreamRetryCount,
+			"partial_length", len(result.Suggestion),
+			"error", err,
+		)
+		partial := result.Suggestion
+		next, nextErr := api.invokeAgentWithRetry(ctx, logger, agent, sessionID, buildContinuationPrompt(partial), onChunk)
+		result.Retries += next.Retries + 1
+		if next.Region != "" {
+			result.Region = next.Region
+		}
+		switch {
+		case completeFindingsJSON(partial + next.Suggestion):
+			result.Suggestion = partial + next.Suggestion
+		case completeFindingsJSON(next.Suggestion):
+			// The agent answered again in full rather than continuing.
+			result.Suggestion = next.Suggestion
+		default:
+			result.Suggestion = partial + next.Suggestion
+		}
+		err = nextErr
+		if completeFindingsJSON(result.Suggestion) {
+			err = nil
+			logger.Info("Agent response completed after stream retry", "stream_retry", attempt, "length", len(result.Suggestion))
+		}
+	}
+	return result, err
+}
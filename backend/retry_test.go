@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestCompleteFindingsJSON(t *testing.T) {
+	tests := []struct {
+		name, suggestion string
+		want             bool
+	}{
+		{"empty array", "[]", true},
+		{"findings", `[{"severity":"HIGH","rule_id":"S3.1","description":"public"}]`, true},
+		{"fenced with prose", "Here are the findings:\n```json\n[{\"description\":\"x\"}]\n```\nLet me know.", true},
+		{"cut off inside a finding", `[{"severity":"HIGH","description":"pub`, false},
+		{"cut off between findings", `[{"description":"a"},`, false},
+		{"closing bracket inside a string", `[{"description":"see [docs]`, false},
+		{"no array", "The code looks compliant.", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := completeFindingsJSON(tt.suggestion); got != tt.want {
+				t.Errorf("completeFindingsJSON(%q) = %t, want %t", tt.suggestion, got, tt.want)
+			}
+		})
+	}
+}
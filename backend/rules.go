@@ -0,0 +1,159 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ruleManifests holds the baseline rule set for each framework, one
+// rules/<framework>.json file per framework, so /rules works offline.
+//
+//go:embed rules/*.json
+var ruleManifests embed.FS
+
+// Paging limits for /rules.
+const (
+	defaultRulesLimit = 50
+	maxRulesLimit     = 500
+)
+
+// Rule is a compliance control enforced for a framework.
+type Rule struct {
+	RuleID        string   `json:"rule_id"`
+	Title         string   `json:"title"`
+	Severity      string   `json:"severity"`
+	ResourceTypes []string `json:"resource_types"`
+	// ControlIDs are the framework controls the rule maps to, copied to
+	// findings the agent reports without them.
+	ControlIDs []string `json:"control_ids,omitempty"`
+	// Level is the CIS Benchmark profile the rule belongs to, for CIS
+	// frameworks.
+	Level string `json:"level,omitempty"`
+}
+
+// RulesResponse defines the structure of the /rules JSON response.
+type RulesResponse struct {
+	Framework string `json:"framework"`
+	Rules     []Rule `json:"rules"`
+	Total     int    `json:"total"`
+	Limit     int    `json:"limit"`
+	Offset    int    `json:"offset"`
+}
+
+// loadRuleManifests decodes the embedded rule manifests, keyed by framework ID.
+func loadRuleManifests() (map[string][]Rule, error) {
+	files, err := ruleManifests.ReadDir("rules")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rule manifests: %w", err)
+	}
+
+	manifests := make(map[string][]Rule, len(files))
+	for _, f := range files {
+		data, err := ruleManifests.ReadFile(path.Join("rules", f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rule manifest %s: %w", f.Name(), err)
+		}
+
+		var rules []Rule
+		if err := json.Unmarshal(data, &rules); err != nil {
+			return nil, fmt.Errorf("failed to decode rule manifest %s: %w", f.Name(), err)
+		}
+		manifests[strings.TrimSuffix(f.Name(), ".json")] = rules
+	}
+	return manifests, nil
+}
+
+// annotateFindings fills in the control IDs and benchmark level of findings
+// the agent reported without them from the framework's rule manifest, and
+// tags NIST SP 800-53 and CIS AWS findings with their controls.
+func (api *BedrockConverseAPI) annotateFindings(frameworkID string, findings []Finding) {
+	for i := range findings {
+		f := &findings[i]
+		for _, rule := range api.Rules[frameworkID] {
+			if rule.RuleID != f.RuleID {
+				continue
+			}
+			if len(f.ControlIDs) == 0 {
+				f.ControlIDs = rule.ControlIDs
+			}
+			if f.BenchmarkLevel == "" {
+				f.BenchmarkLevel = rule.Level
+			}
+			break
+		}
+		switch frameworkID {
+		case "nist-800-53":
+			tagNISTControl(f)
+		case "cis-aws":
+			tagCISControl(f)
+		}
+	}
+}
+
+// rulesHandler handles the /rules endpoint. Rules can be filtered with the
+// resource_type and severity query parameters and paged with limit and offset.
+func (api *BedrockConverseAPI) rulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	fw, err := lookupFramework(query.Get("framework"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	manifest, ok := api.Rules[fw.ID]
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("No rule manifest is available for framework %q", fw.ID))
+		return
+	}
+
+	limit, err := queryInt(query.Get("limit"), defaultRulesLimit)
+	if err != nil || limit < 1 || limit > maxRulesLimit {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxRulesLimit))
+		return
+	}
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		writeJSONError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+		return
+	}
+
+	resourceType := query.Get("resource_type")
+	severity := strings.ToUpper(query.Get("severity"))
+	matched := []Rule{}
+	for _, rule := range manifest {
+		if resourceType != "" && !slices.Contains(rule.ResourceTypes, resourceType) {
+			continue
+		}
+		if severity != "" && rule.Severity != severity {
+			continue
+		}
+		matched = append(matched, rule)
+	}
+
+	page := matched[min(offset, len(matched)):min(offset+limit, len(matched))]
+	writeJSON(w, http.StatusOK, RulesResponse{
+		Framework: fw.ID,
+		Rules:     page,
+		Total:     len(matched),
+		Limit:     limit,
+		Offset:    offset,
+	})
+}
+
+// queryInt parses an integer query parameter, or returns def when it is empty.
+func queryInt(v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// categoryCrossAccountAccess is the category of findings for resource
+// policies that grant access outside the owning account.
+const categoryCrossAccountAccess = "cross_account_access"
+
+// principalAccountPattern extracts the account ID from an AWS principal,
+// given as an account ID or an IAM ARN such as arn:aws:iam::123456789012:root.
+var principalAccountPattern = regexp.MustCompile(`^(?:arn:aws[a-z-]*:(?:iam|sts)::)?(\d{12})(?::|$)`)
+
+// policyPrincipal is the Principal element of a policy statement, mapping
+// principal types such as AWS or Service to identifiers. The bare "*" is
+// read as {"AWS": "*"}.
+type policyPrincipal map[string]stringList
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *policyPrincipal) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*p = policyPrincipal{"AWS": {s}}
+		return nil
+	}
+	return json.Unmarshal(data, (*map[string]stringList)(p))
+}
+
+// public reports whether the principal is anyone, "*".
+func (p policyPrincipal) public() bool {
+	return slices.Contains(p["AWS"], "*") || slices.Contains(p["*"], "*")
+}
+
+// accounts returns the account IDs named by the AWS principals, in order.
+// Principals given by reference, such as the caller's account, are skipped.
+func (p policyPrincipal) accounts() []string {
+	var accounts []string
+	for _, principal := range p["AWS"] {
+		if m := principalAccountPattern.FindStringSubmatch(principal); m != nil && !slices.Contains(accounts, m[1]) {
+			accounts = append(accounts, m[1])
+		}
+	}
+	return accounts
+}
+
+// bucketPolicyStatements returns the statements of a bucket policy, given
+// literally, with jsonencode or by an aws_iam_policy_document data source.
+// Values of a jsonencode object that reference other blocks, such as the
+// bucket ARN, are read as empty strings.
+func bucketPolicyStatements(tf *TerraformFile, b TerraformBlock) ([]policyStatement, bool) {
+	if statements, ok := policyStatementsOf(tf, b); ok {
+		return statements, true
+	}
+	attr, ok := b.Body.Attributes["policy"]
+	if !ok {
+		return nil, false
+	}
+	call, ok := attr.Expr.(*hclsyntax.FunctionCallExpr)
+	if !ok || call.Name != "jsonencode" || len(call.Args) != 1 {
+		return nil, false
+	}
+	ctx := &hcl.EvalContext{Variables: map[string]cty.Value{}}
+	for _, traversal := range call.Args[0].Variables() {
+		ctx.Variables[traversal.RootName()] = cty.DynamicVal
+	}
+	v, diags := call.Args[0].Value(ctx)
+	if diags.HasErrors() {
+		return nil, false
+	}
+	data, err := json.Marshal(knownValue(v))
+	if err != nil {
+		return nil, false
+	}
+	var doc policyDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, false
+	}
+	return doc.Statement, true
+}
+
+// knownValue converts v to the Go value encoding/json would marshal for it.
+// Unknown and null values become nil.
+func knownValue(v cty.Value) any {
+	if !v.IsKnown() || v.IsNull() {
+		return nil
+	}
+	t := v.Type()
+	switch {
+	case t == cty.String:
+		return v.AsString()
+	case t == cty.Number:
+		f, _ := v.AsBigFloat().Float64()
+		return f
+	case t == cty.Bool:
+		return v.True()
+	case t.IsObjectType() || t.IsMapType():
+		m := map[string]any{}
+		for it := v.ElementIterator(); it.Next(); {
+			k, elem := it.Element()
+			m[k.AsString()] = knownValue(elem)
+		}
+		return m
+	case v.CanIterateElements():
+		var list []any
+		for it := v.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			list = append(list, knownValue(elem))
+		}
+		return list
+	}
+	return nil
+}
+
+// s3BucketPolicyAnalyzer flags S3 bucket policies that allow access to
+// anyone or to other AWS accounts.
+type s3BucketPolicyAnalyzer struct{}
+
+// Analyze implements Analyzer. Accounts given by reference, such as
+// data.aws_caller_identity.current.account_id, are taken to be the owner's.
+func (s3BucketPolicyAnalyzer) Analyze(_ context.Context, tf TerraformFile) ([]Finding, error) {
+	var findings []Finding
+	for _, b := range tf.Resources {
+		if b.Body == nil || (b.Type != "aws_s3_bucket_policy" && b.Type != "aws_s3_bucket") {
+			continue
+		}
+		statements, ok := bucketPolicyStatements(&tf, b)
+		if !ok {
+			continue
+		}
+
+		var accounts []string
+		public, conditional := false, true
+		for _, st := range statements {
+			if st.Effect != "Allow" {
+				continue
+			}
+			if st.Principal.public() {
+				public = true
+				conditional = conditional && len(st.Condition) > 0
+			}
+			for _, account := range st.Principal.accounts() {
+				if !slices.Contains(accounts, account) {
+					accounts = append(accounts, account)
+				}
+			}
+		}
+
+		if public {
+			f := localFinding(b, "S3.6", SeverityCritical,
+				fmt.Sprintf("S3 bucket allows access from any AWS account: %s on line %d grants Principal \"*\"", b.Address(), b.Line), "")
+			if conditional {
+				f.Severity = SeverityHigh
+				f.Description += ", restricted only by conditions; check they limit access to your organization or VPC endpoints"
+			}
+			f.Category = categoryCrossAccountAccess
+			findings = append(findings, f)
+		}
+		if len(accounts) > 0 {
+			noun := "account"
+			if len(accounts) > 1 {
+				noun = "accounts"
+			}
+			f := localFinding(b, "S3.6", SeverityHigh,
+				fmt.Sprintf("S3 bucket allows cross-account access from %s %s. %s on line %d names them as principals; confirm each account is trusted", noun, strings.Join(accounts, ", "), b.Address(), b.Line), "")
+			f.Category = categoryCrossAccountAccess
+			findings = append(findings, f)
+		}
+	}
+	return findings, nil
+}
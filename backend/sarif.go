@@ -0,0 +1,236 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// SARIF 2.1.0 document identification.
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// SarifRequest defines the structure of the incoming /analyze/sarif JSON request.
+type SarifRequest struct {
+	AnalyzeRequest
+	// Path is the file the results are reported against, relative to the
+	// workspace root. It defaults to main.tf.
+	Path string `json:"path,omitempty"`
+}
+
+// SarifLog is a SARIF 2.1.0 document holding a single run.
+type SarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []SarifRun `json:"runs"`
+}
+
+// SarifRun is the output of one analysis run.
+type SarifRun struct {
+	Tool    SarifTool     `json:"tool"`
+	Results []SarifResult `json:"results"`
+}
+
+// SarifTool describes the analyzer that produced a run.
+type SarifTool struct {
+	Driver SarifDriver `json:"driver"`
+}
+
+// SarifDriver names the tool and the rules its results refer to.
+type SarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []SarifRule `json:"rules"`
+}
+
+// SarifRule describes a rule referenced by results.
+type SarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription SarifMessage `json:"shortDescription"`
+}
+
+// SarifMessage is a plain-text SARIF message.
+type SarifMessage struct {
+	Text string `json:"text"`
+}
+
+// SarifResult is a single finding.
+type SarifResult struct {
+	RuleID    string          `json:"ruleId,omitempty"`
+	Level     string          `json:"level"`
+	Message   SarifMessage    `json:"message"`
+	Locations []SarifLocation `json:"locations"`
+}
+
+// SarifLocation is where a result was found.
+type SarifLocation struct {
+	PhysicalLocation SarifPhysicalLocation `json:"physicalLocation"`
+}
+
+// SarifPhysicalLocation is a region of a file.
+type SarifPhysicalLocation struct {
+	ArtifactLocation SarifArtifactLocation `json:"artifactLocation"`
+	Region           SarifRegion           `json:"region"`
+}
+
+// SarifArtifactLocation identifies a file by URI.
+type SarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// SarifRegion is a line and column range within a file.
+type SarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+}
+
+// sarifLevel maps a finding severity to a SARIF result level.
+func sarifLevel(severity string) string {
+	switch severity {
+	case SeverityCritical, SeverityHigh:
+		return "error"
+	case SeverityMedium, SeverityDeprecated:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// findingBlock returns the resource or data source a finding most likely
+// refers to: one whose address appears in the description, else the first
+// one of the finding's type.
+func findingBlock(tf *TerraformFile, f Finding) (TerraformBlock, bool) {
+	blocks := slices.Concat(tf.Resources, tf.DataSources)
+	for _, b := range blocks {
+		if mentionsAddress(f.Description, b.Address()) {
+			return b, true
+		}
+	}
+	for _, b := range blocks {
+		if b.Type == f.ResourceType {
+			return b, true
+		}
+	}
+	return TerraformBlock{}, false
+}
+
+// mentionsAddress reports whether text names address as a whole word, so
+// aws_s3_bucket.logs is found in neither aws_s3_bucket.logs_archive nor
+// data.aws_s3_bucket.logs.
+func mentionsAddress(text, address string) bool {
+	for i := 0; ; {
+		j := strings.Index(text[i:], address)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(address)
+		before := start == 0 || (text[start-1] != '.' && !isAddressChar(text[start-1]))
+		if before && (end == len(text) || !isAddressChar(text[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+// isAddressChar reports whether c may appear in a Terraform block name.
+func isAddressChar(c byte) bool {
+	return c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// analyzeSarifHandler handles the /analyze/sarif endpoint. It runs the same
+// analysis as /analyze and reports the findings as a SARIF 2.1.0 log, so the
+// results can be shown by SARIF viewers and code scanning tools.
+func (api *BedrockConverseAPI) analyzeSarifHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	logger := loggerFromContext(r.Context())
+
+	var req SarifRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Path == "" {
+		req.Path = "main.tf"
+	}
+
+	if req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query text is empty or not a string")
+		return
+	}
+	if api.rejectPromptInjection(w, logger, req.Code) {
+		return
+	}
+
+	fw, err := lookupFramework(req.Framework)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	source, err := inputCode(req.Format, req.Code)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tf, ok := parseSource(r.Context(), w, req.Path, source)
+	if !ok {
+		return
+	}
+	if req.Framework == "" {
+		fw = detectFramework(tf)
+	}
+
+	findings, err := api.analyzeSource(r.Context(), logger, source, tf, fw)
+	if err != nil {
+		writeAnalysisError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.sarifLog(tf, req.Path, fw, findings))
+}
+
+// sarifLog converts findings for the file at path into a SARIF log.
+func (api *BedrockConverseAPI) sarifLog(tf *TerraformFile, path string, fw Framework, findings []Finding) SarifLog {
+	titles := make(map[string]string)
+	for _, rule := range api.Rules[fw.ID] {
+		titles[rule.RuleID] = rule.Title
+	}
+
+	run := SarifRun{
+		Tool:    SarifTool{Driver: SarifDriver{Name: "terraform-compliance", Rules: []SarifRule{}}},
+		Results: []SarifResult{},
+	}
+	seen := make(map[string]bool)
+	for _, f := range findings {
+		if f.RuleID != "" && !seen[f.RuleID] {
+			seen[f.RuleID] = true
+			text := titles[f.RuleID]
+			if text == "" {
+				text = f.Description
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, SarifRule{ID: f.RuleID, ShortDescription: SarifMessage{Text: text}})
+		}
+
+		region := SarifRegion{StartLine: 1}
+		if b, ok := findingBlock(tf, f); ok {
+			region = SarifRegion{StartLine: b.Line, StartColumn: b.Column, EndLine: b.EndLine}
+		}
+		run.Results = append(run.Results, SarifResult{
+			RuleID:  f.RuleID,
+			Level:   sarifLevel(f.Severity),
+			Message: SarifMessage{Text: f.Description},
+			Locations: []SarifLocation{{PhysicalLocation: SarifPhysicalLocation{
+				ArtifactLocation: SarifArtifactLocation{URI: path},
+				Region:           region,
+			}}},
+		})
+	}
+
+	return SarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []SarifRun{run}}
+}
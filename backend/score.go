@@ -0,0 +1,201 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+)
+
+// severityPenalty is the number of points each finding deducts, by severity.
+// Findings without a recognised severity are scored as LOW.
+var severityPenalty = map[string]int{
+	SeverityCritical: 10,
+	SeverityHigh:     5,
+	SeverityMedium:   2,
+	SeverityLow:      1,
+	SeverityInfo:     0,
+
+	SeverityDeprecated: 1,
+}
+
+// scorePenaltyScale weights penalties against the number of resources, so a
+// HIGH finding in a ten-resource configuration costs 5 points.
+const scorePenaltyScale = 10
+
+// Score breakdown groupings, chosen by the group_by request field.
+const (
+	groupByResourceType  = "resource_type"
+	groupByOWASPCategory = "owasp_category"
+)
+
+// unmappedOWASPCategory groups findings for rules with no OWASP category.
+const unmappedOWASPCategory = "unmapped"
+
+// ScoreBreakdown is the penalty attributed to one resource type or, when
+// grouped by OWASP category, to one category.
+type ScoreBreakdown struct {
+	ResourceType string `json:"resource_type,omitempty"`
+	// OWASPCategory is the OWASP IaC Security Top 10 category, such as
+	// IaC-SEC-04, and OWASPName its name. A finding in several categories
+	// counts towards each.
+	OWASPCategory string `json:"owasp_category,omitempty"`
+	OWASPName     string `json:"owasp_name,omitempty"`
+	Findings      int    `json:"findings"`
+	Penalty       int    `json:"penalty"`
+}
+
+// ScoreResponse defines the structure of the /score JSON response.
+type ScoreResponse struct {
+	Score              int              `json:"score"`
+	Grade              string           `json:"grade"`
+	FindingsBySeverity map[string]int   `json:"findings_by_severity"`
+	Framework          string           `json:"framework"`
+	Breakdown          []ScoreBreakdown `json:"breakdown"`
+}
+
+// scoreHandler handles the /score endpoint, condensing an analysis into a
+// 0-100 posture score and letter grade.
+func (api *BedrockConverseAPI) scoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	logger := loggerFromContext(r.Context())
+
+	var req AnalyzeRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	if req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query text is empty or not a string")
+		return
+	}
+	if api.rejectPromptInjection(w, logger, req.Code) {
+		return
+	}
+
+	fw, err := lookupFramework(req.Framework)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.GroupBy != "" && req.GroupBy != groupByResourceType && req.GroupBy != groupByOWASPCategory {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("group_by must be %q or %q, got %q", groupByResourceType, groupByOWASPCategory, req.GroupBy))
+		return
+	}
+
+	source, err := inputCode(req.Format, req.Code)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tf, ok := parseSource(r.Context(), w, "main.tf", source)
+	if !ok {
+		return
+	}
+	if req.Framework == "" {
+		fw = detectFramework(tf)
+	}
+
+	findings, err := api.analyzeSource(r.Context(), logger, source, tf, fw)
+	if err != nil {
+		writeAnalysisError(w, err)
+		return
+	}
+
+	resp := scoreFindings(findings, len(tf.Resources), req.GroupBy)
+	resp.Framework = fw.ID
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// scoreFindings computes the posture score for findings in a configuration
+// declaring resourceCount resources. The score starts at 100 and loses the
+// summed penalties, scaled down as the configuration grows. The breakdown
+// is by resource type unless groupBy is owasp_category.
+func scoreFindings(findings []Finding, resourceCount int, groupBy string) ScoreResponse {
+	resp := ScoreResponse{
+		FindingsBySeverity: map[string]int{
+			SeverityCritical: 0,
+			SeverityHigh:     0,
+			SeverityMedium:   0,
+			SeverityLow:      0,
+		},
+		Breakdown: []ScoreBreakdown{},
+	}
+
+	groups := make(map[string]*ScoreBreakdown)
+	total := 0
+	for _, f := range findings {
+		severity := f.Severity
+		if severity == "" {
+			severity = SeverityLow
+		}
+		resp.FindingsBySeverity[severity]++
+
+		for _, key := range breakdownKeys(f, groupBy) {
+			b, ok := groups[key]
+			if !ok {
+				b = newScoreBreakdown(key, groupBy)
+				groups[key] = b
+			}
+			b.Findings++
+			b.Penalty += severityPenalty[severity]
+		}
+		total += severityPenalty[severity]
+	}
+
+	for _, b := range groups {
+		resp.Breakdown = append(resp.Breakdown, *b)
+	}
+	slices.SortFunc(resp.Breakdown, func(a, b ScoreBreakdown) int {
+		return cmp.Or(cmp.Compare(b.Penalty, a.Penalty), cmp.Compare(a.ResourceType, b.ResourceType), cmp.Compare(a.OWASPCategory, b.OWASPCategory))
+	})
+
+	deduction := float64(total*scorePenaltyScale) / float64(max(resourceCount, 1))
+	resp.Score = max(0, 100-int(math.Round(deduction)))
+	resp.Grade = scoreGrade(resp.Score)
+	return resp
+}
+
+// breakdownKeys returns the breakdown groups a finding counts towards.
+func breakdownKeys(f Finding, groupBy string) []string {
+	if groupBy == groupByOWASPCategory {
+		if len(f.OWASPCategories) == 0 {
+			return []string{unmappedOWASPCategory}
+		}
+		return f.OWASPCategories
+	}
+	if f.ResourceType == "" {
+		return []string{"unknown"}
+	}
+	return []string{f.ResourceType}
+}
+
+// newScoreBreakdown returns an empty breakdown for the group key.
+func newScoreBreakdown(key, groupBy string) *ScoreBreakdown {
+	if groupBy == groupByOWASPCategory {
+		return &ScoreBreakdown{OWASPCategory: key, OWASPName: owaspMapping.Categories[key]}
+	}
+	return &ScoreBreakdown{ResourceType: key}
+}
+
+// scoreGrade maps a score to a letter grade.
+func scoreGrade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 80:
+		return "B"
+	case score >= 70:
+		return "C"
+	case score >= 60:
+		return "D"
+	default:
+		return "F"
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// redactedSecret replaces every detected secret in code sent to Bedrock.
+const redactedSecret = "<REDACTED>"
+
+// SecretPattern describes one kind of secret to look for. If Pattern has a
+// capture group, only the first group is redacted, so an assignment such as
+// password = "hunter2" keeps its attribute name.
+type SecretPattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+
+	re *regexp.Regexp
+}
+
+// defaultSecretPatterns are used when SECRET_PATTERNS_FILE is not set.
+// Values starting with "${" are interpolations, not literals, and are skipped.
+var defaultSecretPatterns = []SecretPattern{
+	{Name: "AWS access key", Pattern: `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`},
+	{Name: "AWS secret key", Pattern: `(?i)\b(?:aws_secret_access_key|secret_key)\s*=\s*"([^"$][^"]*)"`},
+	{Name: "private key", Pattern: `(-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----)`},
+	{Name: "private key", Pattern: `(?i)\bprivate_key\s*=\s*"([^"$][^"]*)"`},
+	{Name: "password", Pattern: `(?i)\b\w*password\w*\s*=\s*"([^"$][^"]*)"`},
+	{Name: "API token", Pattern: `(?i)\b\w*(?:api_key|token)\s*=\s*"([^"$][^"]*)"`},
+	{Name: "secret variable default", Pattern: `(?i)variable\s+"[^"]*(?:password|secret|token|api_key)[^"]*"\s*\{[^}]*?\bdefault\s*=\s*"([^"$][^"]*)"`},
+}
+
+// secretScanner finds and redacts hardcoded secrets in Terraform code.
+type secretScanner struct {
+	patterns []SecretPattern
+}
+
+// newSecretScanner compiles the secret patterns read from the JSON file at
+// path, or the default patterns when path is empty.
+func newSecretScanner(path string) (*secretScanner, error) {
+	return loadSecretScanner(path, defaultSecretPatterns)
+}
+
+// loadSecretScanner compiles the secret patterns read from the JSON file at
+// path, or defaults when path is empty.
+func loadSecretScanner(path string, defaults []SecretPattern) (*secretScanner, error) {
+	patterns := defaults
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret patterns: %w", err)
+		}
+		patterns = nil
+		if err := json.Unmarshal(data, &patterns); err != nil {
+			return nil, fmt.Errorf("failed to decode secret patterns: %w", err)
+		}
+	}
+
+	compiled := make([]SecretPattern, len(patterns))
+	for i, p := range patterns {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret pattern %q: %w", p.Name, err)
+		}
+		p.re = re
+		compiled[i] = p
+	}
+	return &secretScanner{patterns: compiled}, nil
+}
+
+// redact replaces every secret in code with a placeholder. It returns the
+// redacted code and a warning naming the block each secret was found in.
+func (s *secretScanner) redact(code string, tf *TerraformFile) (string, []string) {
+	return s.redactWith(code, tf, func(string) string { return redactedSecret })
+}
+
+// mask replaces every secret in text with a placeholder, without locating
+// them in a Terraform file.
+func (s *secretScanner) mask(text string) string {
+	masked, _ := s.redactWith(text, &TerraformFile{}, func(string) string { return redactedSecret })
+	return masked
+}
+
+// redactNumbered is like redact, but gives every secret its own numbered
+// placeholder so code returned by the agent can have the secrets put back
+// with the returned restore function.
+func (s *secretScanner) redactNumbered(code string, tf *TerraformFile) (string, []string, func(string) string) {
+	var secrets []string
+	redacted, warnings := s.redactWith(code, tf, func(secret string) string {
+		secrets = append(secrets, secret)
+		return fmt.Sprintf("<REDACTED_%d>", len(secrets))
+	})
+
+	restore := func(code string) string {
+		// A later pattern may have matched an earlier placeholder, so
+		// placeholders are expanded newest first.
+		for i := len(secrets); i > 0; i-- {
+			code = strings.ReplaceAll(code, fmt.Sprintf("<REDACTED_%d>", i), secrets[i-1])
+		}
+		return code
+	}
+	return redacted, warnings, restore
+}
+
+// redactWith replaces every secret in code with the placeholder returned by
+// placeholder for it.
+func (s *secretScanner) redactWith(code string, tf *TerraformFile, placeholder func(secret string) string) (string, []string) {
+	var warnings []string
+	for _, p := range s.patterns {
+		var sb strings.Builder
+		last := 0
+		for _, m := range p.re.FindAllStringSubmatchIndex(code, -1) {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+
+			line := strings.Count(code[:start], "\n") + 1
+			location := fmt.Sprintf("line %d", line)
+			if b, ok := tf.blockAt(line); ok {
+				location = b.String()
+			}
+			warnings = append(warnings, fmt.Sprintf("Found potential %s in %s", p.Name, location))
+
+			sb.WriteString(code[last:start])
+			sb.WriteString(placeholder(code[start:end]))
+			last = end
+		}
+		sb.WriteString(code[last:])
+		code = sb.String()
+	}
+	return code, warnings
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// SecurityGroupRule is an ingress or egress rule declared inline in an
+// aws_security_group or by an aws_security_group_rule.
+type SecurityGroupRule struct {
+	// Resource is the address of the declaring resource.
+	Resource  string
+	Direction string
+	// FromPort and ToPort are -1 when not a literal number.
+	FromPort int
+	ToPort   int
+	// Protocol is empty when not a literal string.
+	Protocol       string
+	CIDRBlocks     []string
+	IPv6CIDRBlocks []string
+	Line           int
+}
+
+// allTraffic reports whether the rule covers every protocol and port.
+func (r SecurityGroupRule) allTraffic() bool {
+	return r.Protocol == "-1" || strings.EqualFold(r.Protocol, "all")
+}
+
+// coversTCPPort reports whether the rule admits TCP traffic to port.
+func (r SecurityGroupRule) coversTCPPort(port int) bool {
+	if r.allTraffic() {
+		return true
+	}
+	if !strings.EqualFold(r.Protocol, "tcp") && r.Protocol != "6" {
+		return false
+	}
+	return r.FromPort >= 0 && r.FromPort <= port && port <= r.ToPort
+}
+
+// openToInternet returns the rule's CIDR blocks that match every address.
+func (r SecurityGroupRule) openToInternet() []string {
+	var open []string
+	for _, cidr := range slices.Concat(r.CIDRBlocks, r.IPv6CIDRBlocks) {
+		if cidr == "0.0.0.0/0" || cidr == "::/0" {
+			open = append(open, cidr)
+		}
+	}
+	return open
+}
+
+// ports describes the rule's port range for the prompt.
+func (r SecurityGroupRule) ports() string {
+	switch {
+	case r.allTraffic():
+		return "all"
+	case r.FromPort < 0 || r.ToPort < 0:
+		return "?"
+	case r.FromPort == r.ToPort:
+		return strconv.Itoa(r.FromPort)
+	default:
+		return fmt.Sprintf("%d-%d", r.FromPort, r.ToPort)
+	}
+}
+
+// securityGroupRules extracts the rules declared by a resource block.
+func securityGroupRules(b TerraformBlock) []SecurityGroupRule {
+	switch b.Type {
+	case "aws_security_group":
+		var rules []SecurityGroupRule
+		for _, nested := range b.Body.Blocks {
+			if nested.Type != "ingress" && nested.Type != "egress" {
+				continue
+			}
+			rule := securityGroupRule(TerraformBlock{Body: nested.Body}, b.Address(), nested.Type)
+			rule.Line = nested.TypeRange.Start.Line
+			rules = append(rules, rule)
+		}
+		return rules
+	case "aws_security_group_rule":
+		rule := securityGroupRule(b, b.Address(), literalString(b, "type"))
+		rule.Line = b.Line
+		return []SecurityGroupRule{rule}
+	}
+	return nil
+}
+
+// securityGroupRule reads the port, protocol and CIDR attributes of body.
+func securityGroupRule(body TerraformBlock, resource, direction string) SecurityGroupRule {
+	return SecurityGroupRule{
+		Resource:       resource,
+		Direction:      direction,
+		FromPort:       literalPort(body, "from_port"),
+		ToPort:         literalPort(body, "to_port"),
+		Protocol:       literalProtocol(body),
+		CIDRBlocks:     literalStrings(body, "cidr_blocks"),
+		IPv6CIDRBlocks: literalStrings(body, "ipv6_cidr_blocks"),
+	}
+}
+
+// literalPort returns the value of attribute name when it is a constant
+// number, or -1 otherwise.
+func literalPort(b TerraformBlock, name string) int {
+	port, ok := literalInt(b, name)
+	if !ok {
+		return -1
+	}
+	return port
+}
+
+// literalProtocol returns the protocol attribute, which Terraform accepts
+// as a string or a protocol number.
+func literalProtocol(b TerraformBlock) string {
+	v, _, ok := literalValue(b, "protocol")
+	if !ok {
+		return ""
+	}
+	if v.Type() == cty.Number {
+		return v.AsBigFloat().String()
+	}
+	if v.Type() == cty.String {
+		return v.AsString()
+	}
+	return ""
+}
+
+// literalStrings returns the constant strings in list attribute name.
+func literalStrings(b TerraformBlock, name string) []string {
+	v, _, ok := literalValue(b, name)
+	if !ok || !v.CanIterateElements() {
+		return nil
+	}
+	var values []string
+	for it := v.ElementIterator(); it.Next(); {
+		_, elem := it.Element()
+		if !elem.IsNull() && elem.Type() == cty.String {
+			values = append(values, elem.AsString())
+		}
+	}
+	return values
+}
+
+// securityGroupTable formats rules as a table for the analysis prompt.
+func securityGroupTable(rules []SecurityGroupRule) string {
+	var sb strings.Builder
+	sb.WriteString("| resource | direction | protocol | ports | cidr_blocks | ipv6_cidr_blocks | line |\n")
+	sb.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, r := range rules {
+		protocol := r.Protocol
+		if protocol == "" {
+			protocol = "?"
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s | %s | %d |\n",
+			r.Resource, r.Direction, protocol, r.ports(),
+			strings.Join(r.CIDRBlocks, ", "), strings.Join(r.IPv6CIDRBlocks, ", "), r.Line)
+	}
+	return sb.String()
+}
+
+// sensitivePort is an administrative or database port that must not be
+// reachable from the internet.
+type sensitivePort struct {
+	Port     int
+	Service  string
+	RuleID   string
+	Severity string
+}
+
+// sensitivePorts are checked locally, without waiting for the agent.
+var sensitivePorts = []sensitivePort{
+	{22, "SSH", "EC2.13", SeverityHigh},
+	{3389, "RDP", "EC2.14", SeverityHigh},
+	{5432, "PostgreSQL", "EC2.19", SeverityCritical},
+}
+
+// securityGroupAnalyzer flags ingress rules that open sensitive ports to
+// the whole internet.
+type securityGroupAnalyzer struct{}
+
+// Analyze implements Analyzer.
+func (securityGroupAnalyzer) Analyze(_ context.Context, tf TerraformFile) ([]Finding, error) {
+	var findings []Finding
+	for _, rule := range tf.SecurityGroupRules {
+		if rule.Direction != "ingress" {
+			continue
+		}
+		open := rule.openToInternet()
+		if len(open) == 0 {
+			continue
+		}
+		resourceType, _, _ := strings.Cut(rule.Resource, ".")
+		for _, p := range sensitivePorts {
+			if !rule.coversTCPPort(p.Port) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Severity:     p.Severity,
+				ResourceType: resourceType,
+				RuleID:       p.RuleID,
+				Description:  fmt.Sprintf("%s ingress rule on line %d opens %s port %d to %s", rule.Resource, rule.Line, p.Service, p.Port, strings.Join(open, ", ")),
+			})
+		}
+	}
+	return findings, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// sensitiveRedacted replaces values Terraform marks as sensitive in code
+// sent to the agent.
+const sensitiveRedacted = "<SENSITIVE_REDACTED>"
+
+// isSensitiveBlock reports whether body sets sensitive = true.
+func isSensitiveBlock(body *hclsyntax.Body) bool {
+	attr, ok := body.Attributes["sensitive"]
+	if !ok {
+		return false
+	}
+	v, diags := attr.Expr.Value(nil)
+	return !diags.HasErrors() && v.IsKnown() && !v.IsNull() && v.Type() == cty.Bool && v.True()
+}
+
+// referencesSensitive reports whether expr refers to any of the var.* or
+// local.* names in sensitive.
+func referencesSensitive(expr hclsyntax.Expression, sensitive map[string]bool) bool {
+	for _, traversal := range expr.Variables() {
+		if len(traversal) < 2 {
+			continue
+		}
+		if attr, ok := traversal[1].(hcl.TraverseAttr); ok && sensitive[traversal.RootName()+"."+attr.Name] {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitiveVariables returns the names of the input variables declared
+// with sensitive = true.
+func (tf *TerraformFile) sensitiveVariables() []string {
+	var names []string
+	for _, v := range tf.Variables {
+		if v.Body != nil && isSensitiveBlock(v.Body) {
+			names = append(names, v.Name)
+		}
+	}
+	return names
+}
+
+// redactSensitive replaces the values Terraform would treat as sensitive in
+// tf.Source: defaults of sensitive variables, values of sensitive outputs,
+// and locals and outputs computed from either. It returns the number of
+// values replaced. Lines keep their numbers, so findings still point at the
+// submitted code.
+func (tf *TerraformFile) redactSensitive() int {
+	file, diags := hclsyntax.ParseConfig([]byte(tf.Source), tf.Filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return 0
+	}
+
+	sensitive := make(map[string]bool)
+	var redact []hcl.Range
+	var outputs []*hclsyntax.Body
+	locals := make(map[string]*hclsyntax.Attribute)
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		switch block.Type {
+		case "variable":
+			if len(block.Labels) != 1 || !isSensitiveBlock(block.Body) {
+				continue
+			}
+			sensitive["var."+block.Labels[0]] = true
+			if def, ok := block.Body.Attributes["default"]; ok {
+				redact = append(redact, def.Expr.Range())
+			}
+		case "output":
+			outputs = append(outputs, block.Body)
+		case "locals":
+			for name, attr := range block.Body.Attributes {
+				locals[name] = attr
+			}
+		}
+	}
+
+	// Locals may build on each other, so they are revisited until no
+	// more become sensitive.
+	for changed := true; changed; {
+		changed = false
+		for name, attr := range locals {
+			if !sensitive["local."+name] && referencesSensitive(attr.Expr, sensitive) {
+				sensitive["local."+name] = true
+				redact = append(redact, attr.Expr.Range())
+				changed = true
+			}
+		}
+	}
+
+	for _, body := range outputs {
+		value, ok := body.Attributes["value"]
+		if ok && (isSensitiveBlock(body) || referencesSensitive(value.Expr, sensitive)) {
+			redact = append(redact, value.Expr.Range())
+		}
+	}
+
+	slices.SortFunc(redact, func(a, b hcl.Range) int { return cmp.Compare(b.Start.Byte, a.Start.Byte) })
+	placeholder := strconv.Quote(sensitiveRedacted)
+	for _, r := range redact {
+		removed := tf.Source[r.Start.Byte:r.End.Byte]
+		tf.Source = tf.Source[:r.Start.Byte] + placeholder + strings.Repeat("\n", strings.Count(removed, "\n")) + tf.Source[r.End.Byte:]
+	}
+	return len(redact)
+}
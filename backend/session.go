@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+// sessionIDHeader is the header clients use to continue an existing agent session.
+const sessionIDHeader = "X-Session-ID"
+
+// sessionResumedHeader is set to "true" when a workspace's session is resumed.
+const sessionResumedHeader = "X-Session-Resumed"
+
+// maxWorkspaceSessions bounds how many workspaces' sessions are remembered.
+const maxWorkspaceSessions = 10000
+
+// maxIssuedSessions bounds how many generated session IDs are remembered as
+// belonging to an API key.
+const maxIssuedSessions = 100000
+
+// sessionIDPattern mirrors the characters and length Bedrock accepts for an agent session ID.
+var sessionIDPattern = regexp.MustCompile(`^[0-9a-zA-Z._:-]{2,100}$`)
+
+// newUUID generates a random RFC 4122 version 4 UUID.
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// validSessionID reports whether id is safe to forward to Bedrock as a session ID.
+func validSessionID(id string) bool {
+	return sessionIDPattern.MatchString(id)
+}
+
+// workspaceSession is the most recent agent session of a workspace.
+type workspaceSession struct {
+	ID        string
+	CreatedAt time.Time
+}
+
+// sessionOwner is the API key and workspace a session is resumed for, so
+// knowing a workspace ID is not enough to join another client's session.
+type sessionOwner struct {
+	KeyID     string
+	Workspace string
+}
+
+// workspaceSessions remembers each workspace's most recent agent session so
+// a client that reloads or reconnects keeps the agent's context.
+type workspaceSessions struct {
+	ttl      time.Duration
+	sessions *expirable.LRU[sessionOwner, workspaceSession]
+	// issued maps the session IDs the server generated to the API key they
+	// were generated for.
+	issued *expirable.LRU[string, string]
+}
+
+// newWorkspaceSessions returns a store resuming sessions younger than ttl.
+func newWorkspaceSessions(ttl time.Duration) *workspaceSessions {
+	return &workspaceSessions{
+		ttl:      ttl,
+		sessions: expirable.NewLRU[sessionOwner, workspaceSession](maxWorkspaceSessions, nil, ttl),
+		issued:   expirable.NewLRU[string, string](maxIssuedSessions, nil, ttl),
+	}
+}
+
+// resume returns the session of owner if it is younger than the TTL.
+func (s *workspaceSessions) resume(owner sessionOwner) (string, bool) {
+	session, ok := s.sessions.Get(owner)
+	if !ok || time.Since(session.CreatedAt) >= s.ttl {
+		return "", false
+	}
+	return session.ID, true
+}
+
+// issue records that the server generated id for the API key keyID.
+func (s *workspaceSessions) issue(keyID, id string) {
+	s.issued.Add(id, keyID)
+}
+
+// record makes id the most recent session of owner, unless it already is.
+// Only sessions the server issued to owner's API key are recorded, so a
+// client-chosen session ID never replaces a workspace's session.
+func (s *workspaceSessions) record(owner sessionOwner, id string) {
+	if keyID, ok := s.issued.Get(id); !ok || keyID != owner.KeyID {
+		return
+	}
+	if session, ok := s.sessions.Peek(owner); ok && session.ID == id {
+		return
+	}
+	s.sessions.Add(owner, workspaceSession{ID: id, CreatedAt: time.Now()})
+}
+
+// requestSessionID returns the session ID for r and echoes it in the
+// response. The client's session is reused so follow-up requests share agent
+// context. Failing that, a client naming its workspace resumes the recent
+// session of that workspace and API key; otherwise a new one is started so
+// concurrent users never share history. It writes an error response and
+// returns false on failure.
+func (api *BedrockConverseAPI) requestSessionID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	owner := sessionOwner{KeyID: keyIDFromContext(r.Context()), Workspace: r.Header.Get(workspaceIDHeader)}
+	sessionID := r.Header.Get(sessionIDHeader)
+	if sessionID == "" && owner.Workspace != "" {
+		if id, ok := api.Sessions.resume(owner); ok {
+			sessionID = id
+			w.Header().Set(sessionResumedHeader, "true")
+			loggerFromContext(r.Context()).Info("Resuming workspace session", "workspace_id", owner.Workspace, "session_id", id)
+		}
+	}
+	if sessionID == "" {
+		id, err := newUUID()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to create session")
+			loggerFromContext(r.Context()).Error("Error generating session ID", "error", err)
+			return "", false
+		}
+		sessionID = id
+		api.Sessions.issue(owner.KeyID, sessionID)
+	} else if !validSessionID(sessionID) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid "+sessionIDHeader+" header")
+		return "", false
+	}
+
+	if owner.Workspace != "" {
+		api.Sessions.record(owner, sessionID)
+	}
+	w.Header().Set(sessionIDHeader, sessionID)
+	return sessionID, true
+}
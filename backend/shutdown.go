@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// drainingServer wraps http.Server so that shutdown lets in-flight requests
+// finish while turning new ones away with 503.
+type drainingServer struct {
+	*http.Server
+
+	shuttingDown atomic.Bool
+	openConns    atomic.Int64
+}
+
+// newDrainingServer creates a server listening on addr that serves handler.
+func newDrainingServer(addr string, handler http.Handler) *drainingServer {
+	s := &drainingServer{}
+	s.Server = &http.Server{
+		Addr:    addr,
+		Handler: s.rejectWhileDraining(handler),
+		ConnState: func(_ net.Conn, state http.ConnState) {
+			switch state {
+			case http.StateNew:
+				s.openConns.Add(1)
+			case http.StateClosed, http.StateHijacked:
+				s.openConns.Add(-1)
+			}
+		},
+	}
+	return s
+}
+
+// rejectWhileDraining answers requests that arrive after shutdown has begun
+// with 503 instead of starting new work.
+func (s *drainingServer) rejectWhileDraining(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.shuttingDown.Load() {
+			w.Header().Set("Connection", "close")
+			writeJSONError(w, http.StatusServiceUnavailable, "Server is shutting down")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// drain stops accepting new requests and waits until in-flight requests
+// complete or ctx expires. It returns the number of connections that were
+// open when draining began.
+func (s *drainingServer) drain(ctx context.Context) (int64, error) {
+	s.shuttingDown.Store(true)
+	open := s.openConns.Load()
+	return open, s.Shutdown(ctx)
+}
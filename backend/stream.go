@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// StreamChunk is the payload of each Server-Sent Event carrying agent output.
+type StreamChunk struct {
+	Text string `json:"text"`
+}
+
+// StreamLocalFindings is the payload of the "local" event, sent with the
+// local analyzers' findings before the agent is invoked.
+type StreamLocalFindings struct {
+	Findings []Finding `json:"findings"`
+}
+
+// acceptsEventStream reports whether the client asked for a Server-Sent Events response.
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// sseWriter writes Server-Sent Events, flushing each one to the client.
+type sseWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// newSSEWriter starts a text/event-stream response on w.
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	return &sseWriter{w: w, rc: http.NewResponseController(w)}
+}
+
+// send writes v as the JSON data of an event. An empty event name produces
+// an unnamed event, which EventSource delivers to its onmessage handler.
+func (s *sseWriter) send(event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	if event != "" {
+		if _, err := fmt.Fprintf(s.w, "event: %s\n", event); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// streamAnalysis runs the local analyzers and sends their findings in a
+// "local" event, so clients can show them before the agent answers. It then
+// invokes the agent and relays each response chunk to the client, and to the
+// requests waiting on call, as it arrives. The stream ends with a "done"
+// event carrying base completed with the full suggestion and findings, which
+// is also cached under key, or an "error" event if the analysis fails. The
+// completed response or error is returned for the waiting requests.
+func (api *BedrockConverseAPI) streamAnalysis(w http.ResponseWriter, r *http.Request, logger *slog.Logger, sessionID, key string, tf *TerraformFile, fw Framework, base AnalyzeResponse, call *inflightCall) (AnalyzeResponse, error) {
+	sse := newSSEWriter(w)
+
+	local, err := runAnalyzers(withAnalysisRequest(r.Context(), &analysisRequest{Framework: fw, SessionID: sessionID}), *tf, bedrockAnalyzerName)
+	if err != nil {
+		logger.Warn("Analyzer failed", "error", err)
+		if err := sse.send("error", ErrorResponse{Error: "Analysis failed", Detail: err.Error()}); err != nil {
+			logger.Warn("Failed to stream error to client", "error", err)
+		}
+		return AnalyzeResponse{}, err
+	}
+	if err := sse.send("local", StreamLocalFindings{Findings: local}); err != nil {
+		logger.Warn("Failed to stream local findings to client", "error", err)
+	}
+
+	// In offline mode there is no agent answer to stream, only the final
+	// event with the local findings.
+	var analysis agentAnalysis
+	if !api.Config().OfflineMode {
+		analysis, err = api.invokeAnalysis(r.Context(), logger, tf, fw, sessionID, func(chunk []byte) {
+			call.publish(string(chunk))
+			if err := sse.send("", StreamChunk{Text: string(chunk)}); err != nil {
+				logger.Warn("Failed to stream chunk to client", "error", err)
+			}
+		})
+	}
+	if err != nil {
+		event := ErrorResponse{Error: analysisErrorMessage(err)}
+		if errors.Is(err, errUnparseableResponse) {
+			event.Detail = err.Error()
+		}
+		if err := sse.send("error", event); err != nil {
+			logger.Warn("Failed to stream error to client", "error", err)
+		}
+		return AnalyzeResponse{}, err
+	}
+
+	merged, dropped := tf.mergeFindings(analysis.Findings, local)
+	findings := tf.withBlastRadius(tf.WorkspaceRules.apply(merged))
+
+	resp := base
+	resp.Suggestion, resp.Findings, resp.Truncated = analysis.Suggestion, findings, analysis.Truncated
+	resp.Chunked, resp.ChunkCount = analysis.Chunks > 1, analysis.Chunks
+	resp.Metrics.recordAgent(analysis.PromptTokens, analysis.Latency)
+	resp.Metrics.DuplicatesRemoved = analysis.DuplicatesRemoved + dropped
+	if analysis.DeadlineExceeded {
+		resp.Truncated, resp.Reason, resp.RemainingResources = true, truncatedDeadline, analysis.Remaining
+	} else {
+		api.Cache.Add(key, resp)
+	}
+	shared := resp
+	resp.AnalysisID = api.recordHistory(r, tf.Source, fw, findings)
+
+	if err := sse.send("done", resp); err != nil {
+		logger.Warn("Failed to stream final event to client", "error", err)
+	}
+	return shared, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// tagRuleID is the rule ID of findings for missing required tags.
+const tagRuleID = "TAG.1"
+
+// TagsRequest defines the structure of the incoming /analyze/tags JSON request.
+type TagsRequest struct {
+	Code         string   `json:"code"`
+	Format       Format   `json:"format,omitempty"`
+	RequiredTags []string `json:"required_tags"`
+}
+
+// TagsResponse defines the structure of the /analyze/tags JSON response.
+// Skipped lists resources whose tags are computed, such as from a variable,
+// and so cannot be checked without running Terraform.
+type TagsResponse struct {
+	Findings []Finding `json:"findings"`
+	Skipped  []string  `json:"skipped,omitempty"`
+}
+
+// analyzeTagsHandler handles the /analyze/tags endpoint. It checks every
+// resource's tags against the required tags locally, without invoking Bedrock.
+func (api *BedrockConverseAPI) analyzeTagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	var req TagsRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	if req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query text is empty or not a string")
+		return
+	}
+	if len(req.RequiredTags) == 0 || slices.Contains(req.RequiredTags, "") {
+		writeJSONError(w, http.StatusBadRequest, "required_tags must list at least one non-empty tag name")
+		return
+	}
+
+	source, err := inputCode(req.Format, req.Code)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tf, ok := parseSource(r.Context(), w, "main.tf", source)
+	if !ok {
+		return
+	}
+
+	findings, skipped := tf.missingTags(req.RequiredTags)
+	writeJSON(w, http.StatusOK, TagsResponse{Findings: nonNil(findings), Skipped: skipped})
+}
+
+// missingTags returns a finding for each resource lacking any of the
+// required tags, and the addresses of resources whose tags are not literal.
+// Tags set in an AWS provider's default_tags count for every aws resource.
+func (tf *TerraformFile) missingTags(required []string) ([]Finding, []string) {
+	defaults, defaultsKnown := tf.awsDefaultTags()
+
+	var findings []Finding
+	var skipped []string
+	for _, res := range tf.Resources {
+		var tags []string
+		known := true
+		if attr, ok := res.Body.Attributes["tags"]; ok {
+			tags, known = tagKeys(attr.Expr)
+		}
+		if strings.HasPrefix(res.Type, "aws_") {
+			tags = append(tags, defaults...)
+			known = known && defaultsKnown
+		}
+
+		var missing []string
+		for _, tag := range required {
+			if !slices.Contains(tags, tag) {
+				missing = append(missing, tag)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		if !known {
+			skipped = append(skipped, res.Address())
+			continue
+		}
+		findings = append(findings, Finding{
+			Severity:     SeverityLow,
+			ResourceType: res.Type,
+			RuleID:       tagRuleID,
+			Description:  fmt.Sprintf("%s is missing required tags: %s", res.Address(), strings.Join(missing, ", ")),
+		})
+	}
+	return findings, skipped
+}
+
+// awsDefaultTags returns the tag keys every AWS provider block applies
+// through default_tags. With several provider blocks, only the keys common
+// to all of them are returned, since a resource may use any of them.
+func (tf *TerraformFile) awsDefaultTags() ([]string, bool) {
+	var common []string
+	first := true
+	for _, p := range tf.Providers {
+		if p.Type != "aws" {
+			continue
+		}
+
+		var keys []string
+		for _, block := range p.Body.Blocks {
+			if attr, ok := block.Body.Attributes["tags"]; block.Type == "default_tags" && ok {
+				var known bool
+				if keys, known = tagKeys(attr.Expr); !known {
+					return nil, false
+				}
+			}
+		}
+
+		if first {
+			common, first = keys, false
+		} else {
+			common = slices.DeleteFunc(common, func(k string) bool { return !slices.Contains(keys, k) })
+		}
+	}
+	return common, true
+}
+
+// tagKeys returns the keys of a tags expression written as an object or a
+// merge of objects. It reports false if any part is computed.
+func tagKeys(expr hclsyntax.Expression) ([]string, bool) {
+	switch e := expr.(type) {
+	case *hclsyntax.ObjectConsExpr:
+		keys := make([]string, 0, len(e.Items))
+		for _, item := range e.Items {
+			k, diags := item.KeyExpr.Value(nil)
+			if diags.HasErrors() || !k.IsKnown() || k.IsNull() || k.Type() != cty.String {
+				return nil, false
+			}
+			keys = append(keys, k.AsString())
+		}
+		return keys, true
+	case *hclsyntax.FunctionCallExpr:
+		if e.Name != "merge" {
+			return nil, false
+		}
+		var keys []string
+		for _, arg := range e.Args {
+			argKeys, ok := tagKeys(arg)
+			if !ok {
+				return nil, false
+			}
+			keys = append(keys, argKeys...)
+		}
+		return keys, true
+	}
+	return nil, false
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// TerraformBlock describes a top-level block declared in a Terraform file.
+type TerraformBlock struct {
+	// Type is the resource or data source type, or the provider name.
+	// It is empty for variables and modules.
+	Type string `json:"type,omitempty"`
+	// Name is the block's local name. It is empty for providers.
+	Name string `json:"name,omitempty"`
+	Line int    `json:"line"`
+	// Source and Version are a module call's literal source and version
+	// arguments. They are empty for other blocks.
+	Source  string `json:"source,omitempty"`
+	Version string `json:"version,omitempty"`
+
+	// Kind is the block keyword: resource, data, provider, variable or module.
+	Kind    string          `json:"-"`
+	Column  int             `json:"-"`
+	EndLine int             `json:"-"`
+	Body    *hclsyntax.Body `json:"-"`
+}
+
+// Address returns the block's Terraform address, such as aws_s3_bucket.logs.
+func (b TerraformBlock) Address() string {
+	switch {
+	case b.Type == "":
+		return b.Name
+	case b.Name == "":
+		return b.Type
+	default:
+		return b.Type + "." + b.Name
+	}
+}
+
+// String describes the block for messages, such as aws_s3_bucket.logs or
+// variable db_password. Resources are named by their address alone.
+func (b TerraformBlock) String() string {
+	if b.Kind == "resource" {
+		return b.Address()
+	}
+	return b.Kind + " " + b.Address()
+}
+
+// TerraformFile is the structured view of a Terraform configuration used to
+// build analysis prompts.
+type TerraformFile struct {
+	// Filename and Source identify the code the file was parsed from.
+	// Analyzers receive Source with hardcoded secrets redacted.
+	Filename string
+	Source   string
+	// VariableValues holds the input variable values supplied with the
+	// request, formatted as a .tfvars file with secrets redacted.
+	VariableValues string
+	// DataSourceValues lists the arguments of each data source, with
+	// secrets redacted.
+	DataSourceValues string
+	// Workspace is the Terraform workspace the code is analyzed for, and
+	// WorkspaceRules the overrides configured for it, if any.
+	Workspace      string
+	WorkspaceRules *WorkspaceRules
+	// MaxSuggestions is the number of suggestions requested from the
+	// agent, or 0 for the configured default.
+	MaxSuggestions int
+	// Deadline is when the analysis must return with what it has, or zero
+	// for no deadline.
+	Deadline time.Time
+	// RedactedCount is the number of values marked sensitive in Terraform
+	// that were withheld from the agent.
+	RedactedCount int
+
+	Resources   []TerraformBlock
+	DataSources []TerraformBlock
+	Providers   []TerraformBlock
+	Variables   []TerraformBlock
+	Modules     []TerraformBlock
+	Locals      []string
+	// Moves are the moved blocks, in the order they are declared.
+	Moves []ResourceMove
+
+	// SecurityGroupRules are the ingress and egress rules declared by
+	// aws_security_group and aws_security_group_rule resources.
+	SecurityGroupRules []SecurityGroupRule
+	// LambdaFunctions are the settings of the aws_lambda_function resources.
+	LambdaFunctions []LambdaFunction
+}
+
+// parseTerraform parses HCL source into a TerraformFile. Blocks that parsed
+// successfully are returned alongside any diagnostics, so callers can still
+// work with a partially valid file.
+func parseTerraform(filename, code string) (*TerraformFile, hcl.Diagnostics) {
+	tf := &TerraformFile{Filename: filename, Source: code}
+
+	file, diags := hclsyntax.ParseConfig([]byte(code), filename, hcl.InitialPos)
+	if file == nil {
+		return tf, diags
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return tf, diags
+	}
+
+	for _, block := range body.Blocks {
+		tb := TerraformBlock{
+			Kind:    block.Type,
+			Line:    block.TypeRange.Start.Line,
+			Column:  block.TypeRange.Start.Column,
+			EndLine: block.Body.SrcRange.End.Line,
+			Body:    block.Body,
+		}
+
+		switch {
+		case block.Type == "resource" && len(block.Labels) == 2:
+			tb.Type, tb.Name = block.Labels[0], block.Labels[1]
+			tf.Resources = append(tf.Resources, tb)
+			tf.SecurityGroupRules = append(tf.SecurityGroupRules, securityGroupRules(tb)...)
+			if f, ok := lambdaFunction(tb); ok {
+				tf.LambdaFunctions = append(tf.LambdaFunctions, f)
+			}
+		case block.Type == "data" && len(block.Labels) == 2:
+			tb.Type, tb.Name = block.Labels[0], block.Labels[1]
+			tf.DataSources = append(tf.DataSources, tb)
+		case block.Type == "provider" && len(block.Labels) == 1:
+			tb.Type = block.Labels[0]
+			tf.Providers = append(tf.Providers, tb)
+		case block.Type == "variable" && len(block.Labels) == 1:
+			tb.Name = block.Labels[0]
+			tf.Variables = append(tf.Variables, tb)
+		case block.Type == "module" && len(block.Labels) == 1:
+			tb.Name = block.Labels[0]
+			tb.Source = literalString(tb, "source")
+			tb.Version = literalString(tb, "version")
+			tf.Modules = append(tf.Modules, tb)
+		case block.Type == "moved":
+			if move, ok := parseMove(block.Body); ok {
+				tf.Moves = append(tf.Moves, move)
+			}
+		case block.Type == "locals":
+			for name := range block.Body.Attributes {
+				tf.Locals = append(tf.Locals, name)
+			}
+		}
+	}
+	slices.Sort(tf.Locals)
+
+	return tf, diags
+}
+
+// merge appends the blocks declared in other to tf. The source is left unchanged.
+func (tf *TerraformFile) merge(other *TerraformFile) {
+	tf.Resources = append(tf.Resources, other.Resources...)
+	tf.DataSources = append(tf.DataSources, other.DataSources...)
+	tf.Providers = append(tf.Providers, other.Providers...)
+	tf.Variables = append(tf.Variables, other.Variables...)
+	tf.Modules = append(tf.Modules, other.Modules...)
+	tf.Locals = append(tf.Locals, other.Locals...)
+	slices.Sort(tf.Locals)
+	tf.Moves = append(tf.Moves, other.Moves...)
+	tf.SecurityGroupRules = append(tf.SecurityGroupRules, other.SecurityGroupRules...)
+	tf.LambdaFunctions = append(tf.LambdaFunctions, other.LambdaFunctions...)
+}
+
+// blockAt returns the resource, data source, provider, variable or module
+// block spanning the given line.
+func (tf *TerraformFile) blockAt(line int) (TerraformBlock, bool) {
+	for _, blocks := range [][]TerraformBlock{tf.Resources, tf.DataSources, tf.Providers, tf.Variables, tf.Modules} {
+		for _, b := range blocks {
+			if line >= b.Line && line <= b.EndLine {
+				return b, true
+			}
+		}
+	}
+	return TerraformBlock{}, false
+}
+
+// usesProvider reports whether the file configures the named provider or
+// declares resources or data sources belonging to it. A beta provider such
+// as google-beta counts as its GA provider, whose resource types it shares.
+func (tf *TerraformFile) usesProvider(name string) bool {
+	for _, b := range tf.Providers {
+		if b.Type == name || b.Type == name+"-beta" {
+			return true
+		}
+	}
+	for _, blocks := range [][]TerraformBlock{tf.Resources, tf.DataSources} {
+		for _, b := range blocks {
+			if strings.HasPrefix(b.Type, name+"_") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ResourceTypes returns the distinct resource types declared in the file, sorted.
+func (tf *TerraformFile) ResourceTypes() []string {
+	var types []string
+	for _, r := range tf.Resources {
+		types = append(types, r.Type)
+	}
+	slices.Sort(types)
+	return slices.Compact(types)
+}
+
+// dataSourceValues lists each data source with its arguments as written in
+// tf.Source, one per line, such as
+// "- aws_ami.ubuntu: most_recent = true, owners = [\"099720117109\"]".
+// It must be called before tf.Source is redacted, while block ranges still
+// match it.
+func (tf *TerraformFile) dataSourceValues() string {
+	var describe func(body *hclsyntax.Body) []string
+	describe = func(body *hclsyntax.Body) []string {
+		var args []string
+		for _, name := range sortedKeys(body.Attributes) {
+			r := body.Attributes[name].Expr.Range()
+			if r.End.Byte > len(tf.Source) {
+				continue
+			}
+			args = append(args, name+" = "+strings.Join(strings.Fields(tf.Source[r.Start.Byte:r.End.Byte]), " "))
+		}
+		for _, block := range body.Blocks {
+			args = append(args, block.Type+" { "+strings.Join(describe(block.Body), ", ")+" }")
+		}
+		return args
+	}
+
+	var sb strings.Builder
+	for _, b := range tf.DataSources {
+		if b.Body == nil {
+			continue
+		}
+		fmt.Fprintf(&sb, "- %s", b.Address())
+		if args := describe(b.Body); len(args) > 0 {
+			fmt.Fprintf(&sb, ": %s", strings.Join(args, ", "))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// promptContext summarizes the declared blocks for inclusion in the analysis prompt.
+func (tf *TerraformFile) promptContext() string {
+	var sb strings.Builder
+	if tf.Workspace != "" {
+		fmt.Fprintf(&sb, "Workspace: %s\n", tf.Workspace)
+	}
+	writeSection := func(title string, blocks []TerraformBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		addresses := make([]string, len(blocks))
+		for i, b := range blocks {
+			addresses[i] = b.Address()
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", title, strings.Join(addresses, ", "))
+	}
+
+	writeSection("Resources", tf.Resources)
+	sb.WriteString(tf.movesPromptContext())
+	if tf.DataSourceValues != "" {
+		sb.WriteString("Data Sources:\n")
+		sb.WriteString(tf.DataSourceValues)
+	} else {
+		writeSection("Data Sources", tf.DataSources)
+	}
+	if len(tf.Providers) > 0 {
+		providers := make([]string, len(tf.Providers))
+		for i, b := range tf.Providers {
+			providers[i] = b.Address()
+			if attrs := providerAttributes(b.Body); len(attrs) > 0 {
+				providers[i] += " (" + strings.Join(attrs, ", ") + ")"
+			}
+		}
+		fmt.Fprintf(&sb, "Providers: %s\n", strings.Join(providers, ", "))
+	}
+	writeSection("Variables", tf.Variables)
+	if len(tf.Locals) > 0 {
+		fmt.Fprintf(&sb, "Locals: %s\n", strings.Join(tf.Locals, ", "))
+	}
+	if len(tf.SecurityGroupRules) > 0 {
+		sb.WriteString("Security Group Rules:\n")
+		sb.WriteString(securityGroupTable(tf.SecurityGroupRules))
+	}
+	if len(tf.LambdaFunctions) > 0 {
+		sb.WriteString("Lambda Functions:\n")
+		sb.WriteString(lambdaTable(tf.LambdaFunctions))
+	}
+	if tf.VariableValues != "" {
+		sb.WriteString("Variable Values:\n")
+		sb.WriteString(tf.VariableValues)
+	}
+	if tf.RedactedCount > 0 {
+		fmt.Fprintf(&sb, "Sensitive Values: %d values marked sensitive in Terraform were redacted for security and appear as %s. They are set; do not report them as missing or hardcoded.\n", tf.RedactedCount, sensitiveRedacted)
+	}
+	sb.WriteString(tf.WorkspaceRules.promptContext())
+	if issues := tf.providerIssues(); len(issues) > 0 {
+		sb.WriteString("Provider Credential Issues:\n")
+		for _, issue := range issues {
+			fmt.Fprintf(&sb, "- %s\n", issue)
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// terragruntModuleName is the module block the effective configuration of
+// a terragrunt.hcl file is rendered as.
+const terragruntModuleName = "terragrunt"
+
+// terragruntDependency is a dependency block of a terragrunt.hcl file.
+type terragruntDependency struct {
+	Name       string
+	ConfigPath string
+}
+
+// terragruntConfig is the part of a terragrunt.hcl file that shapes the
+// Terraform configuration it deploys.
+type terragruntConfig struct {
+	// Source is the terraform block's source expression.
+	Source string
+	// Inputs holds the inputs passed to the module as variables. Values
+	// that are not literal strings, such as lists and dependency outputs,
+	// hold their expression.
+	Inputs       map[string]string
+	Dependencies []terragruntDependency
+	Includes     []string
+}
+
+// parseTerragrunt extracts the module source, inputs, dependencies and
+// includes of a terragrunt.hcl file.
+func parseTerragrunt(code string) (terragruntConfig, error) {
+	src := []byte(code)
+	file, diags := hclsyntax.ParseConfig(src, "terragrunt.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		return terragruntConfig{}, fmt.Errorf("invalid terragrunt.hcl: %s", diags.Error())
+	}
+	body := file.Body.(*hclsyntax.Body)
+	exprText := func(expr hclsyntax.Expression) string {
+		return string(expr.Range().SliceBytes(src))
+	}
+
+	cfg := terragruntConfig{Inputs: make(map[string]string)}
+	for _, block := range body.Blocks {
+		switch block.Type {
+		case "terraform":
+			if attr, ok := block.Body.Attributes["source"]; ok {
+				cfg.Source = exprText(attr.Expr)
+			}
+		case "dependency":
+			dep := terragruntDependency{Name: strings.Join(block.Labels, ".")}
+			if attr, ok := block.Body.Attributes["config_path"]; ok {
+				dep.ConfigPath = terragruntString(attr.Expr, exprText)
+			}
+			cfg.Dependencies = append(cfg.Dependencies, dep)
+		case "include":
+			name := strings.Join(block.Labels, ".")
+			if name == "" {
+				name = "include"
+			}
+			cfg.Includes = append(cfg.Includes, name)
+		}
+	}
+	if cfg.Source == "" {
+		return terragruntConfig{}, errors.New("invalid terragrunt.hcl: no terraform block with a source")
+	}
+
+	if attr, ok := body.Attributes["inputs"]; ok {
+		obj, ok := attr.Expr.(*hclsyntax.ObjectConsExpr)
+		if !ok {
+			return terragruntConfig{}, errors.New("invalid terragrunt.hcl: inputs must be an object")
+		}
+		for _, item := range obj.Items {
+			name := hcl.ExprAsKeyword(item.KeyExpr)
+			if name == "" {
+				key, diags := item.KeyExpr.Value(nil)
+				if diags.HasErrors() || key.Type() != cty.String || key.IsNull() {
+					return terragruntConfig{}, fmt.Errorf("invalid terragrunt.hcl: input key %s is not a name", exprText(item.KeyExpr))
+				}
+				name = key.AsString()
+			}
+			cfg.Inputs[name] = terragruntString(item.ValueExpr, exprText)
+		}
+	}
+	return cfg, nil
+}
+
+// terragruntString returns the value of a literal string expression, or
+// the expression itself for anything else.
+func terragruntString(expr hclsyntax.Expression, exprText func(hclsyntax.Expression) string) string {
+	if len(expr.Variables()) == 0 {
+		v, diags := expr.Value(nil)
+		if !diags.HasErrors() && v.IsWhollyKnown() && !v.IsNull() && v.Type() == cty.String {
+			return v.AsString()
+		}
+	}
+	return exprText(expr)
+}
+
+// terragruntToHCL renders the effective Terraform configuration of a
+// terragrunt.hcl file: a module block for its terraform source with each
+// input wired to a variable. The input values are supplied separately, as
+// terraform.tfvars would be.
+func terragruntToHCL(code string) (string, error) {
+	cfg, err := parseTerragrunt(code)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Effective configuration of terragrunt.hcl\n")
+	for _, name := range cfg.Includes {
+		fmt.Fprintf(&sb, "# include %q: the included configuration is not available, so its inputs are not merged\n", name)
+	}
+	for _, dep := range cfg.Dependencies {
+		fmt.Fprintf(&sb, "# dependency %q: outputs of %s\n", dep.Name, dep.ConfigPath)
+	}
+	fmt.Fprintf(&sb, "module %q {\n", terragruntModuleName)
+	source, version := terragruntModuleSource(cfg.Source)
+	fmt.Fprintf(&sb, "  source = %s\n", source)
+	if version != "" {
+		fmt.Fprintf(&sb, "  version = %q\n", version)
+	}
+	names := sortedKeys(cfg.Inputs)
+	for _, name := range names {
+		fmt.Fprintf(&sb, "  %s = var.%s\n", name, name)
+	}
+	sb.WriteString("}\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "\nvariable %q {}\n", name)
+	}
+	return sb.String(), nil
+}
+
+// terragruntModuleSource converts a Terragrunt source expression into a
+// module source and version. Registry sources of the form
+// tfr:///namespace/name/provider?version=x become the registry address and
+// its version; other sources are used as they are.
+func terragruntModuleSource(expr string) (string, string) {
+	literal, err := strconv.Unquote(expr)
+	if err != nil || !strings.HasPrefix(literal, "tfr://") {
+		return expr, ""
+	}
+	u, err := url.Parse(literal)
+	if err != nil {
+		return expr, ""
+	}
+	source := strings.TrimPrefix(u.Path, "/")
+	if u.Host != "" {
+		source = u.Host + "/" + source
+	}
+	return strconv.Quote(source), u.Query().Get("version")
+}
+
+// mergeTerragruntInputs adds the inputs of a terragrunt.hcl request to its
+// variables, as Terragrunt's generated terraform.tfvars would. Variables
+// given in the request take precedence.
+func (req *AnalyzeRequest) mergeTerragruntInputs() error {
+	if req.Format != formatTerragrunt {
+		return nil
+	}
+	cfg, err := parseTerragrunt(req.Code)
+	if err != nil {
+		return err
+	}
+	if req.Variables == nil {
+		req.Variables = make(map[string]string, len(cfg.Inputs))
+	}
+	for name, value := range cfg.Inputs {
+		if _, ok := req.Variables[name]; !ok {
+			req.Variables[name] = value
+		}
+	}
+	return nil
+}
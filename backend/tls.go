@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsEnabled reports whether the server should serve HTTPS, either from
+// certificate files or from certificates obtained through ACME.
+func (cfg *ServerConfig) tlsEnabled() bool {
+	return cfg.TLSCertFile != "" || cfg.ACMEDomain != ""
+}
+
+// setupTLS configures srv to serve HTTPS and returns the handler for the
+// plaintext port. With ACME the plaintext port also answers Let's Encrypt
+// HTTP-01 challenges, so it must be reachable on port 80 from the internet.
+func setupTLS(srv *http.Server, cfg *ServerConfig) http.Handler {
+	redirect := httpsRedirect(cfg.ListenPort)
+	if cfg.ACMEDomain == "" {
+		return redirect
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.ACMEDomain),
+		Cache:      autocert.DirCache(cfg.ACMECacheDir),
+	}
+	srv.TLSConfig = m.TLSConfig()
+	return m.HTTPHandler(redirect)
+}
+
+// httpsRedirect permanently redirects requests to the same URL on the HTTPS port.
+func httpsRedirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// serve runs s with the transport selected by cfg until it is shut down.
+func (s *drainingServer) serve(cfg *ServerConfig) error {
+	switch {
+	case cfg.ACMEDomain != "":
+		return s.ListenAndServeTLS("", "")
+	case cfg.TLSCertFile != "":
+		return s.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	default:
+		return s.ListenAndServe()
+	}
+}
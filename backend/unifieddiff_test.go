@@ -0,0 +1,61 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// hunkHeaderPattern matches the hunk headers of a unified diff.
+var hunkHeaderPattern = regexp.MustCompile(`(?m)^@@ .* @@$`)
+
+func TestUnifiedDiffHunkHeaders(t *testing.T) {
+	lines := func(n int) []string {
+		var l []string
+		for i := 1; i <= n; i++ {
+			l = append(l, "line "+strings.Repeat("x", i))
+		}
+		return l
+	}
+	join := func(l []string) string { return strings.Join(l, "\n") + "\n" }
+	replace := func(l []string, i int, s string) []string {
+		l = slices.Clone(l)
+		l[i] = s
+		return l
+	}
+	twenty := lines(20)
+
+	tests := []struct {
+		name          string
+		before, after string
+		want          []string
+	}{
+		{"equal", "a\n", "a\n", nil},
+		{"change in the middle", join(twenty), join(replace(twenty, 9, "changed")), []string{"@@ -7,7 +7,7 @@"}},
+		{"change on the first line", join(twenty), join(replace(twenty, 0, "changed")), []string{"@@ -1,4 +1,4 @@"}},
+		{"line added at the end", join(twenty[:5]), join(append(slices.Clone(twenty[:5]), "added")), []string{"@@ -3,3 +3,4 @@"}},
+		{"lines removed", join(twenty[:5]), join(twenty[:2]), []string{"@@ -1,5 +1,2 @@"}},
+		{"file created", "", "a\nb\n", []string{"@@ -0,0 +1,2 @@"}},
+		{"file emptied", "a\nb\n", "", []string{"@@ -1,2 +0,0 @@"}},
+		{"nearby changes share a hunk", join(twenty), join(replace(replace(twenty, 4, "one"), 9, "two")), []string{"@@ -2,12 +2,12 @@"}},
+		{"distant changes get separate hunks", join(twenty), join(replace(replace(twenty, 1, "one"), 17, "two")), []string{"@@ -1,5 +1,5 @@", "@@ -15,6 +15,6 @@"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := unifiedDiff("main.tf", tt.before, tt.after)
+			if tt.want == nil {
+				if diff != "" {
+					t.Fatalf("unifiedDiff = %q, want empty", diff)
+				}
+				return
+			}
+			if !strings.HasPrefix(diff, "--- a/main.tf\n+++ b/main.tf\n") {
+				t.Errorf("unifiedDiff does not start with the file headers:\n%s", diff)
+			}
+			if got := hunkHeaderPattern.FindAllString(diff, -1); !slices.Equal(got, tt.want) {
+				t.Errorf("hunk headers = %q, want %q\n%s", got, tt.want, diff)
+			}
+		})
+	}
+}
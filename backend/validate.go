@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hashicorp/hcl/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SyntaxDiagnostic describes one HCL syntax error in submitted code.
+type SyntaxDiagnostic struct {
+	File    string `json:"file,omitempty"`
+	Message string `json:"message"`
+	Detail  string `json:"detail,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// SyntaxErrorResponse is returned with HTTP 400 when code fails to parse.
+type SyntaxErrorResponse struct {
+	Error       string             `json:"error"`
+	Diagnostics []SyntaxDiagnostic `json:"diagnostics"`
+}
+
+// syntaxErrors converts the error-level diagnostics in diags. Warnings are
+// dropped since they never block analysis.
+func syntaxErrors(diags hcl.Diagnostics) []SyntaxDiagnostic {
+	var errs []SyntaxDiagnostic
+	for _, d := range diags {
+		if d.Severity != hcl.DiagError {
+			continue
+		}
+		sd := SyntaxDiagnostic{Message: d.Summary, Detail: d.Detail}
+		if d.Subject != nil {
+			sd.File = d.Subject.Filename
+			sd.Line = d.Subject.Start.Line
+			sd.Column = d.Subject.Start.Column
+		}
+		errs = append(errs, sd)
+	}
+	return errs
+}
+
+// writeSyntaxErrors writes the 400 response listing errs.
+func writeSyntaxErrors(w http.ResponseWriter, errs []SyntaxDiagnostic) {
+	writeJSON(w, http.StatusBadRequest, SyntaxErrorResponse{Error: "Terraform code has syntax errors", Diagnostics: errs})
+}
+
+// parseSource parses source for analysis. Broken code would only produce a
+// confusing prompt, so on syntax errors it writes a 400 response listing them
+// and returns false instead of invoking the agent.
+func parseSource(ctx context.Context, w http.ResponseWriter, filename, source string) (*TerraformFile, bool) {
+	_, span := tracer.Start(ctx, "terraform.parse")
+	defer span.End()
+
+	tf, diags := parseTerraform(filename, source)
+	resourceCount := attribute.Int("terraform.resource_count", len(tf.Resources))
+	span.SetAttributes(resourceCount)
+	trace.SpanFromContext(ctx).SetAttributes(resourceCount)
+
+	if diags.HasErrors() {
+		recordSpanError(span, diags)
+		writeSyntaxErrors(w, syntaxErrors(diags))
+		return nil, false
+	}
+	return tf, true
+}
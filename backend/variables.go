@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// variableNamePattern matches valid Terraform input variable names.
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// validateVariables checks that every supplied variable has a valid name.
+func validateVariables(values map[string]string) error {
+	for name := range values {
+		if !variableNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid variable name %q", name)
+		}
+	}
+	return nil
+}
+
+// renderTFVars formats values as a .tfvars file, sorted by name.
+func renderTFVars(values map[string]string) string {
+	var sb strings.Builder
+	for _, name := range sortedKeys(values) {
+		fmt.Fprintf(&sb, "%s = %s\n", name, strconv.Quote(values[name]))
+	}
+	return sb.String()
+}
+
+// applyVariables records the supplied variable values on tf for the
+// analysis prompt, redacting sensitive variables and any secrets, and returns a warning
+// for each referenced variable that has neither a value nor a default.
+func (api *BedrockConverseAPI) applyVariables(logger *slog.Logger, tf *TerraformFile, values map[string]string) []string {
+	if len(values) > 0 {
+		shown := maps.Clone(values)
+		for _, name := range tf.sensitiveVariables() {
+			if _, ok := shown[name]; ok {
+				shown[name] = sensitiveRedacted
+				tf.RedactedCount++
+			}
+		}
+		var secretWarnings []string
+		tf.VariableValues, secretWarnings = api.Secrets.redact(renderTFVars(shown), &TerraformFile{})
+		if len(secretWarnings) > 0 {
+			logger.Warn("Redacted potential secrets from variable values", "count", len(secretWarnings))
+		}
+	}
+
+	var warnings []string
+	for _, name := range tf.variableReferences() {
+		if _, ok := values[name]; ok || tf.variableHasDefault(name) {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("Variable %s is referenced but no value was provided", name))
+	}
+	return warnings
+}
+
+// variableHasDefault reports whether the file declares variable name with a default value.
+func (tf *TerraformFile) variableHasDefault(name string) bool {
+	for _, v := range tf.Variables {
+		if v.Name == name {
+			_, ok := v.Body.Attributes["default"]
+			return ok
+		}
+	}
+	return false
+}
+
+// variableReferences returns the names of the input variables referenced by
+// resource, data source, provider and module blocks, sorted.
+func (tf *TerraformFile) variableReferences() []string {
+	var names []string
+	var visit func(body *hclsyntax.Body)
+	visit = func(body *hclsyntax.Body) {
+		for _, attr := range body.Attributes {
+			for _, traversal := range attr.Expr.Variables() {
+				if traversal.RootName() != "var" || len(traversal) < 2 {
+					continue
+				}
+				if step, ok := traversal[1].(hcl.TraverseAttr); ok {
+					names = append(names, step.Name)
+				}
+			}
+		}
+		for _, block := range body.Blocks {
+			visit(block.Body)
+		}
+	}
+
+	for _, blocks := range [][]TerraformBlock{tf.Resources, tf.DataSources, tf.Providers, tf.Modules} {
+		for _, b := range blocks {
+			visit(b.Body)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information, set at build time with
+//
+//	go build -ldflags "-X main.Version=1.2.3 -X main.Commit=$(git rev-parse HEAD) -X main.BuiltAt=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Values left unset fall back to what the Go toolchain embedded in the binary.
+var (
+	Version string
+	Commit  string
+	BuiltAt string
+)
+
+// VersionResponse defines the structure of the /version JSON response.
+type VersionResponse struct {
+	Version    string   `json:"version"`
+	Commit     string   `json:"commit,omitempty"`
+	BuiltAt    string   `json:"built_at,omitempty"`
+	GoVersion  string   `json:"go_version"`
+	AgentID    string   `json:"agent_id"`
+	Frameworks []string `json:"frameworks"`
+}
+
+// buildInfo returns the version, commit and build time of the binary. The
+// toolchain records the module version only for go install builds and the
+// VCS revision and commit time only for builds from a checkout.
+func buildInfo() (version, commit, builtAt string) {
+	version, commit, builtAt = Version, Commit, BuiltAt
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return fallback(version, "dev"), commit, builtAt
+	}
+	if version == "" && info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+	modified := false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			commit = fallback(commit, s.Value)
+		case "vcs.time":
+			builtAt = fallback(builtAt, s.Value)
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if modified && Commit == "" && commit != "" {
+		commit += "-dirty"
+	}
+	return fallback(version, "dev"), commit, builtAt
+}
+
+// fallback returns value, or def when value is empty.
+func fallback(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// versionHandler handles the /version endpoint.
+func (api *BedrockConverseAPI) versionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	ids := make([]string, len(frameworks))
+	for i, fw := range frameworks {
+		ids[i] = fw.ID
+	}
+
+	version, commit, builtAt := buildInfo()
+	writeJSON(w, http.StatusOK, VersionResponse{
+		Version:    version,
+		Commit:     commit,
+		BuiltAt:    builtAt,
+		GoVersion:  runtime.Version(),
+		AgentID:    api.Config().AgentID,
+		Frameworks: ids,
+	})
+}
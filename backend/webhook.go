@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// webhookMaxRetries is how many times a failed delivery is retried.
+	webhookMaxRetries = 3
+	// webhookBaseDelay is the wait before the first retry; it doubles
+	// after every failed attempt.
+	webhookBaseDelay = time.Second
+	// webhookTimeout bounds a single delivery attempt.
+	webhookTimeout = 10 * time.Second
+)
+
+// Headers sent with every webhook delivery. The signature is the hex
+// HMAC-SHA256 of the body keyed with WEBHOOK_SECRET, prefixed "sha256=".
+const (
+	webhookJobIDHeader     = "X-Job-ID"
+	webhookJobStatusHeader = "X-Job-Status"
+	webhookTimestampHeader = "X-Timestamp"
+	webhookSignatureHeader = "X-Signature"
+)
+
+// WebhookDelivery records one attempt to POST a job's result to its
+// callback URL. StatusCode is zero when no response was received.
+type WebhookDelivery struct {
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	SentAt     time.Time `json:"sent_at"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// DeliveriesResponse defines the structure of the /jobs/{job_id}/deliveries
+// JSON response.
+type DeliveriesResponse struct {
+	JobID       string            `json:"job_id"`
+	CallbackURL string            `json:"callback_url,omitempty"`
+	Deliveries  []WebhookDelivery `json:"deliveries"`
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, which is
+// not routable on the internet.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// webhookClient sends deliveries. Redirects are not followed, so signed
+// results only reach the URL the client registered, and connections to
+// internal addresses are refused when dialing, after DNS resolution, so a
+// callback host cannot be made to resolve to one later.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: webhookTimeout, Control: webhookDialControl}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// internalAddress reports whether ip is one a callback must not reach:
+// loopback, private, link-local (including cloud metadata endpoints such as
+// 169.254.169.254), shared, multicast or unspecified.
+func internalAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// webhookDialControl refuses connections to internal addresses.
+func webhookDialControl(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid webhook address %q: %w", address, err)
+	}
+	if internalAddress(addrPort.Addr()) {
+		return fmt.Errorf("webhook address %s is internal", addrPort.Addr())
+	}
+	return nil
+}
+
+// validateCallbackURL checks that raw is an absolute http or https URL
+// whose host, if given as an IP address, is not internal. Hostnames are
+// checked when a delivery connects.
+func validateCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid callback_url %q: must be an absolute http or https URL", raw)
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && internalAddress(ip) {
+		return fmt.Errorf("invalid callback_url %q: must not be an internal address", raw)
+	}
+	return nil
+}
+
+// signWebhook returns the X-Signature value for body.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyJob delivers a finished job's outcome to its callback URL in the
+// background, if it has one. Completed jobs send their AnalyzeResponse and
+// failed ones an ErrorResponse.
+func (api *BedrockConverseAPI) notifyJob(ctx context.Context, jobID string) {
+	j, callbackURL, ok := api.Jobs.callback(jobID)
+	if !ok || callbackURL == "" {
+		return
+	}
+
+	var payload any = ErrorResponse{Error: j.Error}
+	if j.Result != nil {
+		payload = j.Result
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		loggerFromContext(ctx).Error("Error encoding webhook body", "error", err)
+		return
+	}
+	go api.deliverWebhook(ctx, jobID, j.Status, callbackURL, body)
+}
+
+// deliverWebhook POSTs body to callbackURL, retrying failures with
+// exponential backoff, and records every attempt on the job.
+func (api *BedrockConverseAPI) deliverWebhook(ctx context.Context, jobID, status, callbackURL string, body []byte) {
+	logger := loggerFromContext(ctx).With("callback_url", callbackURL)
+	signature := signWebhook(api.Config().WebhookSecret, body)
+
+	for attempt := 0; ; attempt++ {
+		delivery := WebhookDelivery{Attempt: attempt + 1, SentAt: time.Now().UTC()}
+		statusCode, err := sendWebhook(ctx, callbackURL, jobID, status, signature, delivery.SentAt, body)
+		delivery.StatusCode = statusCode
+		delivery.DurationMS = time.Since(delivery.SentAt).Milliseconds()
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		api.Jobs.recordDelivery(jobID, delivery)
+
+		if err == nil {
+			logger.Info("Delivered webhook", "attempt", delivery.Attempt, "status_code", delivery.StatusCode)
+			return
+		}
+		if attempt >= webhookMaxRetries {
+			logger.Error("Webhook delivery failed, giving up", "attempt", delivery.Attempt, "status_code", delivery.StatusCode, "error", err)
+			return
+		}
+
+		delay := webhookBaseDelay << attempt
+		logger.Warn("Webhook delivery failed, retrying",
+			"attempt", delivery.Attempt,
+			"status_code", delivery.StatusCode,
+			"delay_ms", delay.Milliseconds(),
+			"error", err,
+		)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// sendWebhook makes one delivery attempt and returns the response status,
+// or zero if there was no response. Any status other than 2xx is an error.
+func sendWebhook(ctx context.Context, callbackURL, jobID, status, signature string, sentAt time.Time, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookJobIDHeader, jobID)
+	req.Header.Set(webhookJobStatusHeader, status)
+	req.Header.Set(webhookTimestampHeader, strconv.FormatInt(sentAt.Unix(), 10))
+	req.Header.Set(webhookSignatureHeader, signature)
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("callback responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// deliveriesHandler handles the /jobs/{job_id}/deliveries endpoint.
+func (api *BedrockConverseAPI) deliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	jobID := r.PathValue("job_id")
+	callbackURL, deliveries, ok := api.Jobs.deliveries(jobID)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Job not found or expired")
+		return
+	}
+	writeJSON(w, http.StatusOK, DeliveriesResponse{JobID: jobID, CallbackURL: callbackURL, Deliveries: nonNil(deliveries)})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// workerPoolQueueSize is the number of tasks that can wait for a free worker.
+const workerPoolQueueSize = 100
+
+// workItem is a task queued on a WorkerPool with the context it runs under.
+type workItem struct {
+	ctx    context.Context
+	task   func(context.Context) error
+	result chan<- error
+}
+
+// WorkerPool runs tasks on a fixed number of goroutines shared by every
+// request, so concurrent batches cannot together exceed the pool's size.
+type WorkerPool struct {
+	items chan workItem
+}
+
+// newWorkerPool starts a pool of size workers.
+func newWorkerPool(size int) *WorkerPool {
+	p := &WorkerPool{items: make(chan workItem, workerPoolQueueSize)}
+	for i := range size {
+		go p.work(strconv.Itoa(i))
+	}
+	return p
+}
+
+// Submit queues task and returns a channel that receives its error once it
+// has run. If ctx is cancelled before a worker picks the task up, the task is
+// skipped and the channel receives ctx.Err() instead.
+func (p *WorkerPool) Submit(ctx context.Context, task func(context.Context) error) <-chan error {
+	result := make(chan error, 1)
+	select {
+	case p.items <- workItem{ctx: ctx, task: task, result: result}:
+	case <-ctx.Done():
+		result <- ctx.Err()
+	}
+	return result
+}
+
+// work runs queued tasks, recording metrics under the worker's label.
+func (p *WorkerPool) work(worker string) {
+	jobs := workerJobs.WithLabelValues(worker)
+	errs := workerErrors.WithLabelValues(worker)
+	idle := workerIdleSeconds.WithLabelValues(worker)
+
+	for {
+		waitStart := time.Now()
+		item := <-p.items
+		idle.Add(time.Since(waitStart).Seconds())
+
+		if err := item.ctx.Err(); err != nil {
+			item.result <- err
+			continue
+		}
+		err := item.task(item.ctx)
+		jobs.Inc()
+		if err != nil {
+			errs.Inc()
+		}
+		item.result <- err
+	}
+}
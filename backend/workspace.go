@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// defaultWorkspace is the workspace Terraform selects when none is chosen.
+const defaultWorkspace = "default"
+
+// workspaceNamePattern limits workspace names to characters that are safe
+// to substitute into code and the prompt.
+var workspaceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,89}$`)
+
+// workspaceInterpolation is an interpolation of terraform.workspace alone.
+var workspaceInterpolation = regexp.MustCompile(`\$\{\s*terraform\.workspace\s*\}`)
+
+// WorkspaceRules adjusts the analysis of code deployed to one workspace.
+type WorkspaceRules struct {
+	// Requirements are extra controls the agent is asked to enforce.
+	Requirements []string `json:"requirements,omitempty"`
+	// SeverityOverrides replaces the severity of findings, keyed by rule ID.
+	SeverityOverrides map[string]string `json:"severity_overrides,omitempty"`
+	// IgnoredRules are rule IDs whose findings are dropped.
+	IgnoredRules []string `json:"ignored_rules,omitempty"`
+}
+
+// loadWorkspaceRules reads the per-workspace rule overrides from the JSON
+// file at path, an object keyed by workspace name. An empty path
+// configures none.
+func loadWorkspaceRules(path string) (map[string]WorkspaceRules, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace rules: %w", err)
+	}
+	var workspaces map[string]WorkspaceRules
+	if err := json.Unmarshal(data, &workspaces); err != nil {
+		return nil, fmt.Errorf("failed to decode workspace rules: %w", err)
+	}
+
+	for name, rules := range workspaces {
+		if !workspaceNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("workspace rules: invalid workspace name %q", name)
+		}
+		for ruleID, severity := range rules.SeverityOverrides {
+			if normalizeSeverity(severity) == "" {
+				return nil, fmt.Errorf("workspace rules: %s has unknown severity %q for %s", name, severity, ruleID)
+			}
+			rules.SeverityOverrides[ruleID] = normalizeSeverity(severity)
+		}
+	}
+	return workspaces, nil
+}
+
+// resolveWorkspace validates a requested workspace name, defaulting to
+// the default workspace.
+func resolveWorkspace(name string) (string, error) {
+	if name == "" {
+		return defaultWorkspace, nil
+	}
+	if !workspaceNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid workspace %q: use letters, digits, '-', '_' and '.'", name)
+	}
+	return name, nil
+}
+
+// substituteWorkspace replaces terraform.workspace in source with the
+// workspace name, as Terraform would when planning in that workspace.
+// Interpolations become the bare name and other references, such as
+// terraform.workspace == "production", a quoted string. Lines keep their
+// numbers, so findings still point at the submitted code.
+func substituteWorkspace(source, workspace string) string {
+	source = workspaceInterpolation.ReplaceAllLiteralString(source, workspace)
+
+	// Remaining references are found in the syntax tree rather than the
+	// text, so strings that merely mention terraform.workspace are kept.
+	file, diags := hclsyntax.ParseConfig([]byte(source), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return source
+	}
+	var refs []hcl.Range
+	hclsyntax.VisitAll(file.Body.(*hclsyntax.Body), func(node hclsyntax.Node) hcl.Diagnostics {
+		expr, ok := node.(*hclsyntax.ScopeTraversalExpr)
+		if !ok || len(expr.Traversal) != 2 || expr.Traversal.RootName() != "terraform" {
+			return nil
+		}
+		if attr, ok := expr.Traversal[1].(hcl.TraverseAttr); ok && attr.Name == "workspace" {
+			refs = append(refs, expr.SrcRange)
+		}
+		return nil
+	})
+
+	quoted := strconv.Quote(workspace)
+	for _, ref := range slices.Backward(refs) {
+		source = source[:ref.Start.Byte] + quoted + source[ref.End.Byte:]
+	}
+	return source
+}
+
+// applyWorkspace records the workspace tf is analyzed for and the rule
+// overrides configured for it.
+func (api *BedrockConverseAPI) applyWorkspace(tf *TerraformFile, workspace string) {
+	tf.Workspace = workspace
+	if rules, ok := api.Config().WorkspaceRules[workspace]; ok {
+		tf.WorkspaceRules = &rules
+	}
+}
+
+// apply drops the ignored findings and overrides the severity of the rest.
+// A nil WorkspaceRules leaves findings unchanged.
+func (rules *WorkspaceRules) apply(findings []Finding) []Finding {
+	if rules == nil {
+		return findings
+	}
+	kept := []Finding{}
+	for _, f := range findings {
+		if slices.Contains(rules.IgnoredRules, f.RuleID) {
+			continue
+		}
+		if severity, ok := rules.SeverityOverrides[f.RuleID]; ok {
+			f.Severity = severity
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// promptContext describes the workspace requirements for the analysis prompt.
+func (rules *WorkspaceRules) promptContext() string {
+	if rules == nil || len(rules.Requirements) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("Workspace Requirements:\n")
+	for _, req := range rules.Requirements {
+		fmt.Fprintf(&sb, "- %s\n", req)
+	}
+	return sb.String()
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket message types.
+const (
+	wsAnalyze  = "analyze"
+	wsFollowup = "followup"
+	wsChunk    = "chunk"
+	wsDone     = "done"
+	wsError    = "error"
+)
+
+// WSRequest is a message sent by the client over /ws.
+type WSRequest struct {
+	Type      string `json:"type"`
+	Code      string `json:"code,omitempty"`
+	Framework string `json:"framework,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// WSResponse is a message sent by the server over /ws. A request produces
+// any number of chunk messages followed by a done or error message.
+type WSResponse struct {
+	Type      string    `json:"type"`
+	Text      string    `json:"text,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	Findings  []Finding `json:"findings,omitempty"`
+	Error     string    `json:"error,omitempty"`
+
+	SecretWarnings []string `json:"secret_warnings,omitempty"`
+}
+
+// wsHandler handles the /ws endpoint, a conversational channel where the
+// client starts an analysis and then asks follow-up questions in the same
+// agent session. Messages are handled one at a time in the order received
+// and count against the client's rate limit like separate requests. Only
+// sessions started on the connection can be continued on it.
+func (api *BedrockConverseAPI) wsHandler(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || slices.Contains(api.Config().AllowedOrigins, "*") || slices.Contains(api.Config().AllowedOrigins, origin)
+	}}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response.
+		return
+	}
+	defer conn.Close()
+
+	logger := loggerFromContext(r.Context())
+	logger.Info("WebSocket connection opened")
+
+	// sessions are the session IDs analyzed on this connection.
+	sessions := map[string]bool{}
+	for {
+		var req WSRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Warn("WebSocket connection closed unexpectedly", "error", err)
+			}
+			return
+		}
+
+		var resp WSResponse
+		switch {
+		case api.Limiter != nil && !api.Limiter.allow(clientIP(r)):
+			resp = WSResponse{Type: wsError, SessionID: req.SessionID, Error: "Rate limit exceeded"}
+		case req.Type == wsAnalyze:
+			resp = api.wsAnalyze(r, conn, logger, req, sessions)
+		case req.Type == wsFollowup:
+			resp = api.wsFollowup(r, conn, logger, req, sessions)
+		default:
+			resp = WSResponse{Type: wsError, Error: `Unknown message type: must be "analyze" or "followup"`}
+		}
+		if err := conn.WriteJSON(resp); err != nil {
+			logger.Warn("Failed to write WebSocket message", "error", err)
+			return
+		}
+	}
+}
+
+// wsAnalyze analyzes req.Code in a new agent session, or the one named by
+// req.SessionID if it is in sessions, streaming the agent response to conn.
+// A new session is added to sessions.
+func (api *BedrockConverseAPI) wsAnalyze(r *http.Request, conn *websocket.Conn, logger *slog.Logger, req WSRequest, sessions map[string]bool) WSResponse {
+	if req.Code == "" {
+		return WSResponse{Type: wsError, Error: "Query text is empty or not a string"}
+	}
+	if detail, injected := api.checkPromptInjection(logger, req.Code); injected {
+		return WSResponse{Type: wsError, Error: errPromptInjection + ": " + detail}
+	}
+	fw, err := lookupFramework(req.Framework)
+	if err != nil {
+		return WSResponse{Type: wsError, Error: err.Error()}
+	}
+	sessionID, errResp, ok := wsSessionID(req.SessionID, sessions)
+	if !ok {
+		return errResp
+	}
+	sessions[sessionID] = true
+
+	tf, diags := parseTerraform("main.tf", req.Code)
+	if diags.HasErrors() {
+		return WSResponse{Type: wsError, Error: "Terraform code has syntax errors: " + diags.Error()}
+	}
+	if req.Framework == "" {
+		fw = detectFramework(tf)
+	}
+	agent := api.Config().agentFor(fw.ID)
+	logger = logger.With("agent_id", agent.AgentID, "session_id", sessionID, "framework", fw.ID)
+	secretWarnings := api.redactSource(logger, tf)
+
+	prompt, err := api.buildAnalysisPrompt(r.Context(), tf.Source, tf.ResourceTypes(), tf, fw)
+	if err != nil {
+		logger.Error("Failed to build analysis prompt", "error", err)
+		return WSResponse{Type: wsError, SessionID: sessionID, Error: "Analysis failed"}
+	}
+	result, err := api.invokeAgentWithRetry(r.Context(), logger, agent, sessionID, prompt, wsRelay(conn, logger))
+	if err != nil {
+		_, message := agentErrorStatus(err)
+		return WSResponse{Type: wsError, SessionID: sessionID, Error: message}
+	}
+
+	// Findings are a convenience; the streamed text is the answer.
+	findings, err := parseFindings(result.Suggestion)
+	if err != nil {
+		logger.Warn("Failed to parse agent response", "error", err)
+	}
+	api.annotateFindings(fw.ID, findings)
+	return WSResponse{Type: wsDone, SessionID: sessionID, Findings: findings, SecretWarnings: secretWarnings}
+}
+
+// wsFollowup sends a follow-up question to an agent session in sessions.
+func (api *BedrockConverseAPI) wsFollowup(r *http.Request, conn *websocket.Conn, logger *slog.Logger, req WSRequest, sessions map[string]bool) WSResponse {
+	if req.SessionID == "" || !sessions[req.SessionID] {
+		return WSResponse{Type: wsError, Error: "A session_id from an analysis on this connection is required for a followup"}
+	}
+	if req.Message == "" {
+		return WSResponse{Type: wsError, SessionID: req.SessionID, Error: "Message is empty"}
+	}
+	if detail, injected := api.checkPromptInjection(logger, req.Message); injected {
+		return WSResponse{Type: wsError, SessionID: req.SessionID, Error: errPromptInjection + ": " + detail}
+	}
+	// A session belongs to the agent that started it, so followups name the
+	// framework of the analysis they continue.
+	fw, err := lookupFramework(req.Framework)
+	if err != nil {
+		return WSResponse{Type: wsError, SessionID: req.SessionID, Error: err.Error()}
+	}
+	agent := api.Config().agentFor(fw.ID)
+	logger = logger.With("agent_id", agent.AgentID, "session_id", req.SessionID)
+
+	// Questions often quote code, so they are redacted like submitted code.
+	message, secretWarnings := api.Secrets.redact(req.Message, &TerraformFile{})
+	if len(secretWarnings) > 0 {
+		logger.Warn("Redacted potential secrets from follow-up message", "count", len(secretWarnings))
+	}
+
+	if _, err := api.invokeAgentWithRetry(r.Context(), logger, agent, req.SessionID, message, wsRelay(conn, logger)); err != nil {
+		_, msg := agentErrorStatus(err)
+		return WSResponse{Type: wsError, SessionID: req.SessionID, Error: msg}
+	}
+	return WSResponse{Type: wsDone, SessionID: req.SessionID}
+}
+
+// wsRelay returns an onChunk callback that forwards agent chunks to conn.
+func wsRelay(conn *websocket.Conn, logger *slog.Logger) func([]byte) {
+	return func(chunk []byte) {
+		if err := conn.WriteJSON(WSResponse{Type: wsChunk, Text: string(chunk)}); err != nil {
+			logger.Warn("Failed to write WebSocket message", "error", err)
+		}
+	}
+}
+
+// wsSessionID returns id if it is in sessions, or a new session ID if id is
+// empty.
+func wsSessionID(id string, sessions map[string]bool) (string, WSResponse, bool) {
+	if id == "" {
+		generated, err := newUUID()
+		if err != nil {
+			return "", WSResponse{Type: wsError, Error: "Failed to create session"}, false
+		}
+		return generated, WSResponse{}, true
+	}
+	if !sessions[id] {
+		return "", WSResponse{Type: wsError, Error: "Unknown session_id: sessions must be started on this connection"}, false
+	}
+	return id, WSResponse{}, true
+}
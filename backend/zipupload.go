@@ -0,0 +1,184 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// isMultipartUpload reports whether the request body is multipart/form-data.
+func isMultipartUpload(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// decodeZipUpload fills req from a multipart/form-data /analyze request
+// whose file field holds a ZIP archive of a Terraform directory. The .tf
+// files are concatenated into req.Code and the .tfvars files supply
+// req.Variables; framework, workspace and max_suggestions are read from
+// form fields. On failure it writes an error response and returns false.
+func (api *BedrockConverseAPI) decodeZipUpload(w http.ResponseWriter, r *http.Request, req *AnalyzeRequest) bool {
+	limit := int64(api.Config().MaxZipSize)
+	if err := r.ParseMultipartForm(limit); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeRequestTooLarge(w, tooLarge.Limit)
+			return false
+		}
+		writeJSONError(w, http.StatusBadRequest, "Invalid multipart form")
+		return false
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "The file field must contain a ZIP archive")
+		return false
+	}
+	defer file.Close()
+	if header.Size > limit {
+		writeRequestTooLarge(w, limit)
+		return false
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to read uploaded file")
+		return false
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "The file field is not a valid ZIP archive")
+		return false
+	}
+	if len(archive.File) > api.Config().MaxFilesInZip {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Too many files in ZIP archive: at most %d are allowed", api.Config().MaxFilesInZip))
+		return false
+	}
+
+	files, err := extractTerraformFiles(archive, api.Config().BatchMaxBytes)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	// Each file is checked on its own so syntax errors name the file and
+	// line they were found at, not a position in the combined code.
+	var code strings.Builder
+	var syntaxErrs []SyntaxDiagnostic
+	variables := make(map[string]string)
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, ".tfvars") {
+			values, diags := parseTFVars(f.Name, f.Content)
+			syntaxErrs = append(syntaxErrs, syntaxErrors(diags)...)
+			for name, value := range values {
+				variables[name] = value
+			}
+			continue
+		}
+		_, diags := parseTerraform(f.Name, f.Content)
+		syntaxErrs = append(syntaxErrs, syntaxErrors(diags)...)
+		fmt.Fprintf(&code, "# File: %s\n%s\n", f.Name, f.Content)
+	}
+	if len(syntaxErrs) > 0 {
+		writeSyntaxErrors(w, syntaxErrs)
+		return false
+	}
+	if code.Len() == 0 {
+		writeJSONError(w, http.StatusBadRequest, "ZIP archive contains no .tf files")
+		return false
+	}
+
+	req.Code, req.Variables = code.String(), variables
+	req.Framework = r.FormValue("framework")
+	req.Workspace = r.FormValue("workspace")
+	if v := r.FormValue("max_suggestions"); v != "" {
+		if req.MaxSuggestions, err = strconv.Atoi(v); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "max_suggestions must be an integer")
+			return false
+		}
+	}
+	return true
+}
+
+// extractTerraformFiles returns the .tf and .tfvars files in archive,
+// sorted by path with .auto.tfvars files last so their values take
+// precedence, as they would for Terraform. Provider caches and hidden
+// directories are skipped. The files may hold at most maxBytes in total
+// once decompressed.
+func extractTerraformFiles(archive *zip.Reader, maxBytes int) ([]BatchFile, error) {
+	var files []BatchFile
+	remaining := int64(maxBytes)
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || !(strings.HasSuffix(f.Name, ".tf") || strings.HasSuffix(f.Name, ".tfvars")) {
+			continue
+		}
+		if slices.ContainsFunc(strings.Split(path.Dir(f.Name), "/"), func(dir string) bool {
+			return strings.HasPrefix(dir, ".") && dir != "." || dir == "__MACOSX"
+		}) {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s in ZIP archive", f.Name)
+		}
+		// Reading one byte past the budget detects archives that
+		// decompress to more than they claim.
+		content, err := io.ReadAll(io.LimitReader(rc, remaining+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s from ZIP archive", f.Name)
+		}
+		if remaining -= int64(len(content)); remaining < 0 {
+			return nil, fmt.Errorf("ZIP archive too large: at most %d bytes of Terraform files are allowed", maxBytes)
+		}
+		files = append(files, BatchFile{Name: f.Name, Content: string(content)})
+	}
+
+	slices.SortFunc(files, func(a, b BatchFile) int {
+		aAuto, bAuto := strings.HasSuffix(a.Name, ".auto.tfvars"), strings.HasSuffix(b.Name, ".auto.tfvars")
+		if aAuto != bAuto {
+			if aAuto {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return files, nil
+}
+
+// parseTFVars returns the string, number and bool values assigned in a
+// .tfvars file. Lists, maps and expressions are skipped, since variable
+// values are passed to the agent as strings.
+func parseTFVars(filename, content string) (map[string]string, hcl.Diagnostics) {
+	file, diags := hclsyntax.ParseConfig([]byte(content), filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	values := make(map[string]string)
+	for name, attr := range file.Body.(*hclsyntax.Body).Attributes {
+		v, valDiags := attr.Expr.Value(nil)
+		if valDiags.HasErrors() || !v.IsWhollyKnown() || v.IsNull() || !v.Type().IsPrimitiveType() {
+			continue
+		}
+		if s, err := convert.Convert(v, cty.String); err == nil {
+			values[name] = s.AsString()
+		}
+	}
+	return values, diags
+}